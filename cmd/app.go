@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/urfave/cli/v2"
 )
@@ -12,16 +13,36 @@ func Execute() {
 		Usage: "A CLI for sending emails via multiple providers",
 		Description: "email-cli supports sending emails through:\n" +
 			"  - Google Workspace (OAuth2)\n" +
+			"  - Microsoft 365 / Outlook (Graph API)\n" +
+			"  - Amazon SES\n" +
+			"  - SendGrid, Mailgun, Postmark and Resend\n" +
+			"  - JMAP (Fastmail, Stalwart)\n" +
+			"  - HTTP webhooks (in-house gateways, chat bridges)\n" +
+			"  - Plugin executables (JSON over stdin/stdout)\n" +
 			"  - Proton Mail (via Bridge)\n" +
-			"  - Generic SMTP\n\n" +
+			"  - Generic SMTP\n" +
+			"  - Local delivery (pipe to a command, or LMTP)\n" +
+			"  - .eml files or a Maildir (development and CI)\n" +
+			"  - Failover chains across any of the above\n" +
+			"  - Pools that spread sends across accounts\n\n" +
 			"Perfect for automation, scripts, and AI agents.",
 		Commands: []*cli.Command{
 			sendCommand(),
+			sendmailCommand(),
+			draftsCommand(),
+			quotaCommand(),
+			queueCommand(),
 			configCommand(),
 		},
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	args := os.Args
+	// Invoked as "sendmail" (e.g. through a symlink), act as the sendmail command.
+	if filepath.Base(args[0]) == "sendmail" {
+		args = append([]string{args[0], "sendmail"}, args[1:]...)
+	}
+
+	if err := app.Run(args); err != nil {
 		// Keep behavior similar to cobra root Execute(): print error and exit 1.
 		_, _ = os.Stderr.WriteString(err.Error() + "\n")
 		os.Exit(1)
@@ -20,6 +20,8 @@ func configCommand() *cli.Command {
 			configDefaultCommand(),
 			configPathCommand(),
 			configSetCommand(),
+			configCheckCommand(),
+			configRouteCommand(),
 		},
 	}
 }
@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -38,12 +44,30 @@ func configAddCommand() *cli.Command {
 			"    --username me@example.com \\\n" +
 			"    --password \"secret\" \\\n" +
 			"    --tls\n\n" +
+			"  # SMTP with an IMAP server for drafts\n" +
+			"  email-cli config add --name work \\\n" +
+			"    --type smtp \\\n" +
+			"    --from me@example.com \\\n" +
+			"    --host smtp.example.com \\\n" +
+			"    --username me@example.com \\\n" +
+			"    --password \"secret\" \\\n" +
+			"    --imap-host imap.example.com\n\n" +
 			"  # Proton Mail\n" +
 			"  email-cli config add --name proton \\\n" +
 			"    --type proton \\\n" +
 			"    --from me@proton.me \\\n" +
 			"    --username me@proton.me \\\n" +
 			"    --password \"bridge-password\"\n\n" +
+			"  # Pipe to a local sendmail\n" +
+			"  email-cli config add --name local \\\n" +
+			"    --type pipe \\\n" +
+			"    --from me@example.com \\\n" +
+			"    --command \"/usr/sbin/sendmail -oi -t\"\n\n" +
+			"  # LMTP into Dovecot\n" +
+			"  email-cli config add --name dovecot \\\n" +
+			"    --type lmtp \\\n" +
+			"    --from me@example.com \\\n" +
+			"    --socket /var/run/dovecot/lmtp\n\n" +
 			"  # Google (device auth by default)\n" +
 			"  email-cli config add --name google \\\n" +
 			"    --type google \\\n" +
@@ -56,22 +80,113 @@ func configAddCommand() *cli.Command {
 			"    --from me@gmail.com \\\n" +
 			"    --client-id \"xxx.apps.googleusercontent.com\" \\\n" +
 			"    --client-secret \"xxx\" \\\n" +
-			"    --oauth-method local",
+			"    --oauth-method local\n\n" +
+			"  # Microsoft 365 / Outlook (device auth)\n" +
+			"  email-cli config add --name outlook \\\n" +
+			"    --type microsoft \\\n" +
+			"    --from me@contoso.com \\\n" +
+			"    --client-id \"00000000-0000-0000-0000-000000000000\" \\\n" +
+			"    --tenant contoso.onmicrosoft.com\n\n" +
+			"  # Amazon SES\n" +
+			"  email-cli config add --name ses \\\n" +
+			"    --type ses \\\n" +
+			"    --from alerts@example.com \\\n" +
+			"    --region us-east-1 \\\n" +
+			"    --access-key-id AKIA... \\\n" +
+			"    --secret-access-key \"...\"\n\n" +
+			"  # JMAP (Fastmail API token)\n" +
+			"  email-cli config add --name fastmail \\\n" +
+			"    --type jmap \\\n" +
+			"    --from me@fastmail.com \\\n" +
+			"    --url https://api.fastmail.com/jmap/session \\\n" +
+			"    --access-token \"fmu1-...\"\n\n" +
+			"  # Webhook into an in-house gateway, signed with HMAC-SHA256\n" +
+			"  email-cli config add --name gateway \\\n" +
+			"    --type webhook \\\n" +
+			"    --from alerts@example.com \\\n" +
+			"    --url https://mail-gw.internal/send \\\n" +
+			"    --webhook-header \"Authorization: Bearer ...\" \\\n" +
+			"    --secret \"...\"\n\n" +
+			"  # Plugin executable speaking the JSON-over-stdio plugin protocol\n" +
+			"  email-cli config add --name gateway \\\n" +
+			"    --type plugin \\\n" +
+			"    --from alerts@example.com \\\n" +
+			"    --command email-cli-provider-foo \\\n" +
+			"    --option region=eu\n\n" +
+			"  # Write messages to a Maildir instead of sending (development, CI)\n" +
+			"  email-cli config add --name sink \\\n" +
+			"    --type file \\\n" +
+			"    --from dev@example.com \\\n" +
+			"    --path ./outbox \\\n" +
+			"    --maildir\n\n" +
+			"  # Fall over to a backup provider when the primary is unreachable\n" +
+			"  email-cli config add --name reliable \\\n" +
+			"    --type failover \\\n" +
+			"    --providers agent,sendgrid\n\n" +
+			"  # Spread sends across accounts, respecting each one's daily limit\n" +
+			"  email-cli config add --name outbound \\\n" +
+			"    --type pool \\\n" +
+			"    --providers inbox1,inbox2,inbox3 \\\n" +
+			"    --strategy quota \\\n" +
+			"    --daily-limit inbox1=100 --daily-limit inbox2=100 --daily-limit inbox3=100\n\n" +
+			"  # Mailgun (EU region)\n" +
+			"  email-cli config add --name mailgun \\\n" +
+			"    --type mailgun \\\n" +
+			"    --from alerts@mg.example.com \\\n" +
+			"    --api-key \"key-...\" \\\n" +
+			"    --domain mg.example.com \\\n" +
+			"    --region eu\n\n" +
+			"  # Google Workspace service account (no consent flow)\n" +
+			"  email-cli config add --name alerts \\\n" +
+			"    --type google \\\n" +
+			"    --from alerts@company.com \\\n" +
+			"    --service-account-key /etc/email-cli/sa.json",
 		Flags: []cli.Flag{
 			&cli.StringFlag{Name: "name", Aliases: []string{"n"}, Usage: "Provider name (alternative to positional arg)"},
-			&cli.StringFlag{Name: "type", Usage: "Provider type: agentmail, smtp, proton, google"},
-			&cli.StringFlag{Name: "api-key", Usage: "AgentMail API key"},
+			&cli.StringFlag{Name: "type", Usage: "Provider type: agentmail, smtp, proton, google, microsoft, ses, sendgrid, mailgun, postmark, resend, jmap, webhook, plugin, file, pipe, lmtp, failover, pool"},
+			&cli.StringFlag{Name: "api-key", Usage: "API key (agentmail, sendgrid, mailgun, resend) or server token (postmark)"},
 			&cli.StringFlag{Name: "inbox-id", Usage: "AgentMail inbox ID"},
 			&cli.StringFlag{Name: "from", Usage: "From email address"},
-			&cli.StringFlag{Name: "host", Usage: "SMTP host / Bridge host"},
-			&cli.IntFlag{Name: "port", Usage: "SMTP port / Bridge port"},
+			&cli.StringFlag{Name: "host", Usage: "SMTP host / Bridge host / LMTP host"},
+			&cli.IntFlag{Name: "port", Usage: "SMTP port / Bridge port / LMTP port"},
 			&cli.StringFlag{Name: "username", Usage: "Username"},
 			&cli.StringFlag{Name: "password", Usage: "Password"},
 			&cli.BoolFlag{Name: "tls", Value: true, Usage: "Use TLS (SMTP)"},
-			&cli.StringFlag{Name: "client-id", Usage: "Google OAuth client ID"},
+			&cli.StringFlag{Name: "imap-host", Usage: "IMAP host for drafts (SMTP)"},
+			&cli.IntFlag{Name: "imap-port", Usage: "IMAP port (default: 993)"},
+			&cli.StringFlag{Name: "imap-username", Usage: "IMAP username (default: --username)"},
+			&cli.StringFlag{Name: "imap-password", Usage: "IMAP password (default: --password)"},
+			&cli.StringFlag{Name: "command", Usage: "Command to pipe messages to (pipe), or plugin executable (plugin)"},
+			&cli.StringSliceFlag{Name: "option", Usage: "Option passed to the plugin as key=value (plugin, repeatable)"},
+			&cli.StringFlag{Name: "socket", Usage: "LMTP Unix socket path (lmtp)"},
+			&cli.StringFlag{Name: "path", Usage: "Directory to write messages to (file)"},
+			&cli.BoolFlag{Name: "maildir", Usage: "Deliver into a Maildir instead of writing .eml files (file)"},
+			&cli.StringSliceFlag{Name: "providers", Usage: "Providers to try in order (failover) or to spread sends across (pool), comma-separated"},
+			&cli.StringFlag{Name: "strategy", Usage: "How a pool picks a provider: round-robin (default), weighted, or quota (pool)"},
+			&cli.StringSliceFlag{Name: "weight", Usage: "Member's share of sends as name=N, with the weighted strategy (pool, repeatable)"},
+			&cli.StringSliceFlag{Name: "daily-limit", Usage: "Member's messages per UTC day as name=N (pool, repeatable)"},
+			&cli.StringFlag{Name: "client-id", Usage: "Google OAuth client ID / Microsoft application (client) ID"},
 			&cli.StringFlag{Name: "client-secret", Usage: "Google OAuth client secret"},
-			&cli.StringFlag{Name: "access-token", Usage: "Google OAuth access token"},
-			&cli.StringFlag{Name: "refresh-token", Usage: "Google OAuth refresh token"},
+			&cli.StringFlag{Name: "access-token", Usage: "Google or Microsoft OAuth access token, or JMAP API token"},
+			&cli.StringFlag{Name: "refresh-token", Usage: "Google or Microsoft OAuth refresh token"},
+			&cli.StringFlag{Name: "tenant", Value: "common", Usage: "Microsoft Entra tenant ID or domain (microsoft)"},
+			&cli.StringFlag{Name: "service-account-key", Usage: "Google service account JSON key file (replaces OAuth; requires domain-wide delegation)"},
+			&cli.StringFlag{Name: "subject", Usage: "Workspace user to impersonate with --service-account-key (default: --from)"},
+			&cli.StringFlag{Name: "region", Usage: "AWS region (ses), or us or eu (mailgun)"},
+			&cli.StringFlag{Name: "access-key-id", Usage: "AWS access key ID (ses)"},
+			&cli.StringFlag{Name: "secret-access-key", Usage: "AWS secret access key (ses)"},
+			&cli.StringFlag{Name: "session-token", Usage: "AWS session token for temporary credentials (ses)"},
+			&cli.StringFlag{Name: "profile", Usage: "AWS shared credentials profile, used when no access key is given (ses)"},
+			&cli.StringFlag{Name: "configuration-set", Usage: "SES configuration set (ses)"},
+			&cli.StringFlag{Name: "domain", Usage: "Sending domain (mailgun)"},
+			&cli.StringFlag{Name: "url", Usage: "JMAP session URL, or server URL for /.well-known/jmap discovery (jmap); endpoint to POST to (webhook)"},
+			&cli.StringFlag{Name: "message-stream", Usage: "Message stream (postmark, default: outbound)"},
+			&cli.StringFlag{Name: "format", Usage: "Request body format: json or multipart (webhook, default: json)"},
+			&cli.StringFlag{Name: "content-type", Usage: "Content-Type of the request body (webhook, default: application/json, or text/plain with --template-file)"},
+			&cli.StringSliceFlag{Name: "webhook-header", Usage: "Extra request header as \"Name: value\" (webhook, repeatable)"},
+			&cli.StringFlag{Name: "secret", Usage: "HMAC-SHA256 signing secret (webhook)"},
+			&cli.StringFlag{Name: "signature-header", Usage: "Header carrying the signature (webhook, default: X-Signature-256)"},
+			&cli.StringFlag{Name: "template-file", Usage: "Go template file rendered as the request body (webhook)"},
 			&cli.StringFlag{Name: "oauth-method", Value: "device", Usage: "Google OAuth method when tokens are not provided: device or local"},
 			&cli.BoolFlag{Name: "default", Usage: "Set as default provider"},
 			&cli.BoolFlag{Name: "use-keychain", Usage: "Store secrets in macOS Keychain instead of config file"},
@@ -113,24 +228,44 @@ func runConfigAdd(c *cli.Context) error {
 			return err
 		}
 	} else {
-		if err := buildProviderConfigInteractive(&providerCfg, useKeychain); err != nil {
+		if err := buildProviderConfigInteractive(c.Context, &providerCfg, useKeychain); err != nil {
 			return err
 		}
 	}
 
-	cfg.Providers[name] = providerCfg
+	// The config is loaded again, under its lock, since setup may have
+	// taken a while.
+	var isDefault bool
+	err = config.Update(func(cfg *config.Config) error {
+		if _, exists := cfg.Providers[name]; exists {
+			return fmt.Errorf("provider %q already exists", name)
+		}
+		if providerCfg.Failover != nil {
+			if _, err := cfg.Members(providerCfg.Failover.Providers); err != nil {
+				return err
+			}
+		}
+		if providerCfg.Pool != nil {
+			if _, err := cfg.Members(providerCfg.Pool.Names()); err != nil {
+				return err
+			}
+		}
 
-	// Set as default if first provider or --default flag.
-	if cfg.DefaultProvider == "" || c.Bool("default") {
-		cfg.DefaultProvider = name
-	}
+		cfg.Providers[name] = providerCfg
 
-	if err := cfg.Save(); err != nil {
+		// Set as default if first provider or --default flag.
+		if cfg.DefaultProvider == "" || c.Bool("default") {
+			cfg.DefaultProvider = name
+		}
+		isDefault = cfg.DefaultProvider == name
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
 	fmt.Printf("Provider %q added successfully!\n", name)
-	if cfg.DefaultProvider == name {
+	if isDefault {
 		fmt.Println("(Set as default provider)")
 	}
 	return nil
@@ -140,8 +275,9 @@ func buildProviderConfigFromFlags(c *cli.Context, providerCfg *config.ProviderCo
 	cfgType := c.String("type")
 	useKeychain := c.Bool("use-keychain")
 
-	// AgentMail doesn't require --from (uses inbox email)
-	if cfgType != "agentmail" {
+	// AgentMail doesn't require --from (uses inbox email), and failover
+	// and pool members send from their own addresses.
+	if cfgType != "agentmail" && cfgType != "failover" && cfgType != "pool" {
 		cfgFrom := c.String("from")
 		if cfgFrom == "" {
 			return fmt.Errorf("--from is required")
@@ -202,6 +338,22 @@ func buildProviderConfigFromFlags(c *cli.Context, providerCfg *config.ProviderCo
 			UseTLS:   c.Bool("tls"),
 		}
 
+		if imapHost := c.String("imap-host"); imapHost != "" {
+			imapPassword := c.String("imap-password")
+			if useKeychain && imapPassword != "" {
+				if err := keychain.Set(providerCfg.Name+"/imap-password", imapPassword); err != nil {
+					return fmt.Errorf("failed to store IMAP password in keychain: %w", err)
+				}
+				imapPassword = keychain.KeychainRef(providerCfg.Name, "imap-password")
+			}
+			providerCfg.SMTP.IMAP = &config.IMAPConfig{
+				Host:     imapHost,
+				Port:     c.Int("imap-port"),
+				Username: c.String("imap-username"),
+				Password: imapPassword,
+			}
+		}
+
 	case "proton":
 		host := c.String("host")
 		if host == "" {
@@ -230,6 +382,16 @@ func buildProviderConfigFromFlags(c *cli.Context, providerCfg *config.ProviderCo
 		}
 
 	case "google":
+		if keyPath := c.String("service-account-key"); keyPath != "" {
+			googleCfg, err := googleServiceAccountConfig(providerCfg.Name, keyPath, c.String("subject"), useKeychain)
+			if err != nil {
+				return err
+			}
+			providerCfg.Type = config.ProviderGoogle
+			providerCfg.Google = googleCfg
+			break
+		}
+
 		clientID := c.String("client-id")
 		clientSecret := c.String("client-secret")
 		if clientID == "" || clientSecret == "" {
@@ -240,7 +402,7 @@ func buildProviderConfigFromFlags(c *cli.Context, providerCfg *config.ProviderCo
 		tokenExpiry := ""
 		if accessToken == "" || refreshToken == "" {
 			var err error
-			accessToken, refreshToken, tokenExpiry, err = obtainGoogleTokens(clientID, clientSecret, c.String("oauth-method"))
+			accessToken, refreshToken, tokenExpiry, err = obtainGoogleTokens(c.Context, clientID, clientSecret, c.String("oauth-method"))
 			if err != nil {
 				return err
 			}
@@ -276,14 +438,224 @@ func buildProviderConfigFromFlags(c *cli.Context, providerCfg *config.ProviderCo
 			TokenExpiry:  tokenExpiry,
 		}
 
+	case "microsoft":
+		clientID := c.String("client-id")
+		if clientID == "" {
+			return fmt.Errorf("--client-id is required for Microsoft")
+		}
+		msCfg, err := microsoftConfig(c.Context, providerCfg.Name, clientID, c.String("tenant"),
+			c.String("access-token"), c.String("refresh-token"), useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderMicrosoft
+		providerCfg.Microsoft = msCfg
+
+	case "ses":
+		region := c.String("region")
+		if region == "" {
+			return fmt.Errorf("--region is required for SES")
+		}
+		accessKeyID := c.String("access-key-id")
+		secretAccessKey := c.String("secret-access-key")
+		if (accessKeyID == "") != (secretAccessKey == "") {
+			return fmt.Errorf("--access-key-id and --secret-access-key must be given together")
+		}
+		sesCfg, err := sesConfig(providerCfg.Name, region, accessKeyID, secretAccessKey,
+			c.String("session-token"), c.String("profile"), useKeychain)
+		if err != nil {
+			return err
+		}
+		sesCfg.ConfigurationSet = c.String("configuration-set")
+		providerCfg.Type = config.ProviderSES
+		providerCfg.SES = sesCfg
+
+	case "sendgrid":
+		apiKey := c.String("api-key")
+		if apiKey == "" {
+			return fmt.Errorf("--api-key is required for SendGrid")
+		}
+		apiKey, err := storeAPIKey(providerCfg.Name, apiKey, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderSendGrid
+		providerCfg.SendGrid = &config.SendGridConfig{APIKey: apiKey}
+
+	case "resend":
+		apiKey := c.String("api-key")
+		if apiKey == "" {
+			return fmt.Errorf("--api-key is required for Resend")
+		}
+		apiKey, err := storeAPIKey(providerCfg.Name, apiKey, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderResend
+		providerCfg.Resend = &config.ResendConfig{APIKey: apiKey}
+
+	case "mailgun":
+		apiKey := c.String("api-key")
+		domain := c.String("domain")
+		if apiKey == "" {
+			return fmt.Errorf("--api-key is required for Mailgun")
+		}
+		if domain == "" {
+			return fmt.Errorf("--domain is required for Mailgun")
+		}
+		region := c.String("region")
+		if region != "" && region != "us" && region != "eu" {
+			return fmt.Errorf("--region must be us or eu for Mailgun")
+		}
+		apiKey, err := storeAPIKey(providerCfg.Name, apiKey, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderMailgun
+		providerCfg.Mailgun = &config.MailgunConfig{APIKey: apiKey, Domain: domain, Region: region}
+
+	case "postmark":
+		token := c.String("api-key")
+		if token == "" {
+			return fmt.Errorf("--api-key (the server API token) is required for Postmark")
+		}
+		token, err := storeAPIKey(providerCfg.Name, token, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderPostmark
+		providerCfg.Postmark = &config.PostmarkConfig{ServerToken: token, MessageStream: c.String("message-stream")}
+
+	case "jmap":
+		jmapURL := c.String("url")
+		if jmapURL == "" {
+			return fmt.Errorf("--url is required for JMAP")
+		}
+		username, password, token := c.String("username"), c.String("password"), c.String("access-token")
+		if token == "" && (username == "" || password == "") {
+			return fmt.Errorf("--access-token, or --username and --password, are required for JMAP")
+		}
+		jmapCfg, err := jmapConfig(providerCfg.Name, jmapURL, username, password, token, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Type = config.ProviderJMAP
+		providerCfg.JMAP = jmapCfg
+
+	case "webhook":
+		webhookURL := c.String("url")
+		if webhookURL == "" {
+			return fmt.Errorf("--url is required for webhook")
+		}
+		headers, err := parseWebhookHeaders(c.StringSlice("webhook-header"))
+		if err != nil {
+			return err
+		}
+		var tmpl string
+		if path := c.String("template-file"); path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read template: %w", err)
+			}
+			tmpl = string(data)
+		}
+		webhookCfg, err := webhookConfig(providerCfg.Name, webhookURL, c.String("secret"), useKeychain)
+		if err != nil {
+			return err
+		}
+		webhookCfg.Format = c.String("format")
+		webhookCfg.ContentType = c.String("content-type")
+		webhookCfg.Headers = headers
+		webhookCfg.SignatureHeader = c.String("signature-header")
+		webhookCfg.Template = tmpl
+		providerCfg.Type = config.ProviderWebhook
+		providerCfg.Webhook = webhookCfg
+
+	case "plugin":
+		command := c.String("command")
+		if command == "" {
+			return fmt.Errorf("--command is required for plugin")
+		}
+		options, err := parsePluginOptions(c.StringSlice("option"))
+		if err != nil {
+			return err
+		}
+
+		providerCfg.Type = config.ProviderPlugin
+		providerCfg.Plugin = &config.PluginConfig{Command: command, Options: options}
+
+	case "file":
+		path := c.String("path")
+		if path == "" {
+			return fmt.Errorf("--path is required for file")
+		}
+		path, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+
+		providerCfg.Type = config.ProviderFile
+		providerCfg.File = &config.FileConfig{Path: path, Maildir: c.Bool("maildir")}
+
+	case "failover":
+		providers := c.StringSlice("providers")
+		if len(providers) == 0 {
+			return fmt.Errorf("--providers is required for failover")
+		}
+
+		providerCfg.Type = config.ProviderFailover
+		providerCfg.From = c.String("from")
+		providerCfg.Failover = &config.FailoverConfig{Providers: providers}
+
+	case "pool":
+		providers := c.StringSlice("providers")
+		if len(providers) == 0 {
+			return fmt.Errorf("--providers is required for pool")
+		}
+		pool, err := poolConfig(providers, c.String("strategy"), c.StringSlice("weight"), c.StringSlice("daily-limit"))
+		if err != nil {
+			return err
+		}
+
+		providerCfg.Type = config.ProviderPool
+		providerCfg.From = c.String("from")
+		providerCfg.Pool = pool
+
+	case "pipe":
+		command := c.String("command")
+		if command == "" {
+			return fmt.Errorf("--command is required for pipe")
+		}
+
+		providerCfg.Type = config.ProviderPipe
+		providerCfg.Pipe = &config.PipeConfig{Command: command}
+
+	case "lmtp":
+		socket := c.String("socket")
+		host := c.String("host")
+		if socket == "" && host == "" {
+			return fmt.Errorf("--socket or --host is required for LMTP")
+		}
+		port := c.Int("port")
+		if host != "" && port == 0 {
+			port = 24
+		}
+
+		providerCfg.Type = config.ProviderLMTP
+		providerCfg.LMTP = &config.LMTPConfig{
+			Socket: socket,
+			Host:   host,
+			Port:   port,
+		}
+
 	default:
-		return fmt.Errorf("invalid --type: must be agentmail, smtp, proton, or google")
+		return fmt.Errorf("invalid --type: must be agentmail, smtp, proton, google, microsoft, ses, sendgrid, mailgun, postmark, resend, jmap, webhook, plugin, file, pipe, lmtp, failover, or pool")
 	}
 
 	return nil
 }
 
-func buildProviderConfigInteractive(providerCfg *config.ProviderConfig, useKeychain bool) error {
+func buildProviderConfigInteractive(ctx context.Context, providerCfg *config.ProviderConfig, useKeychain bool) error {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("Select provider type:")
@@ -291,7 +663,21 @@ func buildProviderConfigInteractive(providerCfg *config.ProviderConfig, useKeych
 	fmt.Println("  2. Google Workspace (Gmail API with OAuth2)")
 	fmt.Println("  3. Proton Mail (via Bridge)")
 	fmt.Println("  4. Generic SMTP")
-	fmt.Print("\nChoice [1-4]: ")
+	fmt.Println("  5. Pipe to a command (e.g. sendmail)")
+	fmt.Println("  6. LMTP (local delivery, e.g. Dovecot)")
+	fmt.Println("  7. Microsoft 365 / Outlook (Graph API with OAuth2)")
+	fmt.Println("  8. Amazon SES")
+	fmt.Println("  9. SendGrid")
+	fmt.Println("  10. Mailgun")
+	fmt.Println("  11. Postmark")
+	fmt.Println("  12. Resend")
+	fmt.Println("  13. JMAP (Fastmail, Stalwart)")
+	fmt.Println("  14. Webhook (HTTP POST to your own gateway)")
+	fmt.Println("  15. Plugin (external provider executable)")
+	fmt.Println("  16. File or Maildir (write messages locally, for development)")
+	fmt.Println("  17. Failover (try other providers in order)")
+	fmt.Println("  18. Pool (spread sends across providers or accounts)")
+	fmt.Print("\nChoice [1-18]: ")
 
 	choice, _ := reader.ReadString('\n')
 	choice = strings.TrimSpace(choice)
@@ -317,11 +703,22 @@ func buildProviderConfigInteractive(providerCfg *config.ProviderConfig, useKeych
 		providerCfg.Google = &config.GoogleConfig{}
 
 		providerCfg.From = prompt(reader, "From email address")
+		oauthMethod := promptDefault(reader, "Auth method (device/local/service-account)", "device")
+		if oauthMethod == "service-account" {
+			keyPath := prompt(reader, "Service account JSON key path")
+			subject := promptDefault(reader, "User to impersonate", providerCfg.From)
+			googleCfg, err := googleServiceAccountConfig(providerCfg.Name, keyPath, subject, useKeychain)
+			if err != nil {
+				return err
+			}
+			providerCfg.Google = googleCfg
+			break
+		}
+
 		clientID := prompt(reader, "Client ID")
 		clientSecret := prompt(reader, "Client Secret")
 
-		oauthMethod := promptDefault(reader, "OAuth method (device/local)", "device")
-		accessToken, refreshToken, tokenExpiry, err := obtainGoogleTokens(clientID, clientSecret, oauthMethod)
+		accessToken, refreshToken, tokenExpiry, err := obtainGoogleTokens(ctx, clientID, clientSecret, oauthMethod)
 		if err != nil {
 			return err
 		}
@@ -402,6 +799,183 @@ func buildProviderConfigInteractive(providerCfg *config.ProviderConfig, useKeych
 		useTLS := promptDefault(reader, "Use TLS? (y/n)", "y")
 		providerCfg.SMTP.UseTLS = strings.ToLower(useTLS) == "y"
 
+		if imapHost := prompt(reader, "IMAP host for drafts (optional)"); imapHost != "" {
+			providerCfg.SMTP.IMAP = &config.IMAPConfig{Host: imapHost}
+		}
+
+	case "5":
+		providerCfg.Type = config.ProviderPipe
+		providerCfg.Pipe = &config.PipeConfig{}
+
+		providerCfg.From = prompt(reader, "From email address")
+		providerCfg.Pipe.Command = promptDefault(reader, "Command", "/usr/sbin/sendmail -oi -t")
+
+	case "6":
+		providerCfg.Type = config.ProviderLMTP
+		providerCfg.LMTP = &config.LMTPConfig{}
+
+		providerCfg.From = prompt(reader, "From email address")
+		address := promptDefault(reader, "Socket path or host:port", "/var/run/dovecot/lmtp")
+		if host, portStr, err := net.SplitHostPort(address); err == nil {
+			port, err := strconv.Atoi(portStr)
+			if err != nil {
+				return fmt.Errorf("invalid port: %w", err)
+			}
+			providerCfg.LMTP.Host = host
+			providerCfg.LMTP.Port = port
+		} else {
+			providerCfg.LMTP.Socket = address
+		}
+
+	case "7":
+		providerCfg.Type = config.ProviderMicrosoft
+
+		providerCfg.From = prompt(reader, "From email address")
+		clientID := prompt(reader, "Application (client) ID")
+		tenant := promptDefault(reader, "Tenant", "common")
+		msCfg, err := microsoftConfig(ctx, providerCfg.Name, clientID, tenant, "", "", useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Microsoft = msCfg
+
+	case "8":
+		providerCfg.Type = config.ProviderSES
+
+		providerCfg.From = prompt(reader, "From email address")
+		region := promptDefault(reader, "AWS region", "us-east-1")
+		accessKeyID := prompt(reader, "Access key ID (blank to use an AWS profile)")
+		var secretAccessKey, profile string
+		if accessKeyID != "" {
+			secretAccessKey = prompt(reader, "Secret access key")
+		} else {
+			profile = promptDefault(reader, "AWS profile", "default")
+		}
+		sesCfg, err := sesConfig(providerCfg.Name, region, accessKeyID, secretAccessKey, "", profile, useKeychain)
+		if err != nil {
+			return err
+		}
+		sesCfg.ConfigurationSet = prompt(reader, "Configuration set (optional)")
+		providerCfg.SES = sesCfg
+
+	case "9":
+		providerCfg.Type = config.ProviderSendGrid
+
+		providerCfg.From = prompt(reader, "From email address")
+		apiKey, err := storeAPIKey(providerCfg.Name, prompt(reader, "API Key"), useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.SendGrid = &config.SendGridConfig{APIKey: apiKey}
+
+	case "10":
+		providerCfg.Type = config.ProviderMailgun
+
+		providerCfg.From = prompt(reader, "From email address")
+		domain := prompt(reader, "Sending domain")
+		region := promptDefault(reader, "Region (us/eu)", "us")
+		apiKey, err := storeAPIKey(providerCfg.Name, prompt(reader, "API Key"), useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Mailgun = &config.MailgunConfig{APIKey: apiKey, Domain: domain, Region: region}
+
+	case "11":
+		providerCfg.Type = config.ProviderPostmark
+
+		providerCfg.From = prompt(reader, "From email address")
+		token, err := storeAPIKey(providerCfg.Name, prompt(reader, "Server API token"), useKeychain)
+		if err != nil {
+			return err
+		}
+		stream := promptDefault(reader, "Message stream", "outbound")
+		providerCfg.Postmark = &config.PostmarkConfig{ServerToken: token, MessageStream: stream}
+
+	case "12":
+		providerCfg.Type = config.ProviderResend
+
+		providerCfg.From = prompt(reader, "From email address")
+		apiKey, err := storeAPIKey(providerCfg.Name, prompt(reader, "API Key"), useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.Resend = &config.ResendConfig{APIKey: apiKey}
+
+	case "13":
+		providerCfg.Type = config.ProviderJMAP
+
+		providerCfg.From = prompt(reader, "From email address")
+		jmapURL := promptDefault(reader, "JMAP session URL", "https://api.fastmail.com/jmap/session")
+		var username, password string
+		token := prompt(reader, "API token (blank to use a password)")
+		if token == "" {
+			username = promptDefault(reader, "Username", providerCfg.From)
+			password = prompt(reader, "Password")
+		}
+		jmapCfg, err := jmapConfig(providerCfg.Name, jmapURL, username, password, token, useKeychain)
+		if err != nil {
+			return err
+		}
+		providerCfg.JMAP = jmapCfg
+
+	case "14":
+		providerCfg.Type = config.ProviderWebhook
+
+		providerCfg.From = prompt(reader, "From email address")
+		webhookURL := prompt(reader, "Webhook URL")
+		format := promptDefault(reader, "Body format (json/multipart)", "json")
+		secret := prompt(reader, "Signing secret (blank for none)")
+		webhookCfg, err := webhookConfig(providerCfg.Name, webhookURL, secret, useKeychain)
+		if err != nil {
+			return err
+		}
+		if format != "json" {
+			webhookCfg.Format = format
+		}
+		providerCfg.Webhook = webhookCfg
+
+	case "15":
+		providerCfg.Type = config.ProviderPlugin
+
+		providerCfg.From = prompt(reader, "From email address")
+		command := prompt(reader, "Plugin command (e.g. email-cli-provider-foo)")
+		providerCfg.Plugin = &config.PluginConfig{Command: command}
+		fmt.Println("Set plugin options with: email-cli config set " + providerCfg.Name + " option key=value")
+
+	case "16":
+		providerCfg.Type = config.ProviderFile
+
+		providerCfg.From = prompt(reader, "From email address")
+		path, err := filepath.Abs(prompt(reader, "Directory"))
+		if err != nil {
+			return err
+		}
+		format := promptDefault(reader, "Format (eml/maildir)", "eml")
+		providerCfg.File = &config.FileConfig{Path: path, Maildir: format == "maildir"}
+
+	case "17":
+		providerCfg.Type = config.ProviderFailover
+
+		var providers []string
+		for _, name := range strings.Split(prompt(reader, "Providers to try, in order (comma-separated)"), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				providers = append(providers, name)
+			}
+		}
+		providerCfg.Failover = &config.FailoverConfig{Providers: providers}
+
+	case "18":
+		providerCfg.Type = config.ProviderPool
+
+		providers := strings.Split(prompt(reader, "Providers to spread sends across (comma-separated)"), ",")
+		strategy := promptDefault(reader, "Strategy (round-robin/weighted/quota)", provider.PoolRoundRobin)
+		pool, err := poolConfig(providers, strategy, nil, nil)
+		if err != nil {
+			return err
+		}
+		providerCfg.Pool = pool
+		fmt.Println("Set weights and daily limits with: email-cli config set " + providerCfg.Name + " daily-limit <provider>=<n>")
+
 	default:
 		return fmt.Errorf("invalid choice")
 	}
@@ -409,7 +983,38 @@ func buildProviderConfigInteractive(providerCfg *config.ProviderConfig, useKeych
 	return nil
 }
 
-func obtainGoogleTokens(clientID, clientSecret, oauthMethod string) (string, string, string, error) {
+// googleServiceAccountConfig validates a service account key file and,
+// with useKeychain, moves its contents into the keychain.
+func googleServiceAccountConfig(name, keyPath, subject string, useKeychain bool) (*config.GoogleConfig, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+	if err := provider.ValidateGoogleServiceAccountKey(key); err != nil {
+		return nil, err
+	}
+
+	keyRef := keyPath
+	if useKeychain {
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, key); err != nil {
+			return nil, fmt.Errorf("invalid service account key: %w", err)
+		}
+		if err := keychain.Set(name+"/service-account-key", compact.String()); err != nil {
+			return nil, fmt.Errorf("failed to store service account key in keychain: %w", err)
+		}
+		keyRef = keychain.KeychainRef(name, "service-account-key")
+	} else if abs, err := filepath.Abs(keyPath); err == nil {
+		keyRef = abs
+	}
+
+	return &config.GoogleConfig{
+		ServiceAccountKey: keyRef,
+		Subject:           subject,
+	}, nil
+}
+
+func obtainGoogleTokens(ctx context.Context, clientID, clientSecret, oauthMethod string) (string, string, string, error) {
 	method := strings.ToLower(strings.TrimSpace(oauthMethod))
 	if method == "" {
 		method = "device"
@@ -421,7 +1026,7 @@ func obtainGoogleTokens(clientID, clientSecret, oauthMethod string) (string, str
 
 	switch method {
 	case "device":
-		auth, err := provider.GetGoogleDeviceAuth(clientID, clientSecret)
+		auth, err := provider.GetGoogleDeviceAuth(ctx, clientID, clientSecret)
 		if err != nil {
 			return "", "", "", err
 		}
@@ -435,7 +1040,7 @@ func obtainGoogleTokens(clientID, clientSecret, oauthMethod string) (string, str
 		}
 		fmt.Println("Waiting for authorization...")
 
-		token, err = provider.ExchangeGoogleDeviceAuth(clientID, clientSecret, auth)
+		token, err = provider.ExchangeGoogleDeviceAuth(ctx, clientID, clientSecret, auth)
 		if err != nil {
 			return "", "", "", err
 		}
@@ -445,18 +1050,29 @@ func obtainGoogleTokens(clientID, clientSecret, oauthMethod string) (string, str
 		if err != nil {
 			return "", "", "", err
 		}
+		verifier := provider.GenerateGoogleCodeVerifier()
 
-		fmt.Println("\nStarting local server for OAuth callback...")
-		fmt.Println("Open this URL in your browser to authorize:")
-		fmt.Println(provider.GetGoogleAuthURL(clientID, clientSecret, state))
+		listener, redirectURL, err := provider.ListenGoogleAuthCallback()
+		if err != nil {
+			return "", "", "", err
+		}
+		authURL := provider.GetGoogleAuthURL(clientID, clientSecret, redirectURL, state, verifier)
+
+		fmt.Printf("\nListening for the OAuth callback on %s\n", redirectURL)
+		if err := openBrowser(authURL); err == nil {
+			fmt.Println("Opened your browser to authorize. If it did not open, visit:")
+		} else {
+			fmt.Println("Open this URL in your browser to authorize:")
+		}
+		fmt.Println(authURL)
 		fmt.Println("\nWaiting for authorization...")
 
-		code, err := provider.RunGoogleAuthServer(state)
+		code, err := provider.RunGoogleAuthServer(ctx, listener, state)
 		if err != nil {
 			return "", "", "", fmt.Errorf("failed to get authorization: %w", err)
 		}
 
-		token, err = provider.ExchangeGoogleCode(clientID, clientSecret, code)
+		token, err = provider.ExchangeGoogleCode(ctx, clientID, clientSecret, redirectURL, code, verifier)
 		if err != nil {
 			return "", "", "", fmt.Errorf("failed to get token: %w", err)
 		}
@@ -476,3 +1092,222 @@ func obtainGoogleTokens(clientID, clientSecret, oauthMethod string) (string, str
 
 	return token.AccessToken, token.RefreshToken, tokenExpiry, nil
 }
+
+// microsoftConfig builds a Microsoft provider config, running the device
+// code flow unless both tokens are given.
+func microsoftConfig(ctx context.Context, name, clientID, tenant, accessToken, refreshToken string, useKeychain bool) (*config.MicrosoftConfig, error) {
+	tokenExpiry := ""
+	if accessToken == "" || refreshToken == "" {
+		auth, err := provider.GetMicrosoftDeviceAuth(ctx, clientID, tenant)
+		if err != nil {
+			return nil, err
+		}
+
+		fmt.Println("\nAuthorize this CLI with Microsoft.")
+		fmt.Printf("Open this URL:\n%s\n", auth.VerificationURI)
+		fmt.Printf("Enter this code: %s\n", auth.UserCode)
+		fmt.Println("Waiting for authorization...")
+
+		token, err := provider.ExchangeMicrosoftDeviceAuth(ctx, clientID, tenant, auth)
+		if err != nil {
+			return nil, err
+		}
+		if token.AccessToken == "" {
+			return nil, fmt.Errorf("microsoft oauth returned empty access token")
+		}
+		accessToken, refreshToken = token.AccessToken, token.RefreshToken
+		if !token.Expiry.IsZero() {
+			tokenExpiry = token.Expiry.Format(time.RFC3339)
+		}
+	}
+
+	if useKeychain {
+		if err := keychain.Set(name+"/access-token", accessToken); err != nil {
+			return nil, fmt.Errorf("failed to store access token in keychain: %w", err)
+		}
+		accessToken = keychain.KeychainRef(name, "access-token")
+
+		if refreshToken != "" {
+			if err := keychain.Set(name+"/refresh-token", refreshToken); err != nil {
+				return nil, fmt.Errorf("failed to store refresh token in keychain: %w", err)
+			}
+			refreshToken = keychain.KeychainRef(name, "refresh-token")
+		}
+	}
+
+	if tenant == "common" {
+		tenant = ""
+	}
+	return &config.MicrosoftConfig{
+		ClientID:     clientID,
+		Tenant:       tenant,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenExpiry:  tokenExpiry,
+	}, nil
+}
+
+// sesConfig builds an SES provider config, storing the secret key and
+// session token in the keychain when requested.
+func sesConfig(name, region, accessKeyID, secretAccessKey, sessionToken, profile string, useKeychain bool) (*config.SESConfig, error) {
+	if useKeychain {
+		if secretAccessKey != "" {
+			if err := keychain.Set(name+"/secret-access-key", secretAccessKey); err != nil {
+				return nil, fmt.Errorf("failed to store secret access key in keychain: %w", err)
+			}
+			secretAccessKey = keychain.KeychainRef(name, "secret-access-key")
+		}
+		if sessionToken != "" {
+			if err := keychain.Set(name+"/session-token", sessionToken); err != nil {
+				return nil, fmt.Errorf("failed to store session token in keychain: %w", err)
+			}
+			sessionToken = keychain.KeychainRef(name, "session-token")
+		}
+	}
+
+	return &config.SESConfig{
+		Region:          region,
+		AccessKeyID:     accessKeyID,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+		Profile:         profile,
+	}, nil
+}
+
+// storeAPIKey moves an API key into the keychain when requested,
+// returning the value to keep in the config.
+func storeAPIKey(name, apiKey string, useKeychain bool) (string, error) {
+	if !useKeychain {
+		return apiKey, nil
+	}
+	if err := keychain.Set(name+"/api-key", apiKey); err != nil {
+		return "", fmt.Errorf("failed to store API key in keychain: %w", err)
+	}
+	return keychain.KeychainRef(name, "api-key"), nil
+}
+
+// jmapConfig builds a JMAP provider config, storing the password and
+// token in the keychain when requested.
+func jmapConfig(name, jmapURL, username, password, token string, useKeychain bool) (*config.JMAPConfig, error) {
+	if useKeychain {
+		if password != "" {
+			if err := keychain.Set(name+"/password", password); err != nil {
+				return nil, fmt.Errorf("failed to store password in keychain: %w", err)
+			}
+			password = keychain.KeychainRef(name, "password")
+		}
+		if token != "" {
+			if err := keychain.Set(name+"/access-token", token); err != nil {
+				return nil, fmt.Errorf("failed to store access token in keychain: %w", err)
+			}
+			token = keychain.KeychainRef(name, "access-token")
+		}
+	}
+
+	return &config.JMAPConfig{
+		URL:      jmapURL,
+		Username: username,
+		Password: password,
+		Token:    token,
+	}, nil
+}
+
+// webhookConfig builds a webhook provider config, storing the signing
+// secret in the keychain when requested.
+func webhookConfig(name, webhookURL, secret string, useKeychain bool) (*config.WebhookConfig, error) {
+	if useKeychain && secret != "" {
+		if err := keychain.Set(name+"/secret", secret); err != nil {
+			return nil, fmt.Errorf("failed to store webhook secret in keychain: %w", err)
+		}
+		secret = keychain.KeychainRef(name, "secret")
+	}
+	return &config.WebhookConfig{URL: webhookURL, Secret: secret}, nil
+}
+
+// parseWebhookHeaders parses "Name: value" flags into a header map.
+func parseWebhookHeaders(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	headers := make(map[string]string, len(values))
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t\r\n") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid webhook header %q: want \"Name: value\"", v)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// parsePluginOptions parses "key=value" flags into an options map.
+func parsePluginOptions(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	options := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid plugin option %q: want key=value", v)
+		}
+		options[key] = value
+	}
+	return options, nil
+}
+
+// poolConfig builds a pool from its member names, strategy, and
+// name=N weights and daily limits.
+func poolConfig(providers []string, strategy string, weights, limits []string) (*config.PoolConfig, error) {
+	switch strategy {
+	case "", provider.PoolRoundRobin, provider.PoolWeighted, provider.PoolQuota:
+	default:
+		return nil, fmt.Errorf("invalid strategy %q: must be round-robin, weighted, or quota", strategy)
+	}
+
+	pool := &config.PoolConfig{Strategy: strategy}
+	for _, name := range providers {
+		if name = strings.TrimSpace(name); name != "" {
+			pool.Providers = append(pool.Providers, config.PoolMember{Provider: name})
+		}
+	}
+	for _, w := range weights {
+		if err := setPoolMember(pool, "weight", w); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range limits {
+		if err := setPoolMember(pool, "daily-limit", l); err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// setPoolMember sets a member's weight or daily-limit from name=N. An
+// empty N resets it.
+func setPoolMember(pool *config.PoolConfig, key, value string) error {
+	name, n, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("invalid %s %q: want provider=N", key, value)
+	}
+	number := 0
+	if n != "" {
+		var err error
+		if number, err = strconv.Atoi(n); err != nil || number < 0 {
+			return fmt.Errorf("invalid %s %q: want a number of zero or more", key, value)
+		}
+	}
+	for i := range pool.Providers {
+		if pool.Providers[i].Provider == name {
+			if key == "weight" {
+				pool.Providers[i].Weight = number
+			} else {
+				pool.Providers[i].DailyLimit = number
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %q is not in the pool", key, name)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/urfave/cli/v2"
+)
+
+func configCheckCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "check",
+		Usage:     "Check that a provider is ready to send",
+		ArgsUsage: "[name]",
+		Description: "Run the provider's health check without sending anything.\n" +
+			"Only plugin providers have one so far.",
+		Flags: []cli.Flag{
+			&cli.DurationFlag{Name: "timeout", Usage: "Abort after this long (default: provider timeout, if configured)"},
+		},
+		Action: runConfigCheck,
+	}
+}
+
+func runConfigCheck(c *cli.Context) error {
+	if c.Args().Len() > 1 {
+		return fmt.Errorf("usage: email-cli config check [name]")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	providerCfg, err := cfg.GetProvider(c.Args().First())
+	if err != nil {
+		return err
+	}
+	p, err := provider.New(providerCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create provider: %w", err)
+	}
+	checker, ok := p.(provider.Checker)
+	if !ok {
+		return fmt.Errorf("provider %s has no health check", p.Name())
+	}
+
+	err = withProviderContext(c.Context, providerCfg, c.Duration("timeout"), "check provider", func(ctx context.Context) error {
+		return checker.Check(ctx)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Provider %q is ready.\n", providerCfg.Name)
+	return nil
+}
@@ -22,21 +22,17 @@ func runConfigDefault(c *cli.Context) error {
 	}
 	name := c.Args().First()
 
-	cfg, err := config.Load()
+	err := config.Update(func(cfg *config.Config) error {
+		if _, exists := cfg.Providers[name]; !exists {
+			return fmt.Errorf("provider %q not found", name)
+		}
+		cfg.DefaultProvider = name
+		return nil
+	})
 	if err != nil {
 		return err
 	}
 
-	if _, exists := cfg.Providers[name]; !exists {
-		return fmt.Errorf("provider %q not found", name)
-	}
-
-	cfg.DefaultProvider = name
-
-	if err := cfg.Save(); err != nil {
-		return err
-	}
-
 	fmt.Printf("Default provider set to %q.\n", name)
 	return nil
 }
@@ -26,28 +26,25 @@ func runConfigRemove(c *cli.Context) error {
 	}
 	name := c.Args().First()
 
-	cfg, err := config.Load()
-	if err != nil {
-		return err
-	}
-
-	p, exists := cfg.Providers[name]
-	if !exists {
-		return fmt.Errorf("provider %q not found", name)
-	}
-
-	// Clean up any keychain entries for this provider
-	if keychain.IsSupported() {
-		cleanupKeychainSecrets(&p)
-	}
+	err := config.Update(func(cfg *config.Config) error {
+		p, exists := cfg.Providers[name]
+		if !exists {
+			return fmt.Errorf("provider %q not found", name)
+		}
 
-	delete(cfg.Providers, name)
+		// Clean up any keychain entries for this provider
+		if keychain.IsSupported() {
+			cleanupKeychainSecrets(&p)
+		}
 
-	if cfg.DefaultProvider == name {
-		cfg.DefaultProvider = selectNewDefault(cfg.Providers)
-	}
+		delete(cfg.Providers, name)
 
-	if err := cfg.Save(); err != nil {
+		if cfg.DefaultProvider == name {
+			cfg.DefaultProvider = selectNewDefault(cfg.Providers)
+		}
+		return nil
+	})
+	if err != nil {
 		return err
 	}
 
@@ -99,6 +96,9 @@ func keychainAccountsToDelete(p *config.ProviderConfig) []string {
 		if p.SMTP != nil && keychain.IsKeychainRef(p.SMTP.Password) {
 			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.SMTP.Password))
 		}
+		if p.SMTP != nil && p.SMTP.IMAP != nil && keychain.IsKeychainRef(p.SMTP.IMAP.Password) {
+			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.SMTP.IMAP.Password))
+		}
 	case config.ProviderProton:
 		if p.Proton != nil && keychain.IsKeychainRef(p.Proton.Password) {
 			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Proton.Password))
@@ -114,6 +114,81 @@ func keychainAccountsToDelete(p *config.ProviderConfig) []string {
 			if keychain.IsKeychainRef(p.Google.RefreshToken) {
 				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Google.RefreshToken))
 			}
+			if keychain.IsKeychainRef(p.Google.ServiceAccountKey) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Google.ServiceAccountKey))
+			}
+		}
+	case config.ProviderMicrosoft:
+		if p.Microsoft != nil {
+			if keychain.IsKeychainRef(p.Microsoft.AccessToken) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Microsoft.AccessToken))
+			}
+			if keychain.IsKeychainRef(p.Microsoft.RefreshToken) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Microsoft.RefreshToken))
+			}
+		}
+	case config.ProviderSES:
+		if p.SES != nil {
+			if keychain.IsKeychainRef(p.SES.SecretAccessKey) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.SES.SecretAccessKey))
+			}
+			if keychain.IsKeychainRef(p.SES.SessionToken) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.SES.SessionToken))
+			}
+		}
+	case config.ProviderSendGrid:
+		if p.SendGrid != nil && keychain.IsKeychainRef(p.SendGrid.APIKey) {
+			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.SendGrid.APIKey))
+		}
+	case config.ProviderMailgun:
+		if p.Mailgun != nil && keychain.IsKeychainRef(p.Mailgun.APIKey) {
+			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Mailgun.APIKey))
+		}
+	case config.ProviderPostmark:
+		if p.Postmark != nil && keychain.IsKeychainRef(p.Postmark.ServerToken) {
+			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Postmark.ServerToken))
+		}
+	case config.ProviderResend:
+		if p.Resend != nil && keychain.IsKeychainRef(p.Resend.APIKey) {
+			secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Resend.APIKey))
+		}
+	case config.ProviderJMAP:
+		if p.JMAP != nil {
+			if keychain.IsKeychainRef(p.JMAP.Password) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.JMAP.Password))
+			}
+			if keychain.IsKeychainRef(p.JMAP.Token) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.JMAP.Token))
+			}
+		}
+	case config.ProviderPlugin:
+		if p.Plugin != nil {
+			keys := make([]string, 0, len(p.Plugin.Options))
+			for key := range p.Plugin.Options {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if value := p.Plugin.Options[key]; keychain.IsKeychainRef(value) {
+					secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(value))
+				}
+			}
+		}
+	case config.ProviderWebhook:
+		if p.Webhook != nil {
+			if keychain.IsKeychainRef(p.Webhook.Secret) {
+				secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(p.Webhook.Secret))
+			}
+			names := make([]string, 0, len(p.Webhook.Headers))
+			for name := range p.Webhook.Headers {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				if value := p.Webhook.Headers[name]; keychain.IsKeychainRef(value) {
+					secretsToDelete = append(secretsToDelete, keychain.ParseKeychainRef(value))
+				}
+			}
 		}
 	}
 
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/urfave/cli/v2"
+)
+
+func configRouteCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "route",
+		Usage: "Manage rules that pick a provider by recipient, sender or subject",
+		Description: "Routes pick the provider for each recipient when send is run without\n" +
+			"--provider. The first matching route wins; recipients no route matches\n" +
+			"use the default provider. When recipients match different routes, the\n" +
+			"send is split into one message per provider.\n\n" +
+			"Examples:\n" +
+			"  # Company mail through the internal relay, everything else by default\n" +
+			"  email-cli config route add --domain ourcompany.com --provider relay\n\n" +
+			"  # Alerts through a failover chain\n" +
+			"  email-cli config route add --subject '^\\[alert\\]' --provider agent,relay\n\n" +
+			"  # See where mail would go\n" +
+			"  email-cli config route test alice@ourcompany.com bob@example.com",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "list",
+				Usage:  "List routes in the order they are tried",
+				Action: runConfigRouteList,
+			},
+			{
+				Name:  "add",
+				Usage: "Add a route",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "domain", Usage: "Recipient domain, e.g. example.com (*.example.com for subdomains)"},
+					&cli.StringFlag{Name: "recipient", Usage: "Regular expression matched against the recipient address"},
+					&cli.StringFlag{Name: "from", Usage: "Regular expression matched against the sender address"},
+					&cli.StringFlag{Name: "subject", Usage: "Regular expression matched against the subject"},
+					&cli.StringFlag{Name: "provider", Aliases: []string{"p"}, Usage: "Provider to send matching mail through", Required: true},
+					&cli.IntFlag{Name: "position", Usage: "Insert at this position, starting at 1 (default: last)"},
+				},
+				Action: runConfigRouteAdd,
+			},
+			{
+				Name:      "remove",
+				Usage:     "Remove a route by its position in route list",
+				ArgsUsage: "<position>",
+				Action:    runConfigRouteRemove,
+			},
+			{
+				Name:      "test",
+				Usage:     "Show which provider each recipient would be sent through",
+				ArgsUsage: "<recipient>...",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "from", Usage: "Sender address (default: the default provider's)"},
+					&cli.StringFlag{Name: "subject", Usage: "Subject"},
+				},
+				Action: runConfigRouteTest,
+			},
+		},
+	}
+}
+
+func runConfigRouteList(c *cli.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Routes) == 0 {
+		fmt.Println("No routes configured.")
+		return nil
+	}
+	for i, r := range cfg.Routes {
+		fmt.Printf("%d. %s\n", i+1, r)
+	}
+	if cfg.DefaultProvider != "" {
+		fmt.Printf("Otherwise: %s\n", cfg.DefaultProvider)
+	}
+	return nil
+}
+
+func runConfigRouteAdd(c *cli.Context) error {
+	route := config.Route{
+		Domain:    strings.TrimPrefix(c.String("domain"), "@"),
+		Recipient: c.String("recipient"),
+		From:      c.String("from"),
+		Subject:   c.String("subject"),
+		Provider:  c.String("provider"),
+	}
+	if err := route.Validate(); err != nil {
+		return err
+	}
+
+	pos := c.Int("position")
+	err := config.Update(func(cfg *config.Config) error {
+		if _, err := cfg.GetProvider(route.Provider); err != nil {
+			return err
+		}
+		if pos < 0 || pos > len(cfg.Routes)+1 {
+			return fmt.Errorf("position must be between 1 and %d", len(cfg.Routes)+1)
+		}
+		if pos == 0 {
+			pos = len(cfg.Routes) + 1
+		}
+		cfg.Routes = append(cfg.Routes[:pos-1], append([]config.Route{route}, cfg.Routes[pos-1:]...)...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Route %d added: %s\n", pos, route)
+	return nil
+}
+
+func runConfigRouteRemove(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("usage: email-cli config route remove <position>")
+	}
+
+	pos, posErr := strconv.Atoi(c.Args().First())
+	var route config.Route
+	err := config.Update(func(cfg *config.Config) error {
+		if posErr != nil || pos < 1 || pos > len(cfg.Routes) {
+			return fmt.Errorf("no route at position %q (see email-cli config route list)", c.Args().First())
+		}
+		route = cfg.Routes[pos-1]
+		cfg.Routes = append(cfg.Routes[:pos-1], cfg.Routes[pos:]...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Route %d removed: %s\n", pos, route)
+	return nil
+}
+
+func runConfigRouteTest(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return fmt.Errorf("usage: email-cli config route test <recipient>...")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	routed, err := cfg.RouteRecipients(c.String("from"), c.String("subject"), c.Args().Slice())
+	if err != nil {
+		return err
+	}
+	for _, r := range routed {
+		fmt.Printf("%s: %s\n", r.Provider.Name, strings.Join(r.Recipients, ", "))
+	}
+	return nil
+}
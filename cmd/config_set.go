@@ -2,10 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tnm/email-cli/internal/config"
 	"github.com/tnm/email-cli/internal/keychain"
+	"github.com/tnm/email-cli/internal/provider"
 	"github.com/urfave/cli/v2"
 )
 
@@ -15,16 +21,58 @@ func configSetCommand() *cli.Command {
 		Usage:     "Set a config value (for agents)",
 		ArgsUsage: "<name> <key> <value>",
 		Description: "Set a specific configuration value.\n\n" +
+			"Keys for all providers:\n" +
+			"  timeout, connect-timeout (durations such as 30s or 2m)\n" +
+			"  proxy (socks5://host:port or http://host:port, empty to disable)\n" +
+			"  rate-limit (sends per period such as 30/min, empty to disable), rate-burst\n" +
+			"  daily-quota, monthly-quota (recipients per UTC day or month, 0 to disable)\n" +
+			"  retries (times to retry transient failures), retry-max-wait (duration),\n" +
+			"  retry-ambiguous (true/false: retry sends that may have been delivered)\n\n" +
 			"Keys for AgentMail:\n" +
 			"  api-key, inbox-id\n\n" +
 			"Keys for SMTP/Proton:\n" +
 			"  from, host, port, username, password, tls\n\n" +
+			"Keys for SMTP drafts (IMAP):\n" +
+			"  imap-host, imap-port, imap-username, imap-password, imap-drafts\n\n" +
 			"Keys for Google:\n" +
-			"  from, client-id, client-secret, access-token, refresh-token\n\n" +
+			"  from, client-id, client-secret, access-token, refresh-token,\n" +
+			"  service-account-key, subject\n\n" +
+			"Keys for Microsoft:\n" +
+			"  from, client-id, tenant, access-token, refresh-token\n\n" +
+			"Keys for SES:\n" +
+			"  from, region, access-key-id, secret-access-key, session-token,\n" +
+			"  profile, configuration-set\n\n" +
+			"Keys for SendGrid/Resend:\n" +
+			"  from, api-key\n\n" +
+			"Keys for Mailgun:\n" +
+			"  from, api-key, domain, region\n\n" +
+			"Keys for Postmark:\n" +
+			"  from, api-key (server token), message-stream\n\n" +
+			"Keys for JMAP:\n" +
+			"  from, url, username, password, access-token\n\n" +
+			"Keys for Webhook:\n" +
+			"  from, url, format, content-type, secret, signature-header,\n" +
+			"  template-file, header (\"Name: value\" to add, \"Name:\" to remove)\n\n" +
+			"Keys for Plugin:\n" +
+			"  from, command, option (key=value to add, key= to remove;\n" +
+			"  with --use-keychain the value is stored in the keychain)\n\n" +
+			"Keys for File:\n" +
+			"  from, path, maildir (true/false)\n\n" +
+			"Keys for Failover:\n" +
+			"  from, providers (comma-separated, in the order to try them)\n\n" +
+			"Keys for Pool:\n" +
+			"  from, providers (comma-separated), strategy (round-robin/weighted/quota),\n" +
+			"  weight (provider=N), daily-limit (provider=N; provider= removes it)\n\n" +
+			"Keys for Pipe:\n" +
+			"  from, command\n\n" +
+			"Keys for LMTP:\n" +
+			"  from, socket, host, port\n\n" +
 			"Examples:\n" +
 			"  email-cli config set mymail password \"new-password\"\n" +
 			"  email-cli config set mymail host smtp.newserver.com\n" +
 			"  email-cli config set agent api-key \"am_...\"\n" +
+			"  email-cli config set relay rate-limit 30/min\n" +
+			"  email-cli config set gmail daily-quota 500\n" +
 			"  email-cli config set --use-keychain agent api-key \"am_...\"",
 		Flags: []cli.Flag{
 			&cli.BoolFlag{Name: "use-keychain", Usage: "Store secret in macOS Keychain"},
@@ -44,11 +92,19 @@ func runConfigSet(c *cli.Context) error {
 		return fmt.Errorf("--use-keychain is only supported on macOS")
 	}
 
-	cfg, err := config.Load()
+	err := config.Update(func(cfg *config.Config) error {
+		return setProviderKey(cfg, name, key, value, useKeychain)
+	})
 	if err != nil {
 		return err
 	}
 
+	fmt.Printf("Updated %s.%s\n", name, key)
+	return nil
+}
+
+// setProviderKey sets key on the named provider in cfg.
+func setProviderKey(cfg *config.Config, name, key, value string, useKeychain bool) error {
 	p, exists := cfg.Providers[name]
 	if !exists {
 		return fmt.Errorf("provider %q not found", name)
@@ -58,6 +114,61 @@ func runConfigSet(c *cli.Context) error {
 	case "from":
 		p.From = value
 
+	case "timeout", "connect-timeout":
+		if value != "" {
+			if _, err := time.ParseDuration(value); err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+		if key == "timeout" {
+			p.Timeout = value
+		} else {
+			p.ConnectTimeout = value
+		}
+
+	case "proxy":
+		if value != "" {
+			if _, err := provider.ParseProxyURL(value); err != nil {
+				return err
+			}
+		}
+		p.Proxy = value
+
+	case "rate-limit":
+		p.RateLimit = value
+		if _, _, err := p.Rate(); err != nil {
+			return err
+		}
+
+	case "retry-max-wait":
+		p.RetryMaxWait = value
+		if _, err := p.RetryWait(); err != nil {
+			return err
+		}
+
+	case "retry-ambiguous":
+		ambiguous, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid retry-ambiguous value: %w", err)
+		}
+		p.RetryAmbiguous = ambiguous
+
+	case "retries", "rate-burst", "daily-quota", "monthly-quota":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q: must be a number of zero or more", key, value)
+		}
+		switch key {
+		case "retries":
+			p.Retries = n
+		case "rate-burst":
+			p.RateBurst = n
+		case "daily-quota":
+			p.DailyQuota = n
+		default:
+			p.MonthlyQuota = n
+		}
+
 	case "host":
 		switch p.Type {
 		case config.ProviderSMTP:
@@ -70,6 +181,11 @@ func runConfigSet(c *cli.Context) error {
 				return fmt.Errorf("proton config missing for %q", name)
 			}
 			p.Proton.Host = value
+		case config.ProviderLMTP:
+			if p.LMTP == nil {
+				return fmt.Errorf("lmtp config missing for %q", name)
+			}
+			p.LMTP.Host = value
 		default:
 			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
@@ -90,6 +206,11 @@ func runConfigSet(c *cli.Context) error {
 				return fmt.Errorf("proton config missing for %q", name)
 			}
 			p.Proton.Port = port
+		case config.ProviderLMTP:
+			if p.LMTP == nil {
+				return fmt.Errorf("lmtp config missing for %q", name)
+			}
+			p.LMTP.Port = port
 		default:
 			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
@@ -106,6 +227,11 @@ func runConfigSet(c *cli.Context) error {
 				return fmt.Errorf("proton config missing for %q", name)
 			}
 			p.Proton.Username = value
+		case config.ProviderJMAP:
+			if p.JMAP == nil {
+				return fmt.Errorf("jmap config missing for %q", name)
+			}
+			p.JMAP.Username = value
 		default:
 			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
@@ -136,6 +262,18 @@ func runConfigSet(c *cli.Context) error {
 			} else {
 				p.Proton.Password = value
 			}
+		case config.ProviderJMAP:
+			if p.JMAP == nil {
+				return fmt.Errorf("jmap config missing for %q", name)
+			}
+			if useKeychain || keychain.IsKeychainRef(p.JMAP.Password) {
+				if err := keychain.Set(name+"/password", value); err != nil {
+					return fmt.Errorf("failed to store password in keychain: %w", err)
+				}
+				p.JMAP.Password = keychain.KeychainRef(name, "password")
+			} else {
+				p.JMAP.Password = value
+			}
 		default:
 			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
@@ -149,14 +287,255 @@ func runConfigSet(c *cli.Context) error {
 		}
 		p.SMTP.UseTLS = value == "true" || value == "1" || value == "yes"
 
+	case "imap-host", "imap-port", "imap-username", "imap-password", "imap-drafts":
+		if p.Type != config.ProviderSMTP {
+			return fmt.Errorf("key %q only valid for SMTP provider", key)
+		}
+		if p.SMTP == nil {
+			return fmt.Errorf("smtp config missing for %q", name)
+		}
+		if p.SMTP.IMAP == nil {
+			p.SMTP.IMAP = &config.IMAPConfig{}
+		}
+		imapCfg := p.SMTP.IMAP
+		switch key {
+		case "imap-host":
+			imapCfg.Host = value
+		case "imap-port":
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid port: %w", err)
+			}
+			imapCfg.Port = port
+		case "imap-username":
+			imapCfg.Username = value
+		case "imap-password":
+			if useKeychain || keychain.IsKeychainRef(imapCfg.Password) {
+				if err := keychain.Set(name+"/imap-password", value); err != nil {
+					return fmt.Errorf("failed to store IMAP password in keychain: %w", err)
+				}
+				imapCfg.Password = keychain.KeychainRef(name, "imap-password")
+			} else {
+				imapCfg.Password = value
+			}
+		case "imap-drafts":
+			imapCfg.Drafts = value
+		}
+		if *imapCfg == (config.IMAPConfig{}) {
+			p.SMTP.IMAP = nil
+		}
+
+	case "region":
+		switch p.Type {
+		case config.ProviderSES:
+			if p.SES == nil {
+				return fmt.Errorf("ses config missing for %q", name)
+			}
+			p.SES.Region = value
+		case config.ProviderMailgun:
+			if p.Mailgun == nil {
+				return fmt.Errorf("mailgun config missing for %q", name)
+			}
+			if value != "" && value != "us" && value != "eu" {
+				return fmt.Errorf("invalid region %q: must be us or eu", value)
+			}
+			p.Mailgun.Region = value
+		default:
+			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
+		}
+
+	case "access-key-id", "profile", "configuration-set":
+		if p.Type != config.ProviderSES {
+			return fmt.Errorf("key %q only valid for SES provider", key)
+		}
+		if p.SES == nil {
+			return fmt.Errorf("ses config missing for %q", name)
+		}
+		switch key {
+		case "access-key-id":
+			p.SES.AccessKeyID = value
+		case "profile":
+			p.SES.Profile = value
+		case "configuration-set":
+			p.SES.ConfigurationSet = value
+		}
+
+	case "secret-access-key", "session-token":
+		if p.Type != config.ProviderSES {
+			return fmt.Errorf("key %q only valid for SES provider", key)
+		}
+		if p.SES == nil {
+			return fmt.Errorf("ses config missing for %q", name)
+		}
+		field := &p.SES.SecretAccessKey
+		if key == "session-token" {
+			field = &p.SES.SessionToken
+		}
+		if value != "" && (useKeychain || keychain.IsKeychainRef(*field)) {
+			if err := keychain.Set(name+"/"+key, value); err != nil {
+				return fmt.Errorf("failed to store %s in keychain: %w", key, err)
+			}
+			*field = keychain.KeychainRef(name, key)
+		} else {
+			*field = value
+		}
+
+	case "command":
+		switch p.Type {
+		case config.ProviderPipe:
+			if p.Pipe == nil {
+				return fmt.Errorf("pipe config missing for %q", name)
+			}
+			p.Pipe.Command = value
+		case config.ProviderPlugin:
+			if p.Plugin == nil {
+				return fmt.Errorf("plugin config missing for %q", name)
+			}
+			p.Plugin.Command = value
+		default:
+			return fmt.Errorf("key %q only valid for Pipe and plugin providers", key)
+		}
+
+	case "option":
+		if p.Type != config.ProviderPlugin {
+			return fmt.Errorf("key %q only valid for plugin provider", key)
+		}
+		if p.Plugin == nil {
+			return fmt.Errorf("plugin config missing for %q", name)
+		}
+		options, err := parsePluginOptions([]string{value})
+		if err != nil {
+			return err
+		}
+		for opt, v := range options {
+			if v == "" {
+				delete(p.Plugin.Options, opt)
+				continue
+			}
+			if useKeychain {
+				if err := keychain.Set(name+"/option-"+opt, v); err != nil {
+					return fmt.Errorf("failed to store plugin option in keychain: %w", err)
+				}
+				v = keychain.KeychainRef(name, "option-"+opt)
+			}
+			if p.Plugin.Options == nil {
+				p.Plugin.Options = make(map[string]string)
+			}
+			p.Plugin.Options[opt] = v
+		}
+
+	case "path", "maildir":
+		if p.Type != config.ProviderFile {
+			return fmt.Errorf("key %q only valid for file provider", key)
+		}
+		if p.File == nil {
+			return fmt.Errorf("file config missing for %q", name)
+		}
+		if key == "maildir" {
+			maildir, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid maildir value: %w", err)
+			}
+			p.File.Maildir = maildir
+			break
+		}
+		path, err := filepath.Abs(value)
+		if err != nil {
+			return err
+		}
+		p.File.Path = path
+
+	case "providers":
+		var providers []string
+		for _, member := range strings.Split(value, ",") {
+			if member = strings.TrimSpace(member); member != "" {
+				providers = append(providers, member)
+			}
+		}
+		if _, err := cfg.Members(providers); err != nil {
+			return err
+		}
+		switch p.Type {
+		case config.ProviderFailover:
+			p.Failover = &config.FailoverConfig{Providers: providers}
+		case config.ProviderPool:
+			if p.Pool == nil {
+				return fmt.Errorf("pool config missing for %q", name)
+			}
+			// Keep the weights and limits of members that stay.
+			members := make([]config.PoolMember, 0, len(providers))
+			for _, memberName := range providers {
+				member := config.PoolMember{Provider: memberName}
+				for _, old := range p.Pool.Providers {
+					if old.Provider == memberName {
+						member = old
+					}
+				}
+				members = append(members, member)
+			}
+			p.Pool.Providers = members
+		default:
+			return fmt.Errorf("key %q only valid for failover and pool providers", key)
+		}
+
+	case "strategy":
+		if p.Type != config.ProviderPool {
+			return fmt.Errorf("key %q only valid for pool provider", key)
+		}
+		if p.Pool == nil {
+			return fmt.Errorf("pool config missing for %q", name)
+		}
+		switch value {
+		case provider.PoolRoundRobin, provider.PoolWeighted, provider.PoolQuota:
+		default:
+			return fmt.Errorf("invalid strategy %q: must be round-robin, weighted, or quota", value)
+		}
+		p.Pool.Strategy = value
+
+	case "weight", "daily-limit":
+		if p.Type != config.ProviderPool {
+			return fmt.Errorf("key %q only valid for pool provider", key)
+		}
+		if p.Pool == nil {
+			return fmt.Errorf("pool config missing for %q", name)
+		}
+		if err := setPoolMember(p.Pool, key, value); err != nil {
+			return err
+		}
+
+	case "socket":
+		if p.Type != config.ProviderLMTP {
+			return fmt.Errorf("key %q only valid for LMTP provider", key)
+		}
+		if p.LMTP == nil {
+			return fmt.Errorf("lmtp config missing for %q", name)
+		}
+		p.LMTP.Socket = value
+
 	case "client-id":
-		if p.Type != config.ProviderGoogle {
-			return fmt.Errorf("key %q only valid for Google provider", key)
+		switch p.Type {
+		case config.ProviderGoogle:
+			if p.Google == nil {
+				return fmt.Errorf("google config missing for %q", name)
+			}
+			p.Google.ClientID = value
+		case config.ProviderMicrosoft:
+			if p.Microsoft == nil {
+				return fmt.Errorf("microsoft config missing for %q", name)
+			}
+			p.Microsoft.ClientID = value
+		default:
+			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
-		if p.Google == nil {
-			return fmt.Errorf("google config missing for %q", name)
+
+	case "tenant":
+		if p.Type != config.ProviderMicrosoft {
+			return fmt.Errorf("key %q only valid for Microsoft provider", key)
 		}
-		p.Google.ClientID = value
+		if p.Microsoft == nil {
+			return fmt.Errorf("microsoft config missing for %q", name)
+		}
+		p.Microsoft.Tenant = value
 
 	case "client-secret":
 		if p.Type != config.ProviderGoogle {
@@ -175,53 +554,172 @@ func runConfigSet(c *cli.Context) error {
 		}
 
 	case "access-token":
-		if p.Type != config.ProviderGoogle {
-			return fmt.Errorf("key %q only valid for Google provider", key)
-		}
-		if p.Google == nil {
-			return fmt.Errorf("google config missing for %q", name)
+		var field *string
+		switch p.Type {
+		case config.ProviderGoogle:
+			if p.Google == nil {
+				return fmt.Errorf("google config missing for %q", name)
+			}
+			field = &p.Google.AccessToken
+		case config.ProviderMicrosoft:
+			if p.Microsoft == nil {
+				return fmt.Errorf("microsoft config missing for %q", name)
+			}
+			field = &p.Microsoft.AccessToken
+		case config.ProviderJMAP:
+			if p.JMAP == nil {
+				return fmt.Errorf("jmap config missing for %q", name)
+			}
+			field = &p.JMAP.Token
+		default:
+			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
-		if useKeychain || keychain.IsKeychainRef(p.Google.AccessToken) {
+		if useKeychain || keychain.IsKeychainRef(*field) {
 			if err := keychain.Set(name+"/access-token", value); err != nil {
 				return fmt.Errorf("failed to store access token in keychain: %w", err)
 			}
-			p.Google.AccessToken = keychain.KeychainRef(name, "access-token")
+			*field = keychain.KeychainRef(name, "access-token")
 		} else {
-			p.Google.AccessToken = value
+			*field = value
 		}
 
 	case "refresh-token":
+		var field *string
+		switch p.Type {
+		case config.ProviderGoogle:
+			if p.Google == nil {
+				return fmt.Errorf("google config missing for %q", name)
+			}
+			field = &p.Google.RefreshToken
+		case config.ProviderMicrosoft:
+			if p.Microsoft == nil {
+				return fmt.Errorf("microsoft config missing for %q", name)
+			}
+			field = &p.Microsoft.RefreshToken
+		default:
+			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
+		}
+		if useKeychain || keychain.IsKeychainRef(*field) {
+			if err := keychain.Set(name+"/refresh-token", value); err != nil {
+				return fmt.Errorf("failed to store refresh token in keychain: %w", err)
+			}
+			*field = keychain.KeychainRef(name, "refresh-token")
+		} else {
+			*field = value
+		}
+
+	case "service-account-key":
 		if p.Type != config.ProviderGoogle {
 			return fmt.Errorf("key %q only valid for Google provider", key)
 		}
 		if p.Google == nil {
 			return fmt.Errorf("google config missing for %q", name)
 		}
-		if useKeychain || keychain.IsKeychainRef(p.Google.RefreshToken) {
-			if err := keychain.Set(name+"/refresh-token", value); err != nil {
-				return fmt.Errorf("failed to store refresh token in keychain: %w", err)
-			}
-			p.Google.RefreshToken = keychain.KeychainRef(name, "refresh-token")
-		} else {
-			p.Google.RefreshToken = value
+		if value == "" {
+			p.Google.ServiceAccountKey = ""
+			break
 		}
+		googleCfg, err := googleServiceAccountConfig(name, value, p.Google.Subject,
+			useKeychain || keychain.IsKeychainRef(p.Google.ServiceAccountKey))
+		if err != nil {
+			return err
+		}
+		p.Google.ServiceAccountKey = googleCfg.ServiceAccountKey
 
-	case "api-key":
-		if p.Type != config.ProviderAgentMail {
-			return fmt.Errorf("key %q only valid for AgentMail provider", key)
+	case "subject":
+		if p.Type != config.ProviderGoogle {
+			return fmt.Errorf("key %q only valid for Google provider", key)
 		}
-		if p.AgentMail == nil {
-			return fmt.Errorf("agentmail config missing for %q", name)
+		if p.Google == nil {
+			return fmt.Errorf("google config missing for %q", name)
+		}
+		p.Google.Subject = value
+
+	case "api-key":
+		var field *string
+		switch p.Type {
+		case config.ProviderAgentMail:
+			if p.AgentMail == nil {
+				return fmt.Errorf("agentmail config missing for %q", name)
+			}
+			field = &p.AgentMail.APIKey
+		case config.ProviderSendGrid:
+			if p.SendGrid == nil {
+				return fmt.Errorf("sendgrid config missing for %q", name)
+			}
+			field = &p.SendGrid.APIKey
+		case config.ProviderMailgun:
+			if p.Mailgun == nil {
+				return fmt.Errorf("mailgun config missing for %q", name)
+			}
+			field = &p.Mailgun.APIKey
+		case config.ProviderPostmark:
+			if p.Postmark == nil {
+				return fmt.Errorf("postmark config missing for %q", name)
+			}
+			field = &p.Postmark.ServerToken
+		case config.ProviderResend:
+			if p.Resend == nil {
+				return fmt.Errorf("resend config missing for %q", name)
+			}
+			field = &p.Resend.APIKey
+		default:
+			return fmt.Errorf("key %q not valid for provider type %s", key, p.Type)
 		}
-		if useKeychain || keychain.IsKeychainRef(p.AgentMail.APIKey) {
+		if useKeychain || keychain.IsKeychainRef(*field) {
 			if err := keychain.Set(name+"/api-key", value); err != nil {
 				return fmt.Errorf("failed to store API key in keychain: %w", err)
 			}
-			p.AgentMail.APIKey = keychain.KeychainRef(name, "api-key")
+			*field = keychain.KeychainRef(name, "api-key")
 		} else {
-			p.AgentMail.APIKey = value
+			*field = value
 		}
 
+	case "domain":
+		if p.Type != config.ProviderMailgun {
+			return fmt.Errorf("key %q only valid for Mailgun provider", key)
+		}
+		if p.Mailgun == nil {
+			return fmt.Errorf("mailgun config missing for %q", name)
+		}
+		p.Mailgun.Domain = value
+
+	case "url":
+		switch p.Type {
+		case config.ProviderJMAP:
+			if p.JMAP == nil {
+				return fmt.Errorf("jmap config missing for %q", name)
+			}
+			p.JMAP.URL = value
+		case config.ProviderWebhook:
+			if p.Webhook == nil {
+				return fmt.Errorf("webhook config missing for %q", name)
+			}
+			p.Webhook.URL = value
+		default:
+			return fmt.Errorf("key %q only valid for JMAP and webhook providers", key)
+		}
+
+	case "format", "content-type", "secret", "signature-header", "template-file", "header":
+		if p.Type != config.ProviderWebhook {
+			return fmt.Errorf("key %q only valid for webhook provider", key)
+		}
+		if p.Webhook == nil {
+			return fmt.Errorf("webhook config missing for %q", name)
+		}
+		if err := setWebhookKey(p.Webhook, name, key, value, useKeychain); err != nil {
+			return err
+		}
+
+	case "message-stream":
+		if p.Type != config.ProviderPostmark {
+			return fmt.Errorf("key %q only valid for Postmark provider", key)
+		}
+		if p.Postmark == nil {
+			return fmt.Errorf("postmark config missing for %q", name)
+		}
+		p.Postmark.MessageStream = value
+
 	case "inbox-id":
 		if p.Type != config.ProviderAgentMail {
 			return fmt.Errorf("key %q only valid for AgentMail provider", key)
@@ -236,11 +734,58 @@ func runConfigSet(c *cli.Context) error {
 	}
 
 	cfg.Providers[name] = p
+	return nil
+}
 
-	if err := cfg.Save(); err != nil {
-		return err
+func setWebhookKey(w *config.WebhookConfig, name, key, value string, useKeychain bool) error {
+	switch key {
+	case "format":
+		if value != "" && value != "json" && value != "multipart" {
+			return fmt.Errorf("invalid format %q: must be json or multipart", value)
+		}
+		w.Format = value
+	case "content-type":
+		if value != "" {
+			if _, _, err := mime.ParseMediaType(value); err != nil {
+				return fmt.Errorf("invalid content type %q: %w", value, err)
+			}
+		}
+		w.ContentType = value
+	case "secret":
+		if value != "" && (useKeychain || keychain.IsKeychainRef(w.Secret)) {
+			if err := keychain.Set(name+"/secret", value); err != nil {
+				return fmt.Errorf("failed to store webhook secret in keychain: %w", err)
+			}
+			value = keychain.KeychainRef(name, "secret")
+		}
+		w.Secret = value
+	case "signature-header":
+		w.SignatureHeader = value
+	case "template-file":
+		if value == "" {
+			w.Template = ""
+			return nil
+		}
+		data, err := os.ReadFile(value)
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		w.Template = string(data)
+	case "header":
+		headers, err := parseWebhookHeaders([]string{value})
+		if err != nil {
+			return err
+		}
+		for h, v := range headers {
+			if v == "" {
+				delete(w.Headers, h)
+				continue
+			}
+			if w.Headers == nil {
+				w.Headers = make(map[string]string)
+			}
+			w.Headers[h] = v
+		}
 	}
-
-	fmt.Printf("Updated %s.%s\n", name, key)
 	return nil
 }
@@ -3,6 +3,7 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/tnm/email-cli/internal/config"
 	"github.com/urfave/cli/v2"
@@ -61,6 +62,7 @@ func redactConfig(cfg *config.Config) *config.Config {
 	redacted := &config.Config{
 		DefaultProvider: cfg.DefaultProvider,
 		Providers:       make(map[string]config.ProviderConfig, len(cfg.Providers)),
+		Routes:          cfg.Routes,
 	}
 	for name, providerCfg := range cfg.Providers {
 		redacted.Providers[name] = redactProviderConfig(providerCfg)
@@ -71,9 +73,22 @@ func redactConfig(cfg *config.Config) *config.Config {
 func redactProviderConfig(providerCfg config.ProviderConfig) config.ProviderConfig {
 	redacted := providerCfg
 
+	if redacted.Proxy != "" {
+		if u, err := url.Parse(redacted.Proxy); err == nil {
+			redacted.Proxy = u.Redacted()
+		}
+	}
+
 	if redacted.SMTP != nil {
 		smtpCfg := *redacted.SMTP
 		smtpCfg.Password = "[REDACTED]"
+		if smtpCfg.IMAP != nil {
+			imapCfg := *smtpCfg.IMAP
+			if imapCfg.Password != "" {
+				imapCfg.Password = "[REDACTED]"
+			}
+			smtpCfg.IMAP = &imapCfg
+		}
 		redacted.SMTP = &smtpCfg
 	}
 
@@ -95,6 +110,78 @@ func redactProviderConfig(providerCfg config.ProviderConfig) config.ProviderConf
 		redacted.Google = &googleCfg
 	}
 
+	if redacted.Microsoft != nil {
+		msCfg := *redacted.Microsoft
+		if msCfg.AccessToken != "" {
+			msCfg.AccessToken = "[REDACTED]"
+		}
+		if msCfg.RefreshToken != "" {
+			msCfg.RefreshToken = "[REDACTED]"
+		}
+		redacted.Microsoft = &msCfg
+	}
+
+	if redacted.SES != nil {
+		sesCfg := *redacted.SES
+		if sesCfg.SecretAccessKey != "" {
+			sesCfg.SecretAccessKey = "[REDACTED]"
+		}
+		if sesCfg.SessionToken != "" {
+			sesCfg.SessionToken = "[REDACTED]"
+		}
+		redacted.SES = &sesCfg
+	}
+
+	if redacted.SendGrid != nil {
+		sendGridCfg := *redacted.SendGrid
+		sendGridCfg.APIKey = "[REDACTED]"
+		redacted.SendGrid = &sendGridCfg
+	}
+
+	if redacted.Mailgun != nil {
+		mailgunCfg := *redacted.Mailgun
+		mailgunCfg.APIKey = "[REDACTED]"
+		redacted.Mailgun = &mailgunCfg
+	}
+
+	if redacted.Postmark != nil {
+		postmarkCfg := *redacted.Postmark
+		postmarkCfg.ServerToken = "[REDACTED]"
+		redacted.Postmark = &postmarkCfg
+	}
+
+	if redacted.Resend != nil {
+		resendCfg := *redacted.Resend
+		resendCfg.APIKey = "[REDACTED]"
+		redacted.Resend = &resendCfg
+	}
+
+	if redacted.JMAP != nil {
+		jmapCfg := *redacted.JMAP
+		if jmapCfg.Password != "" {
+			jmapCfg.Password = "[REDACTED]"
+		}
+		if jmapCfg.Token != "" {
+			jmapCfg.Token = "[REDACTED]"
+		}
+		redacted.JMAP = &jmapCfg
+	}
+
+	if redacted.Webhook != nil {
+		webhookCfg := *redacted.Webhook
+		if webhookCfg.Secret != "" {
+			webhookCfg.Secret = "[REDACTED]"
+		}
+		// Header values usually carry credentials (Authorization and the like).
+		if len(webhookCfg.Headers) > 0 {
+			webhookCfg.Headers = make(map[string]string, len(redacted.Webhook.Headers))
+			for name := range redacted.Webhook.Headers {
+				webhookCfg.Headers[name] = "[REDACTED]"
+			}
+		}
+		redacted.Webhook = &webhookCfg
+	}
+
 	if redacted.AgentMail != nil {
 		agentMailCfg := *redacted.AgentMail
 		agentMailCfg.APIKey = "[REDACTED]"
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/urfave/cli/v2"
+)
+
+func draftsCommand() *cli.Command {
+	providerFlag := &cli.StringFlag{Name: "provider", Aliases: []string{"p"}, Usage: "Provider to use (default: configured default)"}
+	timeoutFlag := &cli.DurationFlag{Name: "timeout", Usage: "Abort after this long (default: provider timeout, if configured)"}
+
+	return &cli.Command{
+		Name:  "drafts",
+		Usage: "List, send, and delete drafts",
+		Description: "Manage drafts saved with 'email-cli send --draft'.\n\n" +
+			"Drafts are supported by Google providers and by SMTP providers\n" +
+			"with an IMAP server configured (imap-host).\n\n" +
+			"Examples:\n" +
+			"  email-cli drafts list\n" +
+			"  email-cli drafts send r-123456789\n" +
+			"  email-cli drafts delete --provider work 42",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "list",
+				Usage: "List drafts, newest first",
+				Flags: []cli.Flag{
+					providerFlag,
+					timeoutFlag,
+					&cli.IntFlag{Name: "limit", Aliases: []string{"n"}, Value: 20, Usage: "Maximum number of drafts to show"},
+				},
+				Action: runDraftsList,
+			},
+			{
+				Name:      "send",
+				Usage:     "Send a draft",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{providerFlag, timeoutFlag},
+				Action:    runDraftsSend,
+			},
+			{
+				Name:      "delete",
+				Usage:     "Delete a draft",
+				ArgsUsage: "<id>",
+				Flags:     []cli.Flag{providerFlag, timeoutFlag},
+				Action:    runDraftsDelete,
+			},
+		},
+	}
+}
+
+// drafter returns the provider selected by --provider, if it supports drafts.
+func drafter(c *cli.Context) (provider.Drafter, *config.ProviderConfig, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to load config: %w", err)
+	}
+	providerCfg, err := cfg.GetProvider(c.String("provider"))
+	if err != nil {
+		return nil, nil, "", err
+	}
+	p, err := provider.New(providerCfg)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to create provider: %w", err)
+	}
+	d, ok := p.(provider.Drafter)
+	if !ok {
+		return nil, nil, "", fmt.Errorf("provider %s does not support drafts", p.Name())
+	}
+	return d, providerCfg, p.Name(), nil
+}
+
+func runDraftsList(c *cli.Context) error {
+	d, providerCfg, _, err := drafter(c)
+	if err != nil {
+		return err
+	}
+
+	var drafts []provider.Draft
+	err = withProviderContext(c.Context, providerCfg, c.Duration("timeout"), "list drafts", func(ctx context.Context) error {
+		var err error
+		drafts, err = d.ListDrafts(ctx, c.Int("limit"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(drafts) == 0 {
+		fmt.Println("No drafts.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTO\tSUBJECT\tDATE")
+	for _, draft := range drafts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", draft.ID, draft.To, draft.Subject, draft.Date)
+	}
+	return w.Flush()
+}
+
+func runDraftsSend(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("usage: email-cli drafts send <id>")
+	}
+	id := c.Args().First()
+
+	d, providerCfg, name, err := drafter(c)
+	if err != nil {
+		return err
+	}
+	err = withProviderContext(c.Context, providerCfg, c.Duration("timeout"), "send draft", func(ctx context.Context) error {
+		return d.SendDraft(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Draft %s sent via %s\n", id, name)
+	return nil
+}
+
+func runDraftsDelete(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("usage: email-cli drafts delete <id>")
+	}
+	id := c.Args().First()
+
+	d, providerCfg, _, err := drafter(c)
+	if err != nil {
+		return err
+	}
+	err = withProviderContext(c.Context, providerCfg, c.Duration("timeout"), "delete draft", func(ctx context.Context) error {
+		return d.DeleteDraft(ctx, id)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted draft %s\n", id)
+	return nil
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+)
+
+// printDryRun shows what sending email through p would do, without
+// sending it: a summary of the message, or with payload set, the request
+// the provider would make (or the MIME message, for providers that send
+// one).
+func printDryRun(w io.Writer, p provider.Provider, providerCfg *config.ProviderConfig, email *provider.Email, payload bool) error {
+	// Failover chains and pools are rehearsed with the member they would
+	// try first.
+	var via string
+	switch d := p.(type) {
+	case *provider.Failover:
+		var chain []string
+		for _, m := range d.Members() {
+			chain = append(chain, m.Name)
+		}
+		if len(chain) > 1 {
+			via = "Failover: " + strings.Join(chain, " -> ")
+		}
+		p, providerCfg = d.Members()[0].Provider, &providerCfg.Failover.Members[0]
+	case *provider.Pool:
+		next, err := d.Next()
+		if err != nil {
+			return err
+		}
+		for i := range providerCfg.Pool.Members {
+			if providerCfg.Pool.Members[i].Name == next.Name {
+				via = fmt.Sprintf("Pool:     %s is next in %s", next.Name, providerCfg.Name)
+				p, providerCfg = next.Provider, &providerCfg.Pool.Members[i]
+				break
+			}
+		}
+	}
+
+	defaultFrom := providerCfg.From
+	if providerCfg.AgentMail != nil {
+		// AgentMail always sends from the inbox.
+		defaultFrom = providerCfg.AgentMail.InboxID
+	}
+	d, err := provider.NewDryRun(defaultFrom, email)
+	if err != nil {
+		return err
+	}
+
+	// Build the provider's request even for a summary, so its own checks
+	// (such as missing capabilities or template errors) are reported.
+	var preview *provider.Preview
+	if previewer, ok := p.(provider.Previewer); ok {
+		if preview, err = previewer.Preview(email); err != nil {
+			return err
+		}
+	}
+
+	if payload {
+		if preview == nil {
+			if converts(providerCfg.Type) {
+				_, _ = fmt.Fprintf(w, "# %s converts this message to its own API request.\n", p.Name())
+			}
+			_, err := w.Write(bytes.ReplaceAll(d.Message, []byte("\r\n"), []byte("\n")))
+			return err
+		}
+		printPreview(w, preview)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(w, "Dry run: nothing was sent.\n\n")
+	_, _ = fmt.Fprintf(w, "Provider: %s (%s)\n", providerCfg.Name, providerCfg.Type)
+	if via != "" {
+		_, _ = fmt.Fprintln(w, via)
+	}
+	if preview != nil {
+		_, _ = fmt.Fprintf(w, "Request:  %s\n", preview.Request)
+	}
+	_, _ = fmt.Fprintf(w, "From:     %s\n", d.From)
+	for _, field := range []struct {
+		name  string
+		addrs []string
+	}{{"To", d.To}, {"Cc", d.Cc}, {"Bcc", d.Bcc}} {
+		if len(field.addrs) > 0 {
+			_, _ = fmt.Fprintf(w, "%-9s %s\n", field.name+":", strings.Join(field.addrs, ", "))
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nHeaders:\n")
+	for _, h := range d.Header {
+		_, _ = fmt.Fprintf(w, "  %s: %s\n", h.Name, h.Value)
+	}
+
+	_, _ = fmt.Fprintf(w, "\nParts:\n")
+	for _, part := range d.Parts {
+		line := strings.Repeat("  ", part.Depth+1) + part.ContentType
+		if part.Filename != "" {
+			line += fmt.Sprintf(" %q", part.Filename)
+		}
+		_, _ = fmt.Fprintf(w, "%s (%s)\n", line, formatSize(part.Size))
+	}
+
+	_, _ = fmt.Fprintf(w, "\nMessage size: %s\n", formatSize(len(d.Message)))
+	if preview != nil {
+		_, _ = fmt.Fprintf(w, "Request size: %s\n", formatSize(len(preview.Body)))
+	}
+	return nil
+}
+
+// converts reports whether a provider type turns the MIME message into
+// a request of its own, with no preview of it.
+func converts(t config.ProviderType) bool {
+	switch t {
+	case config.ProviderMicrosoft, config.ProviderAgentMail:
+		return true
+	}
+	return false
+}
+
+func printPreview(w io.Writer, preview *provider.Preview) {
+	_, _ = fmt.Fprintln(w, preview.Request)
+	names := make([]string, 0, len(preview.Header))
+	for name := range preview.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range preview.Header[name] {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", name, value)
+		}
+	}
+	_, _ = fmt.Fprintln(w)
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, preview.Body, "", "  ") == nil {
+		_, _ = fmt.Fprintln(w, indented.String())
+		return
+	}
+	_, _ = w.Write(preview.Body)
+	if !bytes.HasSuffix(preview.Body, []byte("\n")) {
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+func formatSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
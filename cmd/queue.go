@@ -0,0 +1,474 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/tnm/email-cli/internal/queue"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	// defaultQueueTimeout bounds queued sends through providers with no
+	// timeout configured, so a hung send cannot hold an email forever.
+	defaultQueueTimeout = 5 * time.Minute
+	// queueLeaseGrace is how long after its timeout a sending email is
+	// assumed to belong to a queue run that crashed.
+	queueLeaseGrace = time.Minute
+)
+
+func queueCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "queue",
+		Usage: "Deliver, list, retry and purge queued emails",
+		Description: "Manage the outbox of emails saved with 'email-cli send --queue'.\n\n" +
+			"queue run sends the emails that are due. Those that fail with a\n" +
+			"transient error, such as the network being down, are tried again\n" +
+			"later, waiting a minute and then twice as long after each failure,\n" +
+			"up to an hour. Run it from cron, or keep it running with --watch.\n\n" +
+			"Examples:\n" +
+			"  email-cli send --to user@example.com --subject \"Report\" --body \"Done\" --queue\n" +
+			"  email-cli queue run\n" +
+			"  email-cli queue run --watch --interval 30s\n" +
+			"  email-cli queue list\n" +
+			"  email-cli queue retry --all\n" +
+			"  email-cli queue purge 20260301-120000-9f86d081",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "run",
+				Usage: "Send the queued emails that are due",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "watch", Aliases: []string{"w"}, Usage: "Keep running, sending emails as they are queued and come due"},
+					&cli.DurationFlag{Name: "interval", Value: time.Minute, Usage: "With --watch, how often to check for new emails"},
+					&cli.DurationFlag{Name: "max-age", Value: 48 * time.Hour, Usage: "Stop retrying emails queued longer ago than this"},
+				},
+				Action: runQueueRun,
+			},
+			{
+				Name:   "list",
+				Usage:  "List queued emails, oldest first",
+				Action: runQueueList,
+			},
+			{
+				Name:      "show",
+				Usage:     "Show a queued email",
+				ArgsUsage: "<id>",
+				Action:    runQueueShow,
+			},
+			{
+				Name:      "retry",
+				Usage:     "Send failed or waiting emails at the next queue run",
+				ArgsUsage: "<id>...",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "all", Usage: "Retry every failed or waiting email"},
+				},
+				Action: runQueueRetry,
+			},
+			{
+				Name:      "purge",
+				Usage:     "Remove emails from the queue without sending them",
+				ArgsUsage: "[id...]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "all", Usage: "Remove pending emails too, not just failed ones"},
+				},
+				Action: runQueuePurge,
+			},
+		},
+	}
+}
+
+// queueEmail saves each target's email to the outbox instead of sending
+// it, along with the timeout and retry settings to send it with.
+func queueEmail(targets []sendTarget, timeout time.Duration) error {
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		entryTimeout := timeout
+		if entryTimeout == 0 {
+			_, entryTimeout, err = target.providerCfg.Timeouts()
+			if err != nil {
+				return err
+			}
+		}
+		if entryTimeout == 0 {
+			entryTimeout = defaultQueueTimeout
+		}
+		e := &queue.Entry{
+			Provider:       target.providerCfg.Name,
+			Timeout:        entryTimeout,
+			Retries:        target.providerCfg.Retries,
+			RetryAmbiguous: target.providerCfg.RetryAmbiguous,
+			Email:          target.email,
+		}
+		if err := q.Add(e); err != nil {
+			return err
+		}
+		if len(targets) == 1 {
+			fmt.Printf("Email queued as %s\n", e.ID)
+		} else {
+			fmt.Printf("Email queued as %s for %s to %s\n", e.ID, e.Provider, target.recipientList())
+		}
+	}
+	fmt.Println("Deliver with: email-cli queue run")
+	return nil
+}
+
+func runQueueRun(c *cli.Context) error {
+	if c.Args().Len() > 0 {
+		return fmt.Errorf("usage: email-cli queue run [--watch]")
+	}
+	if c.Duration("interval") <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(c.Context, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !c.Bool("watch") {
+		sent, failed, err := deliverQueue(ctx, q, c.Duration("max-age"))
+		if err != nil {
+			return err
+		}
+		if sent == 0 && failed == 0 && ctx.Err() == nil {
+			fmt.Println(queueSummary(q))
+		}
+		switch {
+		case failed == 1:
+			return fmt.Errorf("1 queued email failed (see email-cli queue list)")
+		case failed > 1:
+			return fmt.Errorf("%d queued emails failed (see email-cli queue list)", failed)
+		}
+		return nil
+	}
+
+	fmt.Println("Watching the queue (Ctrl-C to stop)")
+	for {
+		// Keep watching through errors such as an unreadable config,
+		// which may well be fixed by the next check.
+		if _, _, err := deliverQueue(ctx, q, c.Duration("max-age")); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		wait := c.Duration("interval")
+		if next, ok := nextAttempt(q); ok {
+			wait = min(wait, max(time.Until(next), time.Second))
+		}
+		if err := sleepUntilDone(ctx, wait); err != nil {
+			return nil
+		}
+	}
+}
+
+// deliverQueue sends every queued email that is due, returning how many
+// were sent and how many failed for good. It stops early if ctx is done.
+func deliverQueue(ctx context.Context, q *queue.Queue, maxAge time.Duration) (sent, failed int, err error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to load config: %w", err)
+	}
+	for ctx.Err() == nil {
+		e, err := q.Claim(queueLeaseGrace)
+		if err != nil {
+			return sent, failed, err
+		}
+		if e == nil {
+			break
+		}
+
+		via, sendErr := sendQueued(ctx, cfg, e)
+		if sendErr == nil {
+			if err := q.Remove(e.ID); err != nil {
+				return sent, failed, err
+			}
+			fmt.Printf("Sent %s via %s to %s\n", e.ID, via, queuedRecipients(e))
+			sent++
+			continue
+		}
+
+		now := time.Now()
+		e.LastError = sendErr.Error()
+		kind := provider.Classify(sendErr)
+		retryable := kind == provider.KindTransient || kind == provider.KindRateLimited
+		mayDuplicate := provider.IsAmbiguous(sendErr) && !e.RetryAmbiguous
+		switch {
+		case errors.Is(sendErr, context.Canceled) && ctx.Err() != nil:
+			// Interrupted: put it back, unless it may have been sent.
+			if mayDuplicate {
+				e.Status = queue.StatusFailed
+				e.LastError = "interrupted while sending; it may have been delivered"
+			} else {
+				e.Status = queue.StatusPending
+				e.NextAttempt = now
+			}
+		case retryable && !mayDuplicate:
+			wait := queue.Backoff(e.Attempts)
+			if after, ok := provider.RetryAfter(sendErr); ok {
+				wait = max(wait, after)
+			}
+			if now.Add(wait).Sub(e.Created) > maxAge {
+				e.Status = queue.StatusFailed
+				e.LastError += fmt.Sprintf(" (giving up after %s)", formatWait(now.Sub(e.Created).Round(time.Second)))
+				break
+			}
+			e.Status = queue.StatusPending
+			e.NextAttempt = now.Add(wait)
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s: %v; retrying in %s\n", e.ID, sendErr, formatWait(wait))
+		default:
+			e.Status = queue.StatusFailed
+		}
+		if e.Status == queue.StatusFailed {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s failed: %s\n", e.ID, e.LastError)
+			failed++
+		}
+		if err := q.Save(e); err != nil {
+			return sent, failed, err
+		}
+	}
+	return sent, failed, nil
+}
+
+// sendQueued sends a queued email through the provider it was queued for,
+// returning the name of the provider that delivered it.
+func sendQueued(ctx context.Context, cfg *config.Config, e *queue.Entry) (string, error) {
+	providerCfg, err := cfg.GetProvider(e.Provider)
+	if err != nil {
+		return "", err
+	}
+	providerCfg.Retries = e.Retries
+	providerCfg.RetryAmbiguous = e.RetryAmbiguous
+	p, err := provider.New(providerCfg)
+	if err != nil {
+		return "", fmt.Errorf("failed to create provider: %w", err)
+	}
+	if err := sendEmail(ctx, p, providerCfg, e.Timeout, e.Email); err != nil {
+		return "", err
+	}
+	return deliveredBy(p), nil
+}
+
+func runQueueList(c *cli.Context) error {
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+	entries, err := q.List()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		fmt.Println("The queue is empty.")
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSTATUS\tATTEMPTS\tPROVIDER\tTO\tSUBJECT")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", e.ID, queueStatus(e, now), e.Attempts, e.Provider, queuedRecipients(e), queuedSubject(e))
+	}
+	return w.Flush()
+}
+
+func runQueueShow(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return fmt.Errorf("usage: email-cli queue show <id>")
+	}
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+	e, err := q.Get(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	fmt.Printf("ID:        %s\n", e.ID)
+	fmt.Printf("Queued:    %s\n", e.Created.Local().Format("2006-01-02 15:04:05 MST"))
+	fmt.Printf("Status:    %s\n", queueStatus(e, now))
+	fmt.Printf("Attempts:  %d\n", e.Attempts)
+	if e.LastError != "" {
+		fmt.Printf("Error:     %s\n", e.LastError)
+	}
+	fmt.Printf("Provider:  %s\n", e.Provider)
+	if e.Email.From != "" {
+		fmt.Printf("From:      %s\n", e.Email.From)
+	}
+	for _, field := range []struct {
+		name  string
+		addrs []string
+	}{{"To", e.Email.To}, {"Cc", e.Email.Cc}, {"Bcc", e.Email.Bcc}} {
+		if len(field.addrs) > 0 {
+			fmt.Printf("%-10s %s\n", field.name+":", strings.Join(field.addrs, ", "))
+		}
+	}
+	fmt.Printf("Subject:   %s\n", queuedSubject(e))
+	for _, att := range e.Email.Attachments {
+		fmt.Printf("Attached:  %s (%d bytes)\n", att.Filename, len(att.Content))
+	}
+	return nil
+}
+
+func runQueueRetry(c *cli.Context) error {
+	match, err := queueMatch(c, "retry")
+	if err != nil {
+		return err
+	}
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+	retried, err := q.Retry(match)
+	if err != nil {
+		return err
+	}
+	if len(retried) == 0 {
+		fmt.Println("No emails to retry.")
+		return nil
+	}
+	for _, e := range retried {
+		fmt.Printf("Will retry %s at the next queue run\n", e.ID)
+	}
+	return nil
+}
+
+func runQueuePurge(c *cli.Context) error {
+	match := func(*queue.Entry) bool { return true }
+	if c.Args().Len() > 0 {
+		var err error
+		if match, err = queueMatch(c, "purge"); err != nil {
+			return err
+		}
+	}
+	q, err := queue.Open()
+	if err != nil {
+		return err
+	}
+	// Naming emails purges them whatever their status; otherwise only
+	// failed ones go, unless --all.
+	purged, err := q.Purge(func(e *queue.Entry) bool {
+		if c.Args().Len() == 0 && !c.Bool("all") && e.Status != queue.StatusFailed {
+			return false
+		}
+		return match(e)
+	})
+	if err != nil {
+		return err
+	}
+	if len(purged) == 0 {
+		fmt.Println("Nothing to purge.")
+		return nil
+	}
+	for _, e := range purged {
+		fmt.Printf("Removed %s\n", e.ID)
+	}
+	return nil
+}
+
+// queueMatch matches the emails named in the arguments, or with --all,
+// every email. The named emails must exist.
+func queueMatch(c *cli.Context, action string) (func(*queue.Entry) bool, error) {
+	if c.Bool("all") {
+		if c.Args().Len() > 0 {
+			return nil, fmt.Errorf("give queue IDs or --all, not both")
+		}
+		return func(*queue.Entry) bool { return true }, nil
+	}
+	if c.Args().Len() == 0 {
+		return nil, fmt.Errorf("usage: email-cli queue %s <id>... (or --all)", action)
+	}
+	q, err := queue.Open()
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, id := range c.Args().Slice() {
+		if _, err := q.Get(id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+	return func(e *queue.Entry) bool { return ids[e.ID] }, nil
+}
+
+// queueStatus describes an entry's status for list and show.
+func queueStatus(e *queue.Entry, now time.Time) string {
+	if e.Status == queue.StatusPending && e.NextAttempt.After(now) {
+		return "retry in " + formatWait(e.NextAttempt.Sub(now).Round(time.Second))
+	}
+	return string(e.Status)
+}
+
+// queueSummary describes a queue with nothing due.
+func queueSummary(q *queue.Queue) string {
+	summary := "No queued emails to send."
+	if next, ok := nextAttempt(q); ok {
+		summary = "No queued emails are due; the next retry is in " + formatWait(time.Until(next).Round(time.Second)) + "."
+	}
+	entries, _ := q.List()
+	failed := 0
+	for _, e := range entries {
+		if e.Status == queue.StatusFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		summary += fmt.Sprintf(" %d failed (see email-cli queue list).", failed)
+	}
+	return summary
+}
+
+// nextAttempt returns when the next pending email is due.
+func nextAttempt(q *queue.Queue) (time.Time, bool) {
+	entries, err := q.List()
+	if err != nil {
+		return time.Time{}, false
+	}
+	var next time.Time
+	for _, e := range entries {
+		if e.Status == queue.StatusPending && (next.IsZero() || e.NextAttempt.Before(next)) {
+			next = e.NextAttempt
+		}
+	}
+	return next, !next.IsZero()
+}
+
+func queuedRecipients(e *queue.Entry) string {
+	addrs := append(append(append([]string(nil), e.Email.To...), e.Email.Cc...), e.Email.Bcc...)
+	if len(addrs) > 2 {
+		return fmt.Sprintf("%s and %d more", strings.Join(addrs[:2], ", "), len(addrs)-2)
+	}
+	return strings.Join(addrs, ", ")
+}
+
+func queuedSubject(e *queue.Entry) string {
+	if e.Email.Subject == "" && e.Email.Raw != nil {
+		return "(raw message)"
+	}
+	return e.Email.Subject
+}
+
+func sleepUntilDone(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
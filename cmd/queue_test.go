@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+)
+
+func TestWithProviderContext_TimeoutKeepsError(t *testing.T) {
+	providerCfg := &config.ProviderConfig{Name: "relay", Type: config.ProviderSMTP}
+	err := withProviderContext(context.Background(), providerCfg, 10*time.Millisecond, "send email", func(ctx context.Context) error {
+		<-ctx.Done()
+		return fmt.Errorf("read reply: %w", ctx.Err())
+	})
+	if err == nil || err.Error() != "failed to send email: timed out after 10ms" {
+		t.Fatalf("withProviderContext() error = %v", err)
+	}
+	// A queue run must still see a timeout as worth retrying.
+	if !provider.IsTransient(err) {
+		t.Errorf("timeout error is not transient: Classify() = %s", provider.Classify(err))
+	}
+}
+
+func TestWithProviderContext_DefaultTimeout(t *testing.T) {
+	// With no timeout configured, a stalled provider must still give up.
+	providerCfg := &config.ProviderConfig{Name: "inbox", Type: config.ProviderAgentMail}
+	err := withProviderContext(context.Background(), providerCfg, 0, "send email", func(ctx context.Context) error {
+		deadline, ok := ctx.Deadline()
+		if !ok {
+			return fmt.Errorf("no deadline")
+		}
+		if left := time.Until(deadline); left <= 0 || left > defaultTimeout {
+			return fmt.Errorf("deadline in %s, want within %s", left, defaultTimeout)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("withProviderContext() error = %v", err)
+	}
+}
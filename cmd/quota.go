@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/urfave/cli/v2"
+)
+
+func quotaCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "quota",
+		Usage:     "Show providers' rate limit and quota usage",
+		ArgsUsage: "[name]",
+		Description: "Show how much of each provider's rate limit, daily quota and monthly\n" +
+			"quota has been used, and when they reset. Pools show each member's\n" +
+			"sends today. Limits are set with config set, for example:\n\n" +
+			"  email-cli config set relay rate-limit 30/min\n" +
+			"  email-cli config set gmail daily-quota 500\n" +
+			"  email-cli config set agent monthly-quota 3000",
+		Action: runQuota,
+	}
+}
+
+func runQuota(c *cli.Context) error {
+	if c.Args().Len() > 1 {
+		return fmt.Errorf("usage: email-cli quota [name]")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	if name := c.Args().First(); name != "" {
+		p, ok := cfg.Providers[name]
+		if !ok {
+			return fmt.Errorf("provider %q not found", name)
+		}
+		if !p.HasLimits() && p.Type != config.ProviderPool {
+			fmt.Printf("%s has no rate limit or quotas. Set them with, for example:\n", name)
+			fmt.Printf("  email-cli config set %s daily-quota 500\n", name)
+			return nil
+		}
+		names = []string{name}
+	} else {
+		for name, p := range cfg.Providers {
+			if p.HasLimits() || p.Type == config.ProviderPool {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+	if len(names) == 0 {
+		fmt.Println("No rate limits, quotas or pools configured. Set limits with, for example:")
+		fmt.Println("  email-cli config set <name> daily-quota 500")
+		return nil
+	}
+
+	now := time.Now()
+	for i, name := range names {
+		if i > 0 {
+			fmt.Println()
+		}
+		p := cfg.Providers[name]
+		fmt.Printf("%s (%s)\n", name, p.Type)
+		if p.HasLimits() {
+			if err := printLimitStatus(&p, now); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if p.Type == config.ProviderPool {
+			if err := printPoolUsage(&p, now); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func printLimitStatus(p *config.ProviderConfig, now time.Time) error {
+	limiter, err := provider.LimiterFor(p)
+	if err != nil {
+		return err
+	}
+	status, err := limiter.Status()
+	if err != nil {
+		return err
+	}
+
+	if status.Rate > 0 {
+		available := "next send in " + formatWait(status.NextSend)
+		if status.Available > 0 {
+			available = fmt.Sprintf("%d available now", status.Available)
+		}
+		burst := ""
+		if status.Burst > 1 {
+			burst = fmt.Sprintf(" (bursts of %d)", status.Burst)
+		}
+		fmt.Printf("  Rate limit:  %s%s, %s\n", p.RateLimit, burst, available)
+	}
+	fmt.Printf("  Today:       %s, resets in %s (%s)\n", quotaUsage(status.Today, status.Daily), formatWait(status.DayReset.Sub(now)), status.DayReset.Format("2006-01-02 15:04 MST"))
+	fmt.Printf("  This month:  %s, resets in %s (%s)\n", quotaUsage(status.Month, status.Monthly), formatWait(status.MonthReset.Sub(now)), status.MonthReset.Format("2006-01-02 15:04 MST"))
+	return nil
+}
+
+func printPoolUsage(p *config.ProviderConfig, now time.Time) error {
+	if p.Pool == nil {
+		return fmt.Errorf("pool config missing")
+	}
+	usage, err := provider.PoolUsageFor(p)
+	if err != nil {
+		return err
+	}
+
+	width := 0
+	for _, m := range p.Pool.Providers {
+		width = max(width, len(m.Provider))
+	}
+	for _, m := range p.Pool.Providers {
+		sent := fmt.Sprintf("%d messages today", usage[m.Provider])
+		if m.DailyLimit > 0 {
+			sent = fmt.Sprintf("%d of %d messages today", usage[m.Provider], m.DailyLimit)
+		}
+		fmt.Printf("  %-*s  %s\n", width+1, m.Provider+":", sent)
+	}
+	reset := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	fmt.Printf("  Daily limits reset in %s\n", formatWait(reset.Sub(now)))
+	return nil
+}
+
+// quotaUsage describes used out of quota recipients; a zero quota is
+// unlimited.
+func quotaUsage(used, quota int) string {
+	if quota == 0 {
+		return fmt.Sprintf("%d recipients (no quota)", used)
+	}
+	return fmt.Sprintf("%d of %d recipients (%d left)", used, quota, max(quota-used, 0))
+}
+
+// formatWait rounds d for display: 1.5s, 12m, 5h12m or 13d.
+func formatWait(d time.Duration) string {
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Minute:
+		s := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+		if strings.HasSuffix(s, "h0m") {
+			s = strings.TrimSuffix(s, "0m")
+		}
+		return s
+	default:
+		return d.Round(100 * time.Millisecond).String()
+	}
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/mail"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+)
+
+// sendTarget is one provider a send goes through, and the email it is
+// given.
+type sendTarget struct {
+	providerCfg *config.ProviderConfig
+	email       *provider.Email
+}
+
+// routeEmail picks the providers to send email through: the named one, or
+// with no name, those the configured routes pick for its recipients.
+// When routes split the recipients between providers, each is given a
+// copy of email sent to its own recipients only. The copy keeps the
+// original To and Cc headers, so replies reach everyone, wherever the
+// provider delivers to an envelope apart from the headers.
+func routeEmail(cfg *config.Config, name string, email *provider.Email) ([]sendTarget, error) {
+	if name != "" || len(cfg.Routes) == 0 {
+		providerCfg, err := cfg.GetProvider(name)
+		if err != nil {
+			return nil, err
+		}
+		return []sendTarget{{providerCfg: providerCfg, email: email}}, nil
+	}
+
+	from, subject := email.From, email.Subject
+	if email.Raw != nil {
+		if msg, err := mail.ReadMessage(bytes.NewReader(email.Raw)); err == nil {
+			if from == "" {
+				from = msg.Header.Get("From")
+			}
+			subject, _ = new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+		}
+	}
+
+	var recipients []string
+	recipients = append(recipients, email.To...)
+	recipients = append(recipients, email.Cc...)
+	recipients = append(recipients, email.Bcc...)
+	routed, err := cfg.RouteRecipients(from, subject, recipients)
+	if err != nil {
+		return nil, err
+	}
+	if len(routed) == 1 {
+		return []sendTarget{{providerCfg: routed[0].Provider, email: email}}, nil
+	}
+
+	targets := make([]sendTarget, 0, len(routed))
+	for _, r := range routed {
+		split := *email
+		split.To = only(email.To, r.Recipients)
+		split.Cc = only(email.Cc, r.Recipients)
+		split.Bcc = only(email.Bcc, r.Recipients)
+		if email.Raw == nil {
+			if from, ok := envelopeSender(r.Provider, email.From); ok {
+				raw, err := provider.BuildMessage(from, email)
+				if err != nil {
+					return nil, err
+				}
+				split.Raw = raw
+			}
+		}
+		targets = append(targets, sendTarget{providerCfg: r.Provider, email: &split})
+	}
+	return targets, nil
+}
+
+// envelopeSender reports whether every provider behind providerCfg
+// delivers only to the envelope recipients it is given, whatever the To
+// and Cc headers say, and returns the From the message would be sent as.
+// Gmail, Microsoft Graph and most HTTP APIs deliver to every address in
+// the headers, so their copies can only list their own recipients.
+func envelopeSender(providerCfg *config.ProviderConfig, from string) (string, bool) {
+	members := []config.ProviderConfig{*providerCfg}
+	switch {
+	case providerCfg.Failover != nil:
+		members = providerCfg.Failover.Members
+	case providerCfg.Pool != nil:
+		members = providerCfg.Pool.Members
+	}
+	for _, member := range members {
+		switch member.Type {
+		case config.ProviderSMTP, config.ProviderProton, config.ProviderLMTP,
+			config.ProviderSES, config.ProviderMailgun, config.ProviderJMAP, config.ProviderFile:
+		default:
+			return "", false
+		}
+		// Without a From of its own, the message is from whichever
+		// member sends it, so it cannot be built beforehand.
+		if from == "" && member.From != members[0].From {
+			return "", false
+		}
+	}
+	if from == "" {
+		from = members[0].From
+	}
+	return from, from != ""
+}
+
+// only returns the addresses in addrs that are also in keep.
+func only(addrs, keep []string) []string {
+	var kept []string
+	for _, addr := range addrs {
+		for _, k := range keep {
+			if addr == k {
+				kept = append(kept, addr)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// recipientList describes a target's recipients for progress messages.
+func (t sendTarget) recipientList() string {
+	n := len(t.email.To) + len(t.email.Cc) + len(t.email.Bcc)
+	if n == 1 {
+		return "1 recipient"
+	}
+	return fmt.Sprintf("%d recipients", n)
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+)
+
+func TestRouteEmail_Splits(t *testing.T) {
+	cfg := &config.Config{
+		DefaultProvider: "agent",
+		Providers: map[string]config.ProviderConfig{
+			"agent": {Name: "agent", Type: config.ProviderAgentMail},
+			"relay": {Name: "relay", Type: config.ProviderSMTP, From: "me@ourcompany.com"},
+		},
+		Routes: []config.Route{{Domain: "ourcompany.com", Provider: "relay"}},
+	}
+	email := &provider.Email{
+		To:      []string{"alice@ourcompany.com", "bob@example.com"},
+		Cc:      []string{"carol@example.com"},
+		Bcc:     []string{"audit@ourcompany.com"},
+		Subject: "Hi",
+		Body:    "Hello",
+	}
+
+	targets, err := routeEmail(cfg, "", email)
+	if err != nil {
+		t.Fatalf("routeEmail() error = %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(targets))
+	}
+	relay, agent := targets[0], targets[1]
+	if relay.providerCfg.Name != "relay" || agent.providerCfg.Name != "agent" {
+		t.Fatalf("targets = %s, %s", relay.providerCfg.Name, agent.providerCfg.Name)
+	}
+	if !reflect.DeepEqual(relay.email.To, []string{"alice@ourcompany.com"}) || relay.email.Cc != nil ||
+		!reflect.DeepEqual(relay.email.Bcc, []string{"audit@ourcompany.com"}) {
+		t.Errorf("relay email to %v cc %v bcc %v", relay.email.To, relay.email.Cc, relay.email.Bcc)
+	}
+	if !reflect.DeepEqual(agent.email.To, []string{"bob@example.com"}) || !reflect.DeepEqual(agent.email.Cc, []string{"carol@example.com"}) {
+		t.Errorf("agent email to %v cc %v", agent.email.To, agent.email.Cc)
+	}
+	// SMTP delivers to the envelope, so the relay's copy keeps every
+	// recipient in its headers; AgentMail would deliver to them all.
+	raw := string(relay.email.Raw)
+	if !strings.Contains(raw, "To: alice@ourcompany.com, bob@example.com\r\n") || !strings.Contains(raw, "Cc: carol@example.com\r\n") ||
+		strings.Contains(raw, "audit@") {
+		t.Errorf("relay message headers lost recipients or leaked Bcc:\n%s", raw)
+	}
+	if agent.email.Raw != nil {
+		t.Errorf("agent email has a raw message")
+	}
+	if len(email.To) != 2 {
+		t.Errorf("routeEmail() modified the original email: %v", email.To)
+	}
+
+	// A named provider bypasses routes.
+	targets, err = routeEmail(cfg, "agent", email)
+	if err != nil || len(targets) != 1 || targets[0].email != email {
+		t.Fatalf("routeEmail(agent) = %v, %v", targets, err)
+	}
+}
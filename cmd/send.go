@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/tnm/email-cli/internal/config"
 	"github.com/tnm/email-cli/internal/provider"
@@ -25,8 +30,24 @@ func sendCommand() *cli.Command {
 			"  # Read body from stdin\n" +
 			"  echo \"Hello world\" | email-cli send --to user@example.com --subject \"Test\"\n\n" +
 			"  # Use specific provider\n" +
-			"  email-cli send --provider google --to user@example.com --subject \"Via Gmail\" --body \"Sent via Google\"",
+			"  email-cli send --provider google --to user@example.com --subject \"Via Gmail\" --body \"Sent via Google\"\n\n" +
+			"  # Give up if the provider does not respond within 30 seconds\n" +
+			"  email-cli send --to user@example.com --subject \"Ping\" --body \"Hi\" --timeout 30s\n\n" +
+			"  # Request delivery status notifications (SMTP only)\n" +
+			"  email-cli send --to user@example.com --subject \"Invoice\" --body \"Attached\" --dsn success,failure --dsn-ret hdrs\n\n" +
+			"  # Add a header and tag the message for provider analytics\n" +
+			"  email-cli send --to user@example.com --subject \"Digest\" --body \"...\" \\\n" +
+			"    --header \"List-Unsubscribe: <mailto:unsub@example.com>\" --tag digest\n\n" +
+			"  # Check what would be sent, without sending it\n" +
+			"  email-cli send --to user@example.com --subject \"Report\" --body \"See attached\" --attach report.pdf --dry-run\n\n" +
+			"  # Retry network failures and 5xx responses up to 3 times\n" +
+			"  email-cli send --to user@example.com --subject \"Report\" --body \"Done\" --retries 3\n\n" +
+			"  # Queue the email and return at once; email-cli queue run delivers it\n" +
+			"  email-cli send --to user@example.com --subject \"Report\" --body \"Done\" --queue\n\n" +
+			"  # Save a draft for a person to review and send later\n" +
+			"  email-cli send --to user@example.com --subject \"Proposal\" --body \"Draft text\" --draft",
 		Flags: []cli.Flag{
+			&cli.StringFlag{Name: "from", Aliases: []string{"f"}, Usage: "Sender address (default: the provider's from address)"},
 			&cli.StringSliceFlag{Name: "to", Aliases: []string{"t"}, Usage: "Recipient email addresses (repeatable)"},
 			&cli.StringSliceFlag{Name: "cc", Aliases: []string{"c"}, Usage: "CC recipients"},
 			&cli.StringSliceFlag{Name: "bcc", Aliases: []string{"b"}, Usage: "BCC recipients"},
@@ -34,13 +55,25 @@ func sendCommand() *cli.Command {
 			&cli.StringFlag{Name: "body", Aliases: []string{"m"}, Usage: "Email body (reads from stdin if not provided)"},
 			&cli.BoolFlag{Name: "html", Usage: "Treat body as HTML"},
 			&cli.StringSliceFlag{Name: "attach", Aliases: []string{"a"}, Usage: "File attachments (repeatable)"},
-			&cli.StringFlag{Name: "provider", Aliases: []string{"p"}, Usage: "Provider to use (default: configured default)"},
+			&cli.StringSliceFlag{Name: "header", Aliases: []string{"H"}, Usage: "Extra header as \"Name: value\" (repeatable)"},
+			&cli.StringSliceFlag{Name: "tag", Usage: "Tag for providers that support them: SendGrid, Mailgun, Postmark, Resend (repeatable)"},
+			&cli.StringFlag{Name: "provider", Aliases: []string{"p"}, Usage: "Provider to use, or a comma-separated list to fail over through in order (default: configured default)"},
+			&cli.StringFlag{Name: "dsn", Usage: "Request delivery status notifications: comma-separated success, failure, delay, or never (SMTP only)"},
+			&cli.StringFlag{Name: "dsn-ret", Usage: "DSN return content: full or hdrs (SMTP only)"},
+			&cli.DurationFlag{Name: "timeout", Usage: "Abort the send after this long, e.g. 30s or 2m (default: provider timeout, if configured)"},
+			&cli.IntFlag{Name: "retries", Usage: "Retry transient failures up to this many times, with backoff (default: provider retries, if configured)"},
+			&cli.BoolFlag{Name: "retry-ambiguous", Usage: "Also retry sends that may have been delivered, risking duplicates"},
+			&cli.BoolFlag{Name: "draft", Usage: "Save as a draft instead of sending (Google, or SMTP with an IMAP server)"},
+			&cli.BoolFlag{Name: "queue", Usage: "Save to the outbox and return at once; deliver with 'email-cli queue run'"},
+			&cli.BoolFlag{Name: "dry-run", Usage: "Validate and build the message, print a summary, and send nothing"},
+			&cli.BoolFlag{Name: "payload", Usage: "With --dry-run, print the full message or API request instead of a summary"},
 		},
 		Action: runSend,
 	}
 }
 
 func runSend(c *cli.Context) error {
+	sendFrom := c.String("from")
 	sendTo := c.StringSlice("to")
 	sendCc := c.StringSlice("cc")
 	sendBcc := c.StringSlice("bcc")
@@ -48,7 +81,15 @@ func runSend(c *cli.Context) error {
 	sendBody := c.String("body")
 	sendHTML := c.Bool("html")
 	sendAttachments := c.StringSlice("attach")
+	sendHeaders := c.StringSlice("header")
+	sendTags := c.StringSlice("tag")
 	sendProvider := c.String("provider")
+	sendDSN := c.String("dsn")
+	sendDSNRet := c.String("dsn-ret")
+	sendTimeout := c.Duration("timeout")
+	sendDraft := c.Bool("draft")
+	sendDryRun := c.Bool("dry-run")
+	sendQueue := c.Bool("queue")
 
 	if len(sendTo) == 0 {
 		return fmt.Errorf("--to is required")
@@ -56,20 +97,31 @@ func runSend(c *cli.Context) error {
 	if sendSubject == "" {
 		return fmt.Errorf("--subject is required")
 	}
-
-	cfg, err := config.Load()
-	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+	if sendDryRun && sendDraft {
+		return fmt.Errorf("--dry-run cannot be used with --draft")
+	}
+	if sendQueue && (sendDraft || sendDryRun) {
+		return fmt.Errorf("--queue cannot be used with --draft or --dry-run")
+	}
+	if c.Bool("payload") && !sendDryRun {
+		return fmt.Errorf("--payload requires --dry-run")
+	}
+	headers := make([]provider.Header, 0, len(sendHeaders))
+	for _, h := range sendHeaders {
+		header, err := provider.ParseHeader(h)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, header)
 	}
 
-	providerCfg, err := cfg.GetProvider(sendProvider)
-	if err != nil {
-		return err
+	if sendDraft && (sendDSN != "" || sendDSNRet != "") {
+		return fmt.Errorf("--dsn and --dsn-ret cannot be used with --draft")
 	}
 
-	p, err := provider.New(providerCfg)
+	cfg, err := config.Load()
 	if err != nil {
-		return fmt.Errorf("failed to create provider: %w", err)
+		return fmt.Errorf("failed to load config: %w", err)
 	}
 
 	// Read body from stdin if not provided.
@@ -94,6 +146,7 @@ func runSend(c *cli.Context) error {
 	}
 
 	email := &provider.Email{
+		From:        sendFrom,
 		To:          sendTo,
 		Cc:          sendCc,
 		Bcc:         sendBcc,
@@ -101,13 +154,234 @@ func runSend(c *cli.Context) error {
 		Body:        body,
 		HTML:        sendHTML,
 		Attachments: attachments,
+		Headers:     headers,
+		Tags:        sendTags,
+	}
+
+	if sendDraft {
+		// Drafts are saved in one mailbox, so they are never routed.
+		providerCfg, err := cfg.GetProvider(sendProvider)
+		if err != nil {
+			return err
+		}
+		p, err := provider.New(providerCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create provider: %w", err)
+		}
+		drafter, ok := p.(provider.Drafter)
+		if !ok {
+			return fmt.Errorf("provider %s does not support drafts", p.Name())
+		}
+		var id string
+		err = withProviderContext(c.Context, providerCfg, sendTimeout, "save draft", func(ctx context.Context) error {
+			var err error
+			id, err = drafter.CreateDraft(ctx, email)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if id == "" {
+			_, _ = fmt.Fprintf(os.Stdout, "Draft saved via %s\n", p.Name())
+		} else {
+			_, _ = fmt.Fprintf(os.Stdout, "Draft saved via %s: %s\n", p.Name(), id)
+		}
+		return nil
+	}
+
+	targets, err := routeEmail(cfg, sendProvider, email)
+	if err != nil {
+		return err
+	}
+	providers := make([]provider.Provider, len(targets))
+	for i, target := range targets {
+		target.email.DSN, err = newDSN(target.providerCfg, sendDSN, sendDSNRet, "")
+		if err != nil {
+			return err
+		}
+		if c.IsSet("retries") {
+			target.providerCfg.Retries = c.Int("retries")
+		}
+		if c.Bool("retry-ambiguous") {
+			target.providerCfg.RetryAmbiguous = true
+		}
+		providers[i], err = provider.New(target.providerCfg)
+		if err != nil {
+			return fmt.Errorf("failed to create provider: %w", err)
+		}
+	}
+
+	if sendQueue {
+		return queueEmail(targets, sendTimeout)
+	}
+
+	if sendDryRun {
+		for i, target := range targets {
+			if i > 0 {
+				_, _ = fmt.Fprintln(os.Stdout)
+			}
+			if err := printDryRun(os.Stdout, providers[i], target.providerCfg, target.email, c.Bool("payload")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(targets) == 1 {
+		if err := sendEmail(c.Context, providers[0], targets[0].providerCfg, sendTimeout, email); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Email sent successfully via %s\n", deliveredBy(providers[0]))
+		return nil
+	}
+
+	// Routes split the recipients between providers. A failure through
+	// one does not stop the others, but fails the command.
+	var failed []string
+	for i, target := range targets {
+		name := target.providerCfg.Name
+		if err := sendEmail(c.Context, providers[i], target.providerCfg, sendTimeout, target.email); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Error: %s to %s: %v\n", name, target.recipientList(), err)
+			failed = append(failed, name)
+			continue
+		}
+		via := fmt.Sprintf("%s (%s)", name, providers[i].Name())
+		if _, ok := providers[i].(provider.Delegator); ok {
+			via = deliveredBy(providers[i])
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "Email sent successfully via %s to %s\n", via, target.recipientList())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to send through %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// deliveredBy names the provider that delivered the last email sent
+// through p: for a failover chain or pool, the member that succeeded.
+func deliveredBy(p provider.Provider) string {
+	if d, ok := p.(provider.Delegator); ok {
+		if m, ok := d.Delivered(); ok {
+			return fmt.Sprintf("%s (%s)", m.Name, m.Provider.Name())
+		}
 	}
+	return p.Name()
+}
 
-	if err := p.Send(email); err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+// newDSN builds a DSN request from the --dsn style flags, or returns nil
+// when none were given.
+func newDSN(providerCfg *config.ProviderConfig, notify, ret, envID string) (*provider.DSN, error) {
+	if notify == "" && ret == "" && envID == "" {
+		return nil, nil
 	}
+	types := []config.ProviderType{providerCfg.Type}
+	var members []config.ProviderConfig
+	switch {
+	case providerCfg.Failover != nil:
+		members = providerCfg.Failover.Members
+	case providerCfg.Pool != nil:
+		members = providerCfg.Pool.Members
+	}
+	if members != nil {
+		types = types[:0]
+		for _, member := range members {
+			types = append(types, member.Type)
+		}
+	}
+	for _, t := range types {
+		switch t {
+		case config.ProviderSMTP, config.ProviderProton, config.ProviderLMTP:
+		default:
+			return nil, fmt.Errorf("delivery status notifications are only supported by SMTP, Proton, and LMTP providers, not %s", t)
+		}
+	}
+	return provider.NewDSN(strings.Split(notify, ","), ret, envID)
+}
 
-	_, _ = fmt.Fprintf(os.Stdout, "Email sent successfully via %s\n", p.Name())
+// sendEmail sends through p, bounded by timeout (or the provider's
+// configured timeout when zero) and cancelled by Ctrl-C or SIGTERM.
+func sendEmail(parent context.Context, p provider.Provider, providerCfg *config.ProviderConfig, timeout time.Duration, email *provider.Email) error {
+	onFailover := func(failed provider.Member, err error, next provider.Member) {
+		_, _ = fmt.Fprintf(os.Stderr, "%s failed (%v); trying %s\n", failed.Name, err, next.Name)
+	}
+	switch p := p.(type) {
+	case *provider.Failover:
+		if p.OnFailover == nil {
+			p.OnFailover = onFailover
+		}
+	case *provider.Pool:
+		if p.OnFailover == nil {
+			p.OnFailover = onFailover
+		}
+	}
+	limiter, err := provider.LimiterFor(providerCfg)
+	if err != nil {
+		return err
+	}
+	retrier, err := provider.RetrierFor(providerCfg)
+	if err != nil {
+		return err
+	}
+	retrier.OnRetry = func(attempt int, err error, wait time.Duration) {
+		_, _ = fmt.Fprintf(os.Stderr, "Attempt %d failed (%v); retrying in %s\n", attempt, err, formatWait(wait))
+	}
+	return withProviderContext(parent, providerCfg, timeout, "send email", func(ctx context.Context) error {
+		return retrier.Do(ctx, func(ctx context.Context) error {
+			return limiter.Limit(ctx, email, func() error {
+				return p.Send(ctx, email)
+			})
+		})
+	})
+}
+
+// defaultTimeout bounds provider requests when neither --timeout nor the
+// provider's timeout is set, so a stalled server cannot hang a send
+// forever. It is generous enough for large attachments on a slow link.
+const defaultTimeout = 5 * time.Minute
+
+// withProviderContext runs fn with a context bounded by timeout (or the
+// provider's configured timeout when zero, or defaultTimeout) and
+// cancelled by Ctrl-C or SIGTERM. action completes "failed to ..." in the
+// returned error.
+func withProviderContext(parent context.Context, providerCfg *config.ProviderConfig, timeout time.Duration, action string, fn func(context.Context) error) error {
+	if timeout == 0 {
+		var err error
+		_, timeout, err = providerCfg.Timeouts()
+		if err != nil {
+			return err
+		}
+	}
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	// Ctrl-C or SIGTERM cancels an in-flight request instead of killing it mid-transaction.
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := fn(ctx); err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return &abortedError{fmt.Sprintf("failed to %s: timed out after %s", action, timeout), ctx.Err(), err}
+		case context.Canceled:
+			return &abortedError{fmt.Sprintf("failed to %s: interrupted", action), ctx.Err(), err}
+		}
+		return fmt.Errorf("failed to %s: %w", action, err)
+	}
 	return nil
 }
 
+// abortedError is an action cut short by its timeout or an interrupt. Its
+// message leaves out the error the action ended with, which is mostly
+// noise about the cancelled context, but it still unwraps to it, so a
+// queue run can tell whether a send may have been delivered.
+type abortedError struct {
+	msg   string
+	cause error
+	err   error
+}
+
+func (e *abortedError) Error() string   { return e.msg }
+func (e *abortedError) Unwrap() []error { return []error{e.cause, e.err} }
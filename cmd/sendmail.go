@@ -0,0 +1,287 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/urfave/cli/v2"
+)
+
+func sendmailCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "sendmail",
+		Usage:     "Send a complete message from stdin, sendmail-style",
+		ArgsUsage: "[options] [recipient ...]",
+		Description: "Read an RFC 5322 message on stdin and deliver it through the default provider.\n" +
+			"Accepts the common sendmail options, so email-cli can stand in for\n" +
+			"/usr/sbin/sendmail. Invoking the binary as \"sendmail\" (e.g. via a symlink)\n" +
+			"runs this command directly.\n\n" +
+			"Options:\n" +
+			"  -t          Read recipients from the To, Cc, and Bcc headers (Bcc is removed)\n" +
+			"  -f ADDR     Envelope sender (also -r)\n" +
+			"  -F NAME     Sender full name, used if the message has no From header\n" +
+			"  -i, -oi     Do not treat a line with a single dot as the end of input\n" +
+			"  -N COND     DSN notify conditions: success, failure, delay, or never\n" +
+			"  -R RET      DSN return content: full or hdrs\n" +
+			"  -V ENVID    DSN envelope ID\n\n" +
+			"Other -o options and the no-op flags -v, -m, -n, -U, and -G are ignored.\n\n" +
+			"Examples:\n" +
+			"  # Recipients from the headers\n" +
+			"  email-cli sendmail -t -i < message.eml\n\n" +
+			"  # Explicit envelope recipients\n" +
+			"  email-cli sendmail -f ops@example.com user@example.com < message.eml\n\n" +
+			"  # Install as the system sendmail\n" +
+			"  ln -s \"$(which email-cli)\" /usr/sbin/sendmail",
+		SkipFlagParsing: true,
+		Action:          runSendmail,
+	}
+}
+
+type sendmailOptions struct {
+	extractRecipients bool
+	ignoreDots        bool
+	sender            string
+	fullName          string
+	dsnNotify         string
+	dsnRet            string
+	dsnEnvID          string
+	recipients        []string
+}
+
+// parseSendmailArgs parses sendmail's getopt-style command line. Option
+// values may be attached ("-fuser@example.com") or separate.
+func parseSendmailArgs(args []string) (*sendmailOptions, error) {
+	opts := &sendmailOptions{}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			opts.recipients = append(opts.recipients, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.recipients = append(opts.recipients, arg)
+			continue
+		}
+
+	flags:
+		for j := 1; j < len(arg); j++ {
+			opt := arg[j]
+			switch opt {
+			case 't':
+				opts.extractRecipients = true
+				continue
+			case 'i':
+				opts.ignoreDots = true
+				continue
+			case 'v', 'm', 'n', 'U', 'G':
+				continue
+			case 'B', 'C', 'F', 'L', 'N', 'O', 'R', 'V', 'X', 'b', 'f', 'h', 'o', 'p', 'q', 'r':
+			default:
+				return nil, fmt.Errorf("sendmail: unsupported option -%c", opt)
+			}
+
+			value := arg[j+1:]
+			if value == "" {
+				if i+1 >= len(args) {
+					return nil, fmt.Errorf("sendmail: option -%c requires a value", opt)
+				}
+				i++
+				value = args[i]
+			}
+
+			switch opt {
+			case 'f', 'r':
+				opts.sender = value
+			case 'F':
+				opts.fullName = value
+			case 'N':
+				opts.dsnNotify = value
+			case 'R':
+				opts.dsnRet = value
+			case 'V':
+				opts.dsnEnvID = value
+			case 'o':
+				if value == "i" {
+					opts.ignoreDots = true
+				}
+			case 'b':
+				if value != "m" {
+					return nil, fmt.Errorf("sendmail: mode -b%s is not supported", value)
+				}
+			}
+			break flags
+		}
+	}
+
+	return opts, nil
+}
+
+func runSendmail(c *cli.Context) error {
+	opts, err := parseSendmailArgs(c.Args().Slice())
+	if err != nil {
+		return err
+	}
+
+	raw, err := readSendmailMessage(os.Stdin, opts.ignoreDots)
+	if err != nil {
+		return fmt.Errorf("failed to read message: %w", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// The default provider's from address completes a message without a
+	// From header. With routes, there may be no default provider.
+	var defaultFrom string
+	if providerCfg, err := cfg.GetProvider(""); err == nil {
+		defaultFrom = providerCfg.From
+	} else if len(cfg.Routes) == 0 {
+		return err
+	}
+
+	raw, recipients, err := prepareSendmailMessage(raw, opts, defaultFrom, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("sendmail: no recipients (give them as arguments or use -t)")
+	}
+
+	targets, err := routeEmail(cfg, "", &provider.Email{
+		From: opts.sender,
+		To:   recipients,
+		Raw:  raw,
+	})
+	if err != nil {
+		return err
+	}
+	for _, target := range targets {
+		target.email.DSN, err = newDSN(target.providerCfg, opts.dsnNotify, opts.dsnRet, opts.dsnEnvID)
+		if err != nil {
+			return err
+		}
+	}
+	providers := make([]provider.Provider, len(targets))
+	for i, target := range targets {
+		if providers[i], err = provider.New(target.providerCfg); err != nil {
+			return fmt.Errorf("failed to create provider: %w", err)
+		}
+	}
+	if len(targets) == 1 {
+		return sendEmail(c.Context, providers[0], targets[0].providerCfg, 0, targets[0].email)
+	}
+
+	// As with send, a failure through one routed provider does not stop
+	// the others.
+	var errs []error
+	for i, target := range targets {
+		if err := sendEmail(c.Context, providers[i], target.providerCfg, 0, target.email); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", target.providerCfg.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readSendmailMessage reads the message from r. Unless ignoreDots is set,
+// a line consisting of a single "." ends the message, as in sendmail.
+func readSendmailMessage(r io.Reader, ignoreDots bool) ([]byte, error) {
+	if ignoreDots {
+		return io.ReadAll(r)
+	}
+
+	var buf bytes.Buffer
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if string(bytes.TrimRight(line, "\r\n")) == "." && len(line) > 1 {
+			return buf.Bytes(), nil
+		}
+		buf.Write(line)
+		if err == io.EOF {
+			return buf.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepareSendmailMessage resolves the envelope recipients and fills in the
+// headers sendmail would add. With -t, recipients come from the To, Cc and
+// Bcc headers plus any given as arguments. Either way the Bcc header is
+// removed, as sendmail does, so it never reaches the recipients.
+func prepareSendmailMessage(raw []byte, opts *sendmailOptions, defaultFrom string, now time.Time) ([]byte, []string, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	recipients := opts.recipients
+	if opts.extractRecipients {
+		for _, key := range []string{"To", "Cc", "Bcc"} {
+			if msg.Header.Get(key) == "" {
+				continue
+			}
+			list, err := msg.Header.AddressList(key)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s header: %w", key, err)
+			}
+			for _, addr := range list {
+				recipients = append(recipients, addr.Address)
+			}
+		}
+	}
+	recipients = dedupeAddresses(recipients)
+	raw = provider.RemoveHeader(raw, "Bcc")
+
+	newline := "\n"
+	if bytes.Contains(raw, []byte("\r\n")) {
+		newline = "\r\n"
+	}
+
+	var added strings.Builder
+	if msg.Header.Get("From") == "" {
+		from := opts.sender
+		if from == "" {
+			from = defaultFrom
+		}
+		if opts.fullName != "" {
+			from = (&mail.Address{Name: opts.fullName, Address: from}).String()
+		}
+		added.WriteString("From: " + from + newline)
+	}
+	if msg.Header.Get("Date") == "" {
+		added.WriteString("Date: " + now.Format(time.RFC1123Z) + newline)
+	}
+	if added.Len() > 0 {
+		raw = append([]byte(added.String()), raw...)
+	}
+
+	return raw, recipients, nil
+}
+
+func dedupeAddresses(addrs []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		key := strings.ToLower(strings.TrimSpace(addr))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, strings.TrimSpace(addr))
+	}
+	return out
+}
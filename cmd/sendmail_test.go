@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSendmailArgs(t *testing.T) {
+	opts, err := parseSendmailArgs([]string{"-t", "-oi", "-fops@example.com", "-F", "Ops Team", "-oem", "-N", "failure", "user@example.com", "--", "-odd@example.com"})
+	if err != nil {
+		t.Fatalf("parseSendmailArgs() error = %v", err)
+	}
+	want := &sendmailOptions{
+		extractRecipients: true,
+		ignoreDots:        true,
+		sender:            "ops@example.com",
+		fullName:          "Ops Team",
+		dsnNotify:         "failure",
+		recipients:        []string{"user@example.com", "-odd@example.com"},
+	}
+	if !reflect.DeepEqual(opts, want) {
+		t.Fatalf("parseSendmailArgs() = %+v, want %+v", opts, want)
+	}
+
+	opts, err = parseSendmailArgs([]string{"-ti", "-bm", "user@example.com"})
+	if err != nil {
+		t.Fatalf("parseSendmailArgs() error = %v", err)
+	}
+	if !opts.extractRecipients || !opts.ignoreDots {
+		t.Fatalf("grouped flags not parsed: %+v", opts)
+	}
+}
+
+func TestParseSendmailArgs_Errors(t *testing.T) {
+	for _, args := range [][]string{{"-bs"}, {"-f"}, {"-Z"}} {
+		if _, err := parseSendmailArgs(args); err == nil {
+			t.Fatalf("parseSendmailArgs(%q) error = nil, want error", args)
+		}
+	}
+}
+
+func TestReadSendmailMessage_Dots(t *testing.T) {
+	input := "Subject: x\n\nfirst\n.\nignored\n"
+
+	got, err := readSendmailMessage(strings.NewReader(input), false)
+	if err != nil {
+		t.Fatalf("readSendmailMessage() error = %v", err)
+	}
+	if string(got) != "Subject: x\n\nfirst\n" {
+		t.Fatalf("readSendmailMessage() = %q", got)
+	}
+
+	got, err = readSendmailMessage(strings.NewReader(input), true)
+	if err != nil {
+		t.Fatalf("readSendmailMessage() error = %v", err)
+	}
+	if string(got) != input {
+		t.Fatalf("readSendmailMessage(-i) = %q, want %q", got, input)
+	}
+}
+
+func TestPrepareSendmailMessage_ExtractRecipients(t *testing.T) {
+	raw := "To: a@example.com, B <b@example.com>\r\n" +
+		"Bcc: hidden@example.com,\r\n" +
+		" other@example.com\r\n" +
+		"Subject: hi\r\n" +
+		"\r\n" +
+		"Bcc: stays in body\r\n"
+	opts := &sendmailOptions{extractRecipients: true, fullName: "Ops", recipients: []string{"A@example.com", "extra@example.com"}}
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	got, recipients, err := prepareSendmailMessage([]byte(raw), opts, "me@example.com", now)
+	if err != nil {
+		t.Fatalf("prepareSendmailMessage() error = %v", err)
+	}
+
+	wantRecipients := []string{"A@example.com", "extra@example.com", "b@example.com", "hidden@example.com", "other@example.com"}
+	if !reflect.DeepEqual(recipients, wantRecipients) {
+		t.Fatalf("recipients = %q, want %q", recipients, wantRecipients)
+	}
+	want := "From: \"Ops\" <me@example.com>\r\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n" +
+		"To: a@example.com, B <b@example.com>\r\n" +
+		"Subject: hi\r\n" +
+		"\r\n" +
+		"Bcc: stays in body\r\n"
+	if string(got) != want {
+		t.Fatalf("message = %q, want %q", got, want)
+	}
+}
+
+func TestPrepareSendmailMessage_RemovesBccWithoutExtract(t *testing.T) {
+	raw := "From: me@example.com\n" +
+		"Date: Tue, 02 Jan 2024 03:04:05 +0000\n" +
+		"To: a@example.com\n" +
+		"Bcc: hidden@example.com\n" +
+		"Subject: hi\n" +
+		"\n" +
+		"body\n"
+	opts := &sendmailOptions{recipients: []string{"a@example.com", "hidden@example.com"}}
+
+	got, recipients, err := prepareSendmailMessage([]byte(raw), opts, "", time.Now())
+	if err != nil {
+		t.Fatalf("prepareSendmailMessage() error = %v", err)
+	}
+	if !reflect.DeepEqual(recipients, opts.recipients) {
+		t.Fatalf("recipients = %q, want %q", recipients, opts.recipients)
+	}
+	if strings.Contains(string(got), "hidden@example.com") {
+		t.Fatalf("message kept its Bcc header:\n%s", got)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/tnm/email-cli/internal/keychain"
 )
@@ -16,6 +19,20 @@ const (
 	ProviderProton    ProviderType = "proton"
 	ProviderSMTP      ProviderType = "smtp"
 	ProviderAgentMail ProviderType = "agentmail"
+	ProviderPipe      ProviderType = "pipe"
+	ProviderLMTP      ProviderType = "lmtp"
+	ProviderMicrosoft ProviderType = "microsoft"
+	ProviderSES       ProviderType = "ses"
+	ProviderSendGrid  ProviderType = "sendgrid"
+	ProviderMailgun   ProviderType = "mailgun"
+	ProviderPostmark  ProviderType = "postmark"
+	ProviderResend    ProviderType = "resend"
+	ProviderJMAP      ProviderType = "jmap"
+	ProviderWebhook   ProviderType = "webhook"
+	ProviderPlugin    ProviderType = "plugin"
+	ProviderFile      ProviderType = "file"
+	ProviderFailover  ProviderType = "failover"
+	ProviderPool      ProviderType = "pool"
 )
 
 type GoogleConfig struct {
@@ -24,6 +41,86 @@ type GoogleConfig struct {
 	AccessToken  string `json:"access_token,omitempty"`
 	RefreshToken string `json:"refresh_token,omitempty"`
 	TokenExpiry  string `json:"token_expiry,omitempty"`
+
+	// ServiceAccountKey, when set, switches to service-account auth with
+	// domain-wide delegation instead of user OAuth. It is a path to the
+	// JSON key file, or a keychain reference holding the key itself.
+	ServiceAccountKey string `json:"service_account_key,omitempty"`
+	// Subject is the Workspace user to impersonate (default: From).
+	Subject string `json:"subject,omitempty"`
+}
+
+// MicrosoftConfig sends through Microsoft Graph as the signed-in user.
+// ClientID is an Entra ID app registration that allows public client
+// flows; Tenant defaults to "common".
+type MicrosoftConfig struct {
+	ClientID     string `json:"client_id"`
+	Tenant       string `json:"tenant,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenExpiry  string `json:"token_expiry,omitempty"`
+}
+
+// SESConfig sends through the Amazon SES v2 API. Without an access key,
+// credentials come from Profile in the shared AWS credentials file.
+type SESConfig struct {
+	Region           string `json:"region"`
+	AccessKeyID      string `json:"access_key_id,omitempty"`
+	SecretAccessKey  string `json:"secret_access_key,omitempty"`
+	SessionToken     string `json:"session_token,omitempty"`
+	Profile          string `json:"profile,omitempty"`
+	ConfigurationSet string `json:"configuration_set,omitempty"`
+}
+
+type SendGridConfig struct {
+	APIKey string `json:"api_key"`
+}
+
+// MailgunConfig sends through a Mailgun sending domain. Region is "us"
+// (the default) or "eu", and must match the region the domain was
+// created in.
+type MailgunConfig struct {
+	APIKey string `json:"api_key"`
+	Domain string `json:"domain"`
+	Region string `json:"region,omitempty"`
+}
+
+// PostmarkConfig sends with a Postmark server API token. MessageStream
+// defaults to the server's "outbound" transactional stream.
+type PostmarkConfig struct {
+	ServerToken   string `json:"server_token"`
+	MessageStream string `json:"message_stream,omitempty"`
+}
+
+type ResendConfig struct {
+	APIKey string `json:"api_key"`
+}
+
+// JMAPConfig sends through a JMAP server. URL is the session resource,
+// or a server URL whose /.well-known/jmap redirects to it. Token is a
+// bearer API token; without one, Username and Password are used.
+type JMAPConfig struct {
+	URL      string `json:"url"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// WebhookConfig POSTs each email to URL. Format is "json" (default) or
+// "multipart"; Template, when set, is a Go text/template rendered with the
+// JSON payload fields and sent as the body instead. ContentType is the
+// body's Content-Type, by default application/json for the JSON payload
+// and text/plain for a template. Secret signs the body with HMAC-SHA256
+// in SignatureHeader (default X-Signature-256). Header values may be
+// keychain references.
+type WebhookConfig struct {
+	URL             string            `json:"url"`
+	Format          string            `json:"format,omitempty"`
+	ContentType     string            `json:"content_type,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Secret          string            `json:"secret,omitempty"`
+	SignatureHeader string            `json:"signature_header,omitempty"`
+	Template        string            `json:"template,omitempty"`
 }
 
 type ProtonConfig struct {
@@ -34,11 +131,25 @@ type ProtonConfig struct {
 }
 
 type SMTPConfig struct {
+	Host     string      `json:"host"`
+	Port     int         `json:"port"`
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	UseTLS   bool        `json:"use_tls"`
+	IMAP     *IMAPConfig `json:"imap,omitempty"`
+}
+
+// IMAPConfig gives an SMTP provider access to the account's mailbox for
+// drafts. Port 993 uses implicit TLS; other ports use STARTTLS. Username
+// and Password default to the SMTP credentials.
+type IMAPConfig struct {
 	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	UseTLS   bool   `json:"use_tls"`
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	// Drafts is the drafts mailbox (default: the mailbox marked \Drafts,
+	// else "Drafts").
+	Drafts string `json:"drafts,omitempty"`
 }
 
 type AgentMailConfig struct {
@@ -46,19 +157,113 @@ type AgentMailConfig struct {
 	InboxID string `json:"inbox_id"`
 }
 
+// PipeConfig delivers by writing the message to a command's stdin.
+// Command is split into arguments like a shell would, but is not run
+// through one.
+type PipeConfig struct {
+	Command string `json:"command"`
+}
+
+// PluginConfig sends through an external executable speaking the plugin
+// protocol. Options are passed to the plugin with every request; values
+// may be keychain references.
+type PluginConfig struct {
+	Command string            `json:"command"`
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// FileConfig writes messages into Path instead of sending them: as .eml
+// files, or delivered into a Maildir when Maildir is set.
+type FileConfig struct {
+	Path    string `json:"path"`
+	Maildir bool   `json:"maildir,omitempty"`
+}
+
+// FailoverConfig sends through the named Providers in order, moving on to
+// the next only when a send fails with a transient error. Members holds
+// their configs once the failover provider is looked up with GetProvider.
+type FailoverConfig struct {
+	Providers []string         `json:"providers"`
+	Members   []ProviderConfig `json:"-"`
+}
+
+// PoolConfig spreads sends across the providers it lists. Strategy is
+// "round-robin" (the default), "weighted", or "quota", which picks the
+// member with the most sends left today. Members holds the providers'
+// configs once the pool is looked up with GetProvider.
+type PoolConfig struct {
+	Strategy  string           `json:"strategy,omitempty"`
+	Providers []PoolMember     `json:"providers"`
+	Members   []ProviderConfig `json:"-"`
+}
+
+// PoolMember is one provider in a pool. Weight (default 1) is its share of
+// sends with the weighted strategy; DailyLimit, when set, is how many
+// messages it may send per UTC day.
+type PoolMember struct {
+	Provider   string `json:"provider"`
+	Weight     int    `json:"weight,omitempty"`
+	DailyLimit int    `json:"daily_limit,omitempty"`
+}
+
+// Names returns the pool's member provider names, in order.
+func (p *PoolConfig) Names() []string {
+	names := make([]string, 0, len(p.Providers))
+	for _, m := range p.Providers {
+		names = append(names, m.Provider)
+	}
+	return names
+}
+
+// LMTPConfig delivers over LMTP to a Unix socket, or to Host and Port
+// when Socket is empty.
+type LMTPConfig struct {
+	Socket string `json:"socket,omitempty"`
+	Host   string `json:"host,omitempty"`
+	Port   int    `json:"port,omitempty"`
+}
+
 type ProviderConfig struct {
-	Type      ProviderType     `json:"type"`
-	Name      string           `json:"name"`
-	From      string           `json:"from"`
-	Google    *GoogleConfig    `json:"google,omitempty"`
-	Proton    *ProtonConfig    `json:"proton,omitempty"`
-	SMTP      *SMTPConfig      `json:"smtp,omitempty"`
-	AgentMail *AgentMailConfig `json:"agentmail,omitempty"`
+	Type           ProviderType     `json:"type"`
+	Name           string           `json:"name"`
+	From           string           `json:"from"`
+	Timeout        string           `json:"timeout,omitempty"`
+	ConnectTimeout string           `json:"connect_timeout,omitempty"`
+	Proxy          string           `json:"proxy,omitempty"`
+	RateLimit      string           `json:"rate_limit,omitempty"`
+	RateBurst      int              `json:"rate_burst,omitempty"`
+	DailyQuota     int              `json:"daily_quota,omitempty"`
+	MonthlyQuota   int              `json:"monthly_quota,omitempty"`
+	Retries        int              `json:"retries,omitempty"`
+	RetryMaxWait   string           `json:"retry_max_wait,omitempty"`
+	RetryAmbiguous bool             `json:"retry_ambiguous,omitempty"`
+	Google         *GoogleConfig    `json:"google,omitempty"`
+	Proton         *ProtonConfig    `json:"proton,omitempty"`
+	SMTP           *SMTPConfig      `json:"smtp,omitempty"`
+	AgentMail      *AgentMailConfig `json:"agentmail,omitempty"`
+	Pipe           *PipeConfig      `json:"pipe,omitempty"`
+	LMTP           *LMTPConfig      `json:"lmtp,omitempty"`
+	Microsoft      *MicrosoftConfig `json:"microsoft,omitempty"`
+	SES            *SESConfig       `json:"ses,omitempty"`
+	SendGrid       *SendGridConfig  `json:"sendgrid,omitempty"`
+	Mailgun        *MailgunConfig   `json:"mailgun,omitempty"`
+	Postmark       *PostmarkConfig  `json:"postmark,omitempty"`
+	Resend         *ResendConfig    `json:"resend,omitempty"`
+	JMAP           *JMAPConfig      `json:"jmap,omitempty"`
+	Webhook        *WebhookConfig   `json:"webhook,omitempty"`
+	Plugin         *PluginConfig    `json:"plugin,omitempty"`
+	File           *FileConfig      `json:"file,omitempty"`
+	Failover       *FailoverConfig  `json:"failover,omitempty"`
+	Pool           *PoolConfig      `json:"pool,omitempty"`
 }
 
 type Config struct {
 	DefaultProvider string                    `json:"default_provider"`
 	Providers       map[string]ProviderConfig `json:"providers"`
+
+	// Routes pick the provider for each recipient when none is given;
+	// see RouteRecipients.
+	Routes []Route `json:"routes,omitempty"`
 }
 
 func ConfigDir() (string, error) {
@@ -125,13 +330,148 @@ func (c *Config) Save() error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
+	if err := writeFileAtomic(path, data, 0600); err != nil {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
 	return nil
 }
 
+// Update loads the config, calls fn, and saves the config if fn succeeds,
+// holding config.json's lock throughout, so a change made by one run, such
+// as a refreshed token, is not lost to another run saving the config it
+// loaded earlier. Every change to a saved config should go through Update.
+func Update(fn func(*Config) error) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := Lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	return cfg.Save()
+}
+
+// writeFileAtomic replaces path with data via a synced temporary file in
+// the same directory, so a crash never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// SaveGoogleToken records a refreshed OAuth token for the named Google
+// provider. Fields stored as keychain references are updated in the
+// keychain; the rest are written to config.json. An empty refresh token
+// leaves the stored one unchanged.
+func SaveGoogleToken(name, accessToken, refreshToken string, expiry time.Time) error {
+	return Update(func(cfg *Config) error {
+		return cfg.setGoogleToken(name, accessToken, refreshToken, expiry)
+	})
+}
+
+func (c *Config) setGoogleToken(name, accessToken, refreshToken string, expiry time.Time) error {
+	p, ok := c.Providers[name]
+	if !ok || p.Type != ProviderGoogle || p.Google == nil {
+		return fmt.Errorf("google provider %q not found", name)
+	}
+
+	googleCfg := *p.Google
+	if err := storeSecret(&googleCfg.AccessToken, accessToken); err != nil {
+		return fmt.Errorf("failed to store access token: %w", err)
+	}
+	if refreshToken != "" {
+		if err := storeSecret(&googleCfg.RefreshToken, refreshToken); err != nil {
+			return fmt.Errorf("failed to store refresh token: %w", err)
+		}
+	}
+	googleCfg.TokenExpiry = formatExpiry(expiry)
+
+	p.Google = &googleCfg
+	c.Providers[name] = p
+	return nil
+}
+
+// SaveMicrosoftToken records a refreshed OAuth token for the named
+// Microsoft provider, like SaveGoogleToken.
+func SaveMicrosoftToken(name, accessToken, refreshToken string, expiry time.Time) error {
+	return Update(func(cfg *Config) error {
+		return cfg.setMicrosoftToken(name, accessToken, refreshToken, expiry)
+	})
+}
+
+func (c *Config) setMicrosoftToken(name, accessToken, refreshToken string, expiry time.Time) error {
+	p, ok := c.Providers[name]
+	if !ok || p.Type != ProviderMicrosoft || p.Microsoft == nil {
+		return fmt.Errorf("microsoft provider %q not found", name)
+	}
+
+	msCfg := *p.Microsoft
+	if err := storeSecret(&msCfg.AccessToken, accessToken); err != nil {
+		return fmt.Errorf("failed to store access token: %w", err)
+	}
+	if refreshToken != "" {
+		if err := storeSecret(&msCfg.RefreshToken, refreshToken); err != nil {
+			return fmt.Errorf("failed to store refresh token: %w", err)
+		}
+	}
+	msCfg.TokenExpiry = formatExpiry(expiry)
+
+	p.Microsoft = &msCfg
+	c.Providers[name] = p
+	return nil
+}
+
+func formatExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return ""
+	}
+	return expiry.Format(time.RFC3339)
+}
+
+// storeSecret sets *field to value, or updates the keychain entry when
+// *field is a keychain reference.
+func storeSecret(field *string, value string) error {
+	if keychain.IsKeychainRef(*field) {
+		return keychain.Set(keychain.ParseKeychainRef(*field), value)
+	}
+	*field = value
+	return nil
+}
+
+// GetProvider returns the named provider, or the default when name is
+// empty. A comma-separated list of names, such as "primary,backup", is
+// returned as a failover provider trying them in order. Failover and pool
+// providers are returned with their members' configs.
 func (c *Config) GetProvider(name string) (*ProviderConfig, error) {
 	if name == "" {
 		name = c.DefaultProvider
@@ -140,14 +480,148 @@ func (c *Config) GetProvider(name string) (*ProviderConfig, error) {
 		return nil, fmt.Errorf("no provider specified and no default set")
 	}
 
-	provider, ok := c.Providers[name]
-	if !ok {
-		return nil, fmt.Errorf("provider %q not found", name)
+	var provider ProviderConfig
+	if strings.Contains(name, ",") {
+		names := strings.Split(name, ",")
+		for i := range names {
+			names[i] = strings.TrimSpace(names[i])
+		}
+		provider = ProviderConfig{
+			Type:     ProviderFailover,
+			Name:     strings.Join(names, ","),
+			Failover: &FailoverConfig{Providers: names},
+		}
+	} else {
+		var ok bool
+		provider, ok = c.Providers[name]
+		if !ok {
+			return nil, fmt.Errorf("provider %q not found", name)
+		}
+	}
+
+	switch {
+	case provider.Type == ProviderFailover && provider.Failover != nil:
+		members, err := c.Members(provider.Failover.Providers)
+		if err != nil {
+			return nil, err
+		}
+		failover := *provider.Failover
+		failover.Members = members
+		provider.Failover = &failover
+		if provider.From == "" {
+			provider.From = members[0].From
+		}
+	case provider.Type == ProviderPool && provider.Pool != nil:
+		members, err := c.Members(provider.Pool.Names())
+		if err != nil {
+			return nil, err
+		}
+		pool := *provider.Pool
+		pool.Members = members
+		provider.Pool = &pool
+		if provider.From == "" {
+			provider.From = members[0].From
+		}
 	}
 
 	return &provider, nil
 }
 
+// Members looks up the providers a failover or pool provider lists. They
+// must exist and must not themselves combine other providers.
+func (c *Config) Members(names []string) ([]ProviderConfig, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("at least one provider is required")
+	}
+	members := make([]ProviderConfig, 0, len(names))
+	for _, name := range names {
+		member, ok := c.Providers[name]
+		if !ok {
+			return nil, fmt.Errorf("provider %q not found", name)
+		}
+		if member.Type == ProviderFailover || member.Type == ProviderPool {
+			return nil, fmt.Errorf("%s provider %q cannot be a member of another provider", member.Type, name)
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// Timeouts parses the optional connect and overall send timeouts.
+// Unset values are returned as zero.
+func (p *ProviderConfig) Timeouts() (connect, overall time.Duration, err error) {
+	if p.ConnectTimeout != "" {
+		connect, err = time.ParseDuration(p.ConnectTimeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid connect_timeout %q: %w", p.ConnectTimeout, err)
+		}
+	}
+	if p.Timeout != "" {
+		overall, err = time.ParseDuration(p.Timeout)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid timeout %q: %w", p.Timeout, err)
+		}
+	}
+	return connect, overall, nil
+}
+
+// RetryWait parses RetryMaxWait, the longest wait between retries (and
+// the longest Retry-After a provider may ask for and still be retried).
+// Retries is how many times a failed send is tried again, and
+// RetryAmbiguous allows retrying sends that may have been delivered. An
+// unset wait is returned as zero.
+func (p *ProviderConfig) RetryWait() (time.Duration, error) {
+	if p.RetryMaxWait == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(p.RetryMaxWait)
+	if err != nil {
+		return 0, fmt.Errorf("invalid retry_max_wait %q: %w", p.RetryMaxWait, err)
+	}
+	return wait, nil
+}
+
+// Rate parses RateLimit, which caps sends as "N/period" such as "30/min",
+// as n sends per period. The period is a unit such
+// as s, min, h or day, or a duration such as 5s; a bare unit means one of
+// it. An unset limit is returned as zero.
+func (p *ProviderConfig) Rate() (n int, per time.Duration, err error) {
+	if p.RateLimit == "" {
+		return 0, 0, nil
+	}
+	count, period, ok := strings.Cut(p.RateLimit, "/")
+	if ok {
+		n, err = strconv.Atoi(strings.TrimSpace(count))
+	}
+	if !ok || err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate_limit %q: want a count and period such as 30/min", p.RateLimit)
+	}
+
+	period = strings.TrimSpace(period)
+	units := map[string]time.Duration{
+		"s": time.Second, "sec": time.Second, "second": time.Second,
+		"m": time.Minute, "min": time.Minute, "minute": time.Minute,
+		"h": time.Hour, "hr": time.Hour, "hour": time.Hour,
+		"d": 24 * time.Hour, "day": 24 * time.Hour,
+	}
+	per, ok = units[period]
+	if !ok {
+		per, err = time.ParseDuration(period)
+		if err != nil || per <= 0 {
+			return 0, 0, fmt.Errorf("invalid rate_limit %q: unknown period %q", p.RateLimit, period)
+		}
+	}
+	return n, per, nil
+}
+
+// HasLimits reports whether the provider has a rate limit or quota.
+// RateBurst is how many sends the rate limit lets through at once
+// (default 1); DailyQuota and MonthlyQuota cap the recipients sent to
+// per UTC day and calendar month.
+func (p *ProviderConfig) HasLimits() bool {
+	return p.RateLimit != "" || p.DailyQuota > 0 || p.MonthlyQuota > 0
+}
+
 // ResolveSecrets resolves any keychain references in the provider config.
 // Returns a copy with secrets resolved (original is not modified).
 func (p *ProviderConfig) ResolveSecrets() (*ProviderConfig, error) {
@@ -164,6 +638,17 @@ func (p *ProviderConfig) ResolveSecrets() (*ProviderConfig, error) {
 				}
 				smtpCfg.Password = secret
 			}
+			if smtpCfg.IMAP != nil {
+				imapCfg := *smtpCfg.IMAP
+				if keychain.IsKeychainRef(imapCfg.Password) {
+					secret, err := keychain.Resolve(imapCfg.Password)
+					if err != nil {
+						return nil, fmt.Errorf("failed to resolve IMAP password: %w", err)
+					}
+					imapCfg.Password = secret
+				}
+				smtpCfg.IMAP = &imapCfg
+			}
 			resolved.SMTP = &smtpCfg
 		}
 
@@ -204,9 +689,56 @@ func (p *ProviderConfig) ResolveSecrets() (*ProviderConfig, error) {
 				}
 				googleCfg.RefreshToken = secret
 			}
+			if keychain.IsKeychainRef(googleCfg.ServiceAccountKey) {
+				secret, err := keychain.Resolve(googleCfg.ServiceAccountKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Google service account key: %w", err)
+				}
+				googleCfg.ServiceAccountKey = secret
+			}
 			resolved.Google = &googleCfg
 		}
 
+	case ProviderMicrosoft:
+		if p.Microsoft != nil {
+			msCfg := *p.Microsoft
+			if keychain.IsKeychainRef(msCfg.AccessToken) {
+				secret, err := keychain.Resolve(msCfg.AccessToken)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Microsoft access token: %w", err)
+				}
+				msCfg.AccessToken = secret
+			}
+			if keychain.IsKeychainRef(msCfg.RefreshToken) {
+				secret, err := keychain.Resolve(msCfg.RefreshToken)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Microsoft refresh token: %w", err)
+				}
+				msCfg.RefreshToken = secret
+			}
+			resolved.Microsoft = &msCfg
+		}
+
+	case ProviderSES:
+		if p.SES != nil {
+			sesCfg := *p.SES
+			if keychain.IsKeychainRef(sesCfg.SecretAccessKey) {
+				secret, err := keychain.Resolve(sesCfg.SecretAccessKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve SES secret access key: %w", err)
+				}
+				sesCfg.SecretAccessKey = secret
+			}
+			if keychain.IsKeychainRef(sesCfg.SessionToken) {
+				secret, err := keychain.Resolve(sesCfg.SessionToken)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve SES session token: %w", err)
+				}
+				sesCfg.SessionToken = secret
+			}
+			resolved.SES = &sesCfg
+		}
+
 	case ProviderAgentMail:
 		if p.AgentMail != nil {
 			agentMailCfg := *p.AgentMail
@@ -219,6 +751,123 @@ func (p *ProviderConfig) ResolveSecrets() (*ProviderConfig, error) {
 			}
 			resolved.AgentMail = &agentMailCfg
 		}
+
+	case ProviderSendGrid:
+		if p.SendGrid != nil {
+			sendGridCfg := *p.SendGrid
+			if keychain.IsKeychainRef(sendGridCfg.APIKey) {
+				secret, err := keychain.Resolve(sendGridCfg.APIKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve SendGrid API key: %w", err)
+				}
+				sendGridCfg.APIKey = secret
+			}
+			resolved.SendGrid = &sendGridCfg
+		}
+
+	case ProviderMailgun:
+		if p.Mailgun != nil {
+			mailgunCfg := *p.Mailgun
+			if keychain.IsKeychainRef(mailgunCfg.APIKey) {
+				secret, err := keychain.Resolve(mailgunCfg.APIKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Mailgun API key: %w", err)
+				}
+				mailgunCfg.APIKey = secret
+			}
+			resolved.Mailgun = &mailgunCfg
+		}
+
+	case ProviderPostmark:
+		if p.Postmark != nil {
+			postmarkCfg := *p.Postmark
+			if keychain.IsKeychainRef(postmarkCfg.ServerToken) {
+				secret, err := keychain.Resolve(postmarkCfg.ServerToken)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Postmark server token: %w", err)
+				}
+				postmarkCfg.ServerToken = secret
+			}
+			resolved.Postmark = &postmarkCfg
+		}
+
+	case ProviderResend:
+		if p.Resend != nil {
+			resendCfg := *p.Resend
+			if keychain.IsKeychainRef(resendCfg.APIKey) {
+				secret, err := keychain.Resolve(resendCfg.APIKey)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve Resend API key: %w", err)
+				}
+				resendCfg.APIKey = secret
+			}
+			resolved.Resend = &resendCfg
+		}
+
+	case ProviderJMAP:
+		if p.JMAP != nil {
+			jmapCfg := *p.JMAP
+			if keychain.IsKeychainRef(jmapCfg.Password) {
+				secret, err := keychain.Resolve(jmapCfg.Password)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve JMAP password: %w", err)
+				}
+				jmapCfg.Password = secret
+			}
+			if keychain.IsKeychainRef(jmapCfg.Token) {
+				secret, err := keychain.Resolve(jmapCfg.Token)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve JMAP token: %w", err)
+				}
+				jmapCfg.Token = secret
+			}
+			resolved.JMAP = &jmapCfg
+		}
+
+	case ProviderWebhook:
+		if p.Webhook != nil {
+			webhookCfg := *p.Webhook
+			if keychain.IsKeychainRef(webhookCfg.Secret) {
+				secret, err := keychain.Resolve(webhookCfg.Secret)
+				if err != nil {
+					return nil, fmt.Errorf("failed to resolve webhook secret: %w", err)
+				}
+				webhookCfg.Secret = secret
+			}
+			if len(webhookCfg.Headers) > 0 {
+				webhookCfg.Headers = make(map[string]string, len(p.Webhook.Headers))
+				for name, value := range p.Webhook.Headers {
+					if keychain.IsKeychainRef(value) {
+						secret, err := keychain.Resolve(value)
+						if err != nil {
+							return nil, fmt.Errorf("failed to resolve webhook header %s: %w", name, err)
+						}
+						value = secret
+					}
+					webhookCfg.Headers[name] = value
+				}
+			}
+			resolved.Webhook = &webhookCfg
+		}
+
+	case ProviderPlugin:
+		if p.Plugin != nil {
+			pluginCfg := *p.Plugin
+			if len(pluginCfg.Options) > 0 {
+				pluginCfg.Options = make(map[string]string, len(p.Plugin.Options))
+				for key, value := range p.Plugin.Options {
+					if keychain.IsKeychainRef(value) {
+						secret, err := keychain.Resolve(value)
+						if err != nil {
+							return nil, fmt.Errorf("failed to resolve plugin option %s: %w", key, err)
+						}
+						value = secret
+					}
+					pluginCfg.Options[key] = value
+				}
+			}
+			resolved.Plugin = &pluginCfg
+		}
 	}
 
 	return &resolved, nil
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestResolveSecrets_PlaintextPassthrough(t *testing.T) {
@@ -168,6 +169,39 @@ func TestGetProvider_NoDefault(t *testing.T) {
 	}
 }
 
+func TestGetProvider_Failover(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "reliable",
+		Providers: map[string]ProviderConfig{
+			"main":     {Name: "main", Type: ProviderSMTP, From: "me@example.com"},
+			"backup":   {Name: "backup", Type: ProviderSendGrid},
+			"reliable": {Name: "reliable", Type: ProviderFailover, Failover: &FailoverConfig{Providers: []string{"main", "backup"}}},
+		},
+	}
+
+	for _, name := range []string{"", "main, backup"} {
+		p, err := cfg.GetProvider(name)
+		if err != nil {
+			t.Fatalf("GetProvider(%q) error = %v", name, err)
+		}
+		if p.Type != ProviderFailover || len(p.Failover.Members) != 2 || p.Failover.Members[1].Name != "backup" {
+			t.Fatalf("GetProvider(%q) = %+v", name, p)
+		}
+		if p.From != "me@example.com" {
+			t.Errorf("GetProvider(%q).From = %q, want the first member's", name, p.From)
+		}
+	}
+	if cfg.Providers["reliable"].Failover.Members != nil {
+		t.Error("GetProvider() modified the stored failover config")
+	}
+
+	for _, name := range []string{"main,missing", "main,reliable"} {
+		if _, err := cfg.GetProvider(name); err == nil {
+			t.Errorf("GetProvider(%q) should return error", name)
+		}
+	}
+}
+
 func TestLoadSave_RoundTrip(t *testing.T) {
 	// Create temp dir for test config
 	tmpDir, err := os.MkdirTemp("", "email-cli-test")
@@ -263,3 +297,206 @@ func TestConfigFilePermissions(t *testing.T) {
 		t.Errorf("Config file permissions = %o, want 0600", perm)
 	}
 }
+
+func TestTimeouts(t *testing.T) {
+	p := &ProviderConfig{Timeout: "2m", ConnectTimeout: "10s"}
+	connect, overall, err := p.Timeouts()
+	if err != nil {
+		t.Fatalf("Timeouts() error = %v", err)
+	}
+	if connect != 10*time.Second {
+		t.Errorf("connect = %v, want 10s", connect)
+	}
+	if overall != 2*time.Minute {
+		t.Errorf("overall = %v, want 2m", overall)
+	}
+
+	empty := &ProviderConfig{}
+	connect, overall, err = empty.Timeouts()
+	if err != nil || connect != 0 || overall != 0 {
+		t.Errorf("Timeouts() on empty config = %v, %v, %v; want zero values", connect, overall, err)
+	}
+
+	bad := &ProviderConfig{Timeout: "soon"}
+	if _, _, err := bad.Timeouts(); err == nil {
+		t.Error("Timeouts() should fail for an invalid duration")
+	}
+}
+
+func TestSaveGoogleToken(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cfg := &Config{
+		DefaultProvider: "work",
+		Providers: map[string]ProviderConfig{
+			"work": {
+				Type: ProviderGoogle,
+				Name: "work",
+				From: "me@example.com",
+				Google: &GoogleConfig{
+					ClientID:     "id",
+					ClientSecret: "secret",
+					AccessToken:  "old-access",
+					RefreshToken: "old-refresh",
+				},
+			},
+		},
+	}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := SaveGoogleToken("work", "new-access", "", expiry); err != nil {
+		t.Fatalf("SaveGoogleToken() error = %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	g := loaded.Providers["work"].Google
+	if g.AccessToken != "new-access" || g.RefreshToken != "old-refresh" || g.TokenExpiry != "2030-01-02T03:04:05Z" {
+		t.Fatalf("google config = %+v", g)
+	}
+	if g.ClientSecret != "secret" {
+		t.Fatalf("ClientSecret = %q, want unchanged", g.ClientSecret)
+	}
+
+	if err := SaveGoogleToken("work", "newer-access", "rotated-refresh", expiry); err != nil {
+		t.Fatalf("SaveGoogleToken() error = %v", err)
+	}
+	loaded, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := loaded.Providers["work"].Google.RefreshToken; got != "rotated-refresh" {
+		t.Fatalf("RefreshToken = %q, want rotated-refresh", got)
+	}
+
+	dir, _ := ConfigDir()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "config.json" {
+		t.Fatalf("config dir contains %v, want only config.json", entries)
+	}
+	info, err := os.Stat(filepath.Join(dir, "config.json"))
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("config permissions = %o, want 0600", perm)
+	}
+
+	if err := SaveGoogleToken("missing", "a", "", time.Time{}); err == nil {
+		t.Fatalf("SaveGoogleToken() for unknown provider error = nil, want error")
+	}
+}
+
+func TestSaveGoogleToken_WaitsForLock(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cfg := &Config{Providers: map[string]ProviderConfig{
+		"gmail": {Type: ProviderGoogle, Name: "gmail", Google: &GoogleConfig{AccessToken: "old"}},
+	}}
+	if err := cfg.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	path, _ := ConfigPath()
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	// Another run holding the lock is between loading and saving the
+	// config; saving the token now would be overwritten.
+	done := make(chan error, 1)
+	go func() { done <- SaveGoogleToken("gmail", "new", "", time.Time{}) }()
+	select {
+	case err := <-done:
+		unlock()
+		t.Fatalf("SaveGoogleToken() returned %v while the config was locked", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("SaveGoogleToken() error = %v", err)
+	}
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := loaded.Providers["gmail"].Google.AccessToken; got != "new" {
+		t.Fatalf("AccessToken = %q, want new", got)
+	}
+}
+
+func TestGetProvider_Pool(t *testing.T) {
+	cfg := &Config{
+		Providers: map[string]ProviderConfig{
+			"inbox1": {Name: "inbox1", Type: ProviderGoogle, From: "one@example.com"},
+			"inbox2": {Name: "inbox2", Type: ProviderGoogle, From: "two@example.com"},
+			"outbound": {Name: "outbound", Type: ProviderPool, Pool: &PoolConfig{
+				Providers: []PoolMember{{Provider: "inbox1", DailyLimit: 100}, {Provider: "inbox2"}},
+			}},
+			"nested": {Name: "nested", Type: ProviderPool, Pool: &PoolConfig{
+				Providers: []PoolMember{{Provider: "outbound"}},
+			}},
+		},
+	}
+
+	p, err := cfg.GetProvider("outbound")
+	if err != nil {
+		t.Fatalf("GetProvider() error = %v", err)
+	}
+	if len(p.Pool.Members) != 2 || p.Pool.Members[1].From != "two@example.com" {
+		t.Fatalf("GetProvider() pool members = %+v", p.Pool.Members)
+	}
+	if p.From != "one@example.com" {
+		t.Errorf("GetProvider().From = %q, want the first member's", p.From)
+	}
+	if cfg.Providers["outbound"].Pool.Members != nil {
+		t.Error("GetProvider() modified the stored pool config")
+	}
+
+	if _, err := cfg.GetProvider("nested"); err == nil {
+		t.Error("GetProvider() should reject a pool inside a pool")
+	}
+	if _, err := cfg.GetProvider("inbox1,outbound"); err == nil {
+		t.Error("GetProvider() should reject a pool inside a failover chain")
+	}
+}
+
+func TestProviderConfig_Rate(t *testing.T) {
+	tests := []struct {
+		limit   string
+		n       int
+		per     time.Duration
+		wantErr bool
+	}{
+		{"", 0, 0, false},
+		{"30/min", 30, time.Minute, false},
+		{"1/s", 1, time.Second, false},
+		{"500 / day", 500, 24 * time.Hour, false},
+		{"10/5s", 10, 5 * time.Second, false},
+		{"30", 0, 0, true},
+		{"0/min", 0, 0, true},
+		{"30/fortnight", 0, 0, true},
+	}
+	for _, tt := range tests {
+		p := &ProviderConfig{RateLimit: tt.limit}
+		n, per, err := p.Rate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Rate(%q) error = %v, wantErr %v", tt.limit, err, tt.wantErr)
+			continue
+		}
+		if n != tt.n || per != tt.per {
+			t.Errorf("Rate(%q) = %d/%s, want %d/%s", tt.limit, n, per, tt.n, tt.per)
+		}
+	}
+}
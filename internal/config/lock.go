@@ -0,0 +1,78 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockWait is how long Lock waits for another run's lock.
+	lockWait = 10 * time.Second
+	// staleLock is how old a lock file must be before it is assumed to
+	// be left over from a run that crashed.
+	staleLock = 30 * time.Second
+)
+
+// Lock creates path's lock file, path+".lock", waiting for any other run
+// holding it. It is meant for short updates: a lock older than 30s is
+// assumed to be left over from a run that crashed. Creating a file
+// exclusively works the same on every platform, where flock and
+// LockFileEx do not.
+func Lock(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	delay := 5 * time.Millisecond
+	for {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLock {
+			breakStaleLock(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock %s: %s is held by another email-cli (remove it if none is running)", path, lockPath)
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
+
+// breakStaleLock removes a lock file left over from a run that crashed.
+// Between the caller finding it stale and here, another run may have
+// broken it too and taken a fresh lock, so rather than removing whatever
+// is at lockPath, the lock is renamed aside, which only one run can do,
+// and removed only if it is still stale. A fresh lock renamed by mistake
+// is linked back into place.
+func breakStaleLock(lockPath string) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return
+	}
+	aside := lockPath + ".stale-" + hex.EncodeToString(b)
+	if err := os.Rename(lockPath, aside); err != nil {
+		// Already broken, or released by its owner.
+		return
+	}
+	if info, err := os.Stat(aside); err == nil && time.Since(info.ModTime()) <= staleLock {
+		os.Link(aside, lockPath)
+	}
+	os.Remove(aside)
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLock_BreaksStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path+".lock", nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * staleLock)
+	if err := os.Chtimes(path+".lock", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := Lock(path)
+	if err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	info, err := os.Stat(path + ".lock")
+	if err != nil || time.Since(info.ModTime()) > staleLock {
+		t.Fatalf("lock file = %v, %v, want a fresh lock", info, err)
+	}
+	unlock()
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 0 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
+
+func TestBreakStaleLock_KeepsFreshLock(t *testing.T) {
+	// Another run broke the stale lock and took a fresh one after this
+	// run found it stale.
+	lockPath := filepath.Join(t.TempDir(), "state.json.lock")
+	if err := os.WriteFile(lockPath, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	breakStaleLock(lockPath)
+	if _, err := os.Stat(lockPath); err != nil {
+		t.Fatalf("fresh lock was removed: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(lockPath))
+	if len(entries) != 1 {
+		t.Errorf("files left behind: %v", entries)
+	}
+}
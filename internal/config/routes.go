@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// Route sends mail that matches it through Provider. Domain matches the
+// recipient's domain exactly (case-insensitively, with "*." matching
+// subdomains); Recipient, From and Subject are regular expressions
+// matched against the bare recipient address, the sender address and
+// the subject. Empty conditions match anything, and all given conditions
+// must match.
+type Route struct {
+	Domain    string `json:"domain,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+	From      string `json:"from,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Provider  string `json:"provider"`
+}
+
+// String describes the route's conditions, such as
+// `domain=example.com subject=/^\[alert\]/ -> relay`.
+func (r Route) String() string {
+	var conds []string
+	if r.Domain != "" {
+		conds = append(conds, "domain="+r.Domain)
+	}
+	if r.Recipient != "" {
+		conds = append(conds, "recipient=/"+r.Recipient+"/")
+	}
+	if r.From != "" {
+		conds = append(conds, "from=/"+r.From+"/")
+	}
+	if r.Subject != "" {
+		conds = append(conds, "subject=/"+r.Subject+"/")
+	}
+	if len(conds) == 0 {
+		conds = append(conds, "*")
+	}
+	return strings.Join(conds, " ") + " -> " + r.Provider
+}
+
+// Validate checks that the route names a provider and that its patterns
+// compile.
+func (r Route) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+type compiledRoute struct {
+	route                    Route
+	recipient, from, subject *regexp.Regexp
+}
+
+func (r Route) compile() (*compiledRoute, error) {
+	if r.Provider == "" {
+		return nil, fmt.Errorf("route %s has no provider", r)
+	}
+	c := &compiledRoute{route: r}
+	for _, p := range []struct {
+		field, pattern string
+		re             **regexp.Regexp
+	}{
+		{"recipient", r.Recipient, &c.recipient},
+		{"from", r.From, &c.from},
+		{"subject", r.Subject, &c.subject},
+	} {
+		if p.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route %s pattern: %w", p.field, err)
+		}
+		*p.re = re
+	}
+	return c, nil
+}
+
+func (c *compiledRoute) match(recipient, from, subject string) bool {
+	if d := strings.ToLower(c.route.Domain); d != "" {
+		_, domain, _ := strings.Cut(strings.ToLower(recipient), "@")
+		if sub, ok := strings.CutPrefix(d, "*."); ok {
+			if !strings.HasSuffix(domain, "."+sub) {
+				return false
+			}
+		} else if domain != d {
+			return false
+		}
+	}
+	return (c.recipient == nil || c.recipient.MatchString(recipient)) &&
+		(c.from == nil || c.from.MatchString(from)) &&
+		(c.subject == nil || c.subject.MatchString(subject))
+}
+
+// RoutedProvider is a provider picked by routing and the recipients it
+// was picked for.
+type RoutedProvider struct {
+	Provider   *ProviderConfig
+	Recipients []string
+}
+
+// RouteRecipients picks a provider for each recipient: that of the first
+// route matching it, or the default provider when none does. Recipients
+// are grouped by provider, in the order the providers were first picked.
+// from is the sender address; when empty, the default provider's from
+// address is matched instead.
+func (c *Config) RouteRecipients(from, subject string, recipients []string) ([]RoutedProvider, error) {
+	routes := make([]*compiledRoute, 0, len(c.Routes))
+	for _, r := range c.Routes {
+		compiled, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, compiled)
+	}
+	if from == "" && c.DefaultProvider != "" {
+		from = c.Providers[c.DefaultProvider].From
+	}
+	from = bareAddress(from)
+
+	var routed []RoutedProvider
+	index := map[string]int{}
+	for _, recipient := range recipients {
+		name := ""
+		for _, r := range routes {
+			if r.match(bareAddress(recipient), from, subject) {
+				name = r.route.Provider
+				break
+			}
+		}
+		if name == "" {
+			if c.DefaultProvider == "" {
+				return nil, fmt.Errorf("no route matches %s and no default provider is set", recipient)
+			}
+			name = c.DefaultProvider
+		}
+
+		i, ok := index[name]
+		if !ok {
+			provider, err := c.GetProvider(name)
+			if err != nil {
+				return nil, err
+			}
+			i = len(routed)
+			index[name] = i
+			routed = append(routed, RoutedProvider{Provider: provider})
+		}
+		routed[i].Recipients = append(routed[i].Recipients, recipient)
+	}
+	return routed, nil
+}
+
+// bareAddress returns the address in addr, such as "a@example.com" for
+// "Alice <a@example.com>", or addr itself if it does not parse.
+func bareAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return parsed.Address
+	}
+	return strings.TrimSpace(addr)
+}
@@ -0,0 +1,95 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRouteRecipients(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "agent",
+		Providers: map[string]ProviderConfig{
+			"agent":  {Name: "agent", Type: ProviderAgentMail, From: "bot@agentmail.to"},
+			"relay":  {Name: "relay", Type: ProviderSMTP},
+			"alerts": {Name: "alerts", Type: ProviderSendGrid},
+		},
+		Routes: []Route{
+			{Subject: `^\[alert\]`, Provider: "alerts"},
+			{Domain: "ourcompany.com", Provider: "relay"},
+			{Domain: "*.ourcompany.com", Provider: "relay"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		from       string
+		subject    string
+		recipients []string
+		want       map[string][]string
+	}{
+		{"split by domain", "", "Hi", []string{"Alice <alice@OurCompany.com>", "bob@example.com", "carol@eu.ourcompany.com"},
+			map[string][]string{"relay": {"Alice <alice@OurCompany.com>", "carol@eu.ourcompany.com"}, "agent": {"bob@example.com"}}},
+		{"subject first", "", "[alert] disk full", []string{"alice@ourcompany.com", "bob@example.com"},
+			map[string][]string{"alerts": {"alice@ourcompany.com", "bob@example.com"}}},
+		{"lookalike domain", "", "Hi", []string{"eve@notourcompany.com"},
+			map[string][]string{"agent": {"eve@notourcompany.com"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routed, err := cfg.RouteRecipients(tt.from, tt.subject, tt.recipients)
+			if err != nil {
+				t.Fatalf("RouteRecipients() error = %v", err)
+			}
+			got := map[string][]string{}
+			for _, r := range routed {
+				got[r.Provider.Name] = r.Recipients
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("RouteRecipients() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouteRecipients_From(t *testing.T) {
+	cfg := &Config{
+		DefaultProvider: "agent",
+		Providers: map[string]ProviderConfig{
+			"agent": {Name: "agent", Type: ProviderAgentMail, From: "bot@agentmail.to"},
+			"relay": {Name: "relay", Type: ProviderSMTP},
+		},
+		Routes: []Route{{From: `@ourcompany\.com$`, Provider: "relay"}},
+	}
+
+	for from, want := range map[string]string{
+		"Ops <ops@ourcompany.com>": "relay",
+		"":                         "agent", // matched as the default provider's from
+	} {
+		routed, err := cfg.RouteRecipients(from, "", []string{"a@example.com"})
+		if err != nil {
+			t.Fatalf("RouteRecipients(%q) error = %v", from, err)
+		}
+		if routed[0].Provider.Name != want {
+			t.Errorf("RouteRecipients(%q) picked %s, want %s", from, routed[0].Provider.Name, want)
+		}
+	}
+}
+
+func TestRouteRecipients_Errors(t *testing.T) {
+	providers := map[string]ProviderConfig{"relay": {Name: "relay", Type: ProviderSMTP}}
+	tests := []struct {
+		name string
+		cfg  *Config
+	}{
+		{"no default", &Config{Providers: providers, Routes: []Route{{Domain: "ourcompany.com", Provider: "relay"}}}},
+		{"bad pattern", &Config{Providers: providers, DefaultProvider: "relay", Routes: []Route{{Subject: "(", Provider: "relay"}}}},
+		{"unknown provider", &Config{Providers: providers, DefaultProvider: "relay", Routes: []Route{{Provider: "missing"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cfg.RouteRecipients("", "", []string{"a@example.com"}); err == nil {
+				t.Fatal("RouteRecipients() should return error")
+			}
+		})
+	}
+}
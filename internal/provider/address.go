@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+func isASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiAddress converts an internationalized address into a form that a
+// server without SMTPUTF8 can accept by encoding the domain as IDNA
+// punycode. The address may carry a display name, which is kept (encoded
+// as an RFC 2047 word if it is not ASCII). A non-ASCII local part cannot
+// be converted and is an error.
+func asciiAddress(addr string) (string, error) {
+	if isASCII(addr) {
+		return addr, nil
+	}
+
+	parsed, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", fmt.Errorf("address %q contains non-ASCII characters and the server does not support SMTPUTF8", addr)
+	}
+	at := strings.LastIndex(parsed.Address, "@")
+	local, domain := parsed.Address[:at], parsed.Address[at+1:]
+	if !isASCII(local) {
+		return "", fmt.Errorf("address %q has a non-ASCII local part and the server does not support SMTPUTF8", addr)
+	}
+
+	asciiDomain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain in address %q: %w", addr, err)
+	}
+	parsed.Address = local + "@" + asciiDomain
+	if parsed.Name == "" {
+		return parsed.Address, nil
+	}
+	return parsed.String(), nil
+}
+
+func asciiAddressList(addrs []string) ([]string, error) {
+	out := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		converted, err := asciiAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, converted)
+	}
+	return out, nil
+}
@@ -0,0 +1,38 @@
+package provider
+
+import "testing"
+
+func TestASCIIAddress(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "user@example.com", want: "user@example.com"},
+		{in: "user@bücher.example", want: "user@xn--bcher-kva.example"},
+		{in: "user@例子.广告", want: "user@xn--fsqu00a.xn--4rr70v"},
+		{in: `"Name" <user@bücher.de>`, want: `"Name" <user@xn--bcher-kva.de>`},
+		{in: "Jörg <jorg@example.com>", want: "=?utf-8?q?J=C3=B6rg?= <jorg@example.com>"},
+		{in: "用户@example.com", wantErr: true},
+		{in: "Name <用户@example.com>", wantErr: true},
+		{in: "ünicode", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := asciiAddress(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("asciiAddress(%q) expected error, got %q", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("asciiAddress(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Fatalf("asciiAddress(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,31 +2,35 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/mail"
 	"os"
 	"path/filepath"
-	"time"
+	"strings"
 
 	"github.com/tnm/email-cli/internal/config"
 )
 
 var agentMailAPIBase = "https://api.agentmail.to/v0"
 
-var httpClient = &http.Client{
-	Timeout: 30 * time.Second,
-}
+// httpClient has no Timeout of its own: requests are bounded by the
+// send's context, so a configured timeout longer than any fixed cap still
+// applies, and a large upload on a slow link is not cut short.
+var httpClient = &http.Client{}
 
 type AgentMail struct {
 	apiKey  string
 	inboxID string
+	client  *http.Client
 }
 
-func NewAgentMail(cfg *config.AgentMailConfig) (*AgentMail, error) {
+func NewAgentMail(cfg *config.AgentMailConfig, t *Transport) (*AgentMail, error) {
 	if cfg.APIKey == "" {
 		return nil, fmt.Errorf("agentmail api_key is required")
 	}
@@ -36,6 +40,7 @@ func NewAgentMail(cfg *config.AgentMailConfig) (*AgentMail, error) {
 	return &AgentMail{
 		apiKey:  cfg.APIKey,
 		inboxID: cfg.InboxID,
+		client:  t.httpClient(),
 	}, nil
 }
 
@@ -64,7 +69,18 @@ type agentMailError struct {
 	Message string `json:"message"`
 }
 
-func (a *AgentMail) Send(email *Email) error {
+func (a *AgentMail) Send(ctx context.Context, email *Email) error {
+	if email.Raw != nil {
+		req, err := agentMailRequestFromRaw(email)
+		if err != nil {
+			return err
+		}
+		return a.post(ctx, req)
+	}
+	if err := a.checkFrom(email.From); err != nil {
+		return err
+	}
+
 	req := agentMailRequest{
 		To:      email.To,
 		Cc:      email.Cc,
@@ -115,13 +131,66 @@ func (a *AgentMail) Send(email *Email) error {
 		})
 	}
 
+	return a.post(ctx, req)
+}
+
+// checkFrom refuses a sender other than the inbox, the only address
+// AgentMail sends from, rather than sending from a different address than
+// requested. A raw message's From is its envelope sender, which AgentMail
+// has no use for, so only emails built here are checked.
+func (a *AgentMail) checkFrom(from string) error {
+	if from == "" {
+		return nil
+	}
+	addr := from
+	if parsed, err := mail.ParseAddress(from); err == nil {
+		addr = parsed.Address
+	}
+	if !strings.EqualFold(addr, a.inboxID) {
+		return fmt.Errorf("agentmail sends only from its inbox %s, not %s", a.inboxID, from)
+	}
+	return nil
+}
+
+// agentMailRequestFromRaw converts a raw message into API fields, since
+// AgentMail does not accept MIME directly.
+func agentMailRequestFromRaw(email *Email) (agentMailRequest, error) {
+	parsed, err := parseRawMessage(email.Raw)
+	if err != nil {
+		return agentMailRequest{}, err
+	}
+
+	to, cc, bcc := envelopeSplit(parsed, email.envelopeRecipients())
+	req := agentMailRequest{
+		To:      to,
+		Cc:      cc,
+		Bcc:     bcc,
+		Subject: parsed.Subject,
+		Text:    parsed.Text,
+		HTML:    parsed.HTML,
+	}
+	for _, att := range parsed.Attachments {
+		mimeType := mime.TypeByExtension(filepath.Ext(att.Filename))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		req.Attachments = append(req.Attachments, agentMailAttachment{
+			Filename:    att.Filename,
+			Content:     base64.StdEncoding.EncodeToString(att.Content),
+			ContentType: mimeType,
+		})
+	}
+	return req, nil
+}
+
+func (a *AgentMail) post(ctx context.Context, req agentMailRequest) error {
 	body, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	url := fmt.Sprintf("%s/inboxes/%s/messages/send", agentMailAPIBase, a.inboxID)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -129,7 +198,7 @@ func (a *AgentMail) Send(email *Email) error {
 	httpReq.Header.Set("Authorization", "Bearer "+a.apiKey)
 	httpReq.Header.Set("Content-Type", "application/json")
 
-	resp, err := httpClient.Do(httpReq)
+	resp, err := a.client.Do(httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to send request: %w", err)
 	}
@@ -139,9 +208,9 @@ func (a *AgentMail) Send(email *Email) error {
 		respBody, _ := io.ReadAll(resp.Body)
 		var apiErr agentMailError
 		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
-			return fmt.Errorf("agentmail error: %s", apiErr.Message)
+			return responseError(resp, fmt.Errorf("agentmail error: %s", apiErr.Message))
 		}
-		return fmt.Errorf("agentmail error: %s (status %d)", string(respBody), resp.StatusCode)
+		return responseError(resp, fmt.Errorf("agentmail error: %s (status %d)", string(respBody), resp.StatusCode))
 	}
 
 	return nil
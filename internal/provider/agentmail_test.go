@@ -1,7 +1,9 @@
 package provider
 
 import (
+	"context"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -11,24 +13,21 @@ import (
 	"github.com/tnm/email-cli/internal/config"
 )
 
-func TestAgentMailClientHasTimeout(t *testing.T) {
-	if httpClient.Timeout == 0 {
-		t.Fatal("httpClient has no timeout set")
+func TestHTTPClient_NoFixedTimeout(t *testing.T) {
+	// The send's context bounds each request, so a configured timeout
+	// above any fixed client cap still applies.
+	if httpClient.Timeout != 0 {
+		t.Errorf("httpClient.Timeout = %v, want none", httpClient.Timeout)
 	}
-	if httpClient.Timeout != 30*time.Second {
-		t.Fatalf("httpClient.Timeout = %v, want 30s", httpClient.Timeout)
+	proxied := (&Transport{ConnectTimeout: time.Second}).httpClient()
+	if proxied.Timeout != 0 {
+		t.Errorf("Transport.httpClient().Timeout = %v, want none", proxied.Timeout)
 	}
 }
 
 func TestAgentMailSend_Timeout(t *testing.T) {
 	originalBase := agentMailAPIBase
-	originalTimeout := httpClient.Timeout
-	agentMailAPIBase = ""
-	httpClient.Timeout = 100 * time.Millisecond
-	defer func() {
-		agentMailAPIBase = originalBase
-		httpClient.Timeout = originalTimeout
-	}()
+	defer func() { agentMailAPIBase = originalBase }()
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		time.Sleep(200 * time.Millisecond)
@@ -40,11 +39,13 @@ func TestAgentMailSend_Timeout(t *testing.T) {
 	a, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "am_test",
 		InboxID: "test@agentmail.to",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewAgentMail() error = %v", err)
 	}
-	err = a.Send(&Email{
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = a.Send(ctx, &Email{
 		To:      []string{"user@example.com"},
 		Subject: "test",
 		Body:    "body",
@@ -81,12 +82,12 @@ func TestAgentMailSend_HTTPError(t *testing.T) {
 	a, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "am_test",
 		InboxID: "test@agentmail.to",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewAgentMail() error = %v", err)
 	}
 
-	err = a.Send(&Email{
+	err = a.Send(context.Background(), &Email{
 		To:      []string{"user@example.com"},
 		Subject: "test",
 		Body:    "body",
@@ -119,12 +120,12 @@ func TestAgentMailSend_Success(t *testing.T) {
 	a, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "am_test",
 		InboxID: "test@agentmail.to",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewAgentMail() error = %v", err)
 	}
 
-	err = a.Send(&Email{
+	err = a.Send(context.Background(), &Email{
 		To:      []string{"user@example.com"},
 		Subject: "test",
 		Body:    "body",
@@ -141,11 +142,57 @@ func TestAgentMailSend_Success(t *testing.T) {
 	}
 }
 
+func TestAgentMailSend_FromOtherThanInbox(t *testing.T) {
+	originalBase := agentMailAPIBase
+	defer func() { agentMailAPIBase = originalBase }()
+
+	var posts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	agentMailAPIBase = server.URL
+	a, err := NewAgentMail(&config.AgentMailConfig{
+		APIKey:  "am_test",
+		InboxID: "test@agentmail.to",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewAgentMail() error = %v", err)
+	}
+
+	err = a.Send(context.Background(), &Email{
+		From:    "Other <other@example.com>",
+		To:      []string{"user@example.com"},
+		Subject: "test",
+		Body:    "body",
+	})
+	want := "agentmail sends only from its inbox test@agentmail.to, not Other <other@example.com>"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+	if posts != 0 {
+		t.Fatalf("Send() made %d requests, want none", posts)
+	}
+
+	// Naming the inbox itself is fine.
+	err = a.Send(context.Background(), &Email{
+		From:    "Agent <Test@agentmail.to>",
+		To:      []string{"user@example.com"},
+		Subject: "test",
+		Body:    "body",
+	})
+	if err != nil {
+		t.Fatalf("Send() from the inbox error = %v", err)
+	}
+}
+
 func TestNewAgentMail_RequiresAPIKey(t *testing.T) {
 	_, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "",
 		InboxID: "test@agentmail.to",
-	})
+	}, nil)
 	if err == nil {
 		t.Fatal("NewAgentMail() should fail without API key")
 	}
@@ -155,7 +202,7 @@ func TestNewAgentMail_RequiresInboxID(t *testing.T) {
 	_, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "am_test",
 		InboxID: "",
-	})
+	}, nil)
 	if err == nil {
 		t.Fatal("NewAgentMail() should fail without inbox ID")
 	}
@@ -165,7 +212,7 @@ func TestNewAgentMail_Success(t *testing.T) {
 	am, err := NewAgentMail(&config.AgentMailConfig{
 		APIKey:  "am_test",
 		InboxID: "test@agentmail.to",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewAgentMail() error = %v", err)
 	}
@@ -181,7 +228,7 @@ func TestNewSMTP_Success(t *testing.T) {
 		Username: "user",
 		Password: "pass",
 		UseTLS:   true,
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewSMTP() error = %v", err)
 	}
@@ -196,7 +243,7 @@ func TestNewProton_Success(t *testing.T) {
 		Port:     1025,
 		Username: "user",
 		Password: "pass",
-	})
+	}, nil)
 	if err != nil {
 		t.Fatalf("NewProton() error = %v", err)
 	}
@@ -204,3 +251,37 @@ func TestNewProton_Success(t *testing.T) {
 		t.Errorf("Name() = %q, want 'proton'", proton.Name())
 	}
 }
+
+func TestAgentMailSend_ContextCanceled(t *testing.T) {
+	originalBase := agentMailAPIBase
+	defer func() { agentMailAPIBase = originalBase }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	agentMailAPIBase = server.URL
+	a, err := NewAgentMail(&config.AgentMailConfig{
+		APIKey:  "am_test",
+		InboxID: "test@agentmail.to",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewAgentMail() error = %v", err)
+	}
+
+	err = a.Send(ctx, &Email{
+		To:      []string{"user@example.com"},
+		Subject: "test",
+		Body:    "body",
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Send() error = %v, want context.Canceled", err)
+	}
+}
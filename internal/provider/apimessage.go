@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// apiMessage is an email flattened into the fields that transactional
+// mail APIs take instead of MIME.
+type apiMessage struct {
+	From        string
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []attachmentFile
+	Headers     []Header
+	Tags        []string
+}
+
+// newAPIMessage flattens email. A raw message is parsed, and its envelope
+// recipients missing from the To and Cc headers become Bcc.
+func newAPIMessage(defaultFrom string, email *Email) (*apiMessage, error) {
+	msg := &apiMessage{
+		From:    sanitizeHeaderValue(defaultFrom),
+		Headers: email.Headers,
+		Tags:    email.Tags,
+	}
+	if email.From != "" {
+		msg.From = sanitizeHeaderValue(email.From)
+	}
+
+	if email.Raw != nil {
+		parsed, err := parseRawMessage(email.Raw)
+		if err != nil {
+			return nil, err
+		}
+		msg.To, msg.Cc, msg.Bcc = envelopeSplit(parsed, email.envelopeRecipients())
+		msg.Subject = parsed.Subject
+		msg.Text = parsed.Text
+		msg.HTML = parsed.HTML
+		for _, att := range parsed.Attachments {
+			file, err := readAttachment(att)
+			if err != nil {
+				return nil, err
+			}
+			msg.Attachments = append(msg.Attachments, file)
+		}
+	} else {
+		msg.To = sanitizeAddressList(email.To)
+		msg.Cc = sanitizeAddressList(email.Cc)
+		msg.Bcc = sanitizeAddressList(email.Bcc)
+		msg.Subject = sanitizeHeaderValue(email.Subject)
+		if email.HTML {
+			msg.HTML = email.Body
+		} else {
+			msg.Text = email.Body
+		}
+		for _, att := range email.Attachments {
+			file, err := readAttachment(att)
+			if err != nil {
+				return nil, err
+			}
+			msg.Attachments = append(msg.Attachments, file)
+		}
+	}
+
+	if msg.From == "" {
+		return nil, fmt.Errorf("from address is required")
+	}
+	if len(msg.To)+len(msg.Cc)+len(msg.Bcc) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+	return msg, nil
+}
+
+type jsonAttachment struct {
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Content     string `json:"content"` // base64 encoded
+}
+
+// jsonEmail is the JSON form of an email that webhooks and plugins
+// receive.
+type jsonEmail struct {
+	From        string            `json:"from"`
+	To          []string          `json:"to"`
+	Cc          []string          `json:"cc,omitempty"`
+	Bcc         []string          `json:"bcc,omitempty"`
+	Subject     string            `json:"subject"`
+	Text        string            `json:"text,omitempty"`
+	HTML        string            `json:"html,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Attachments []jsonAttachment  `json:"attachments,omitempty"`
+	// Raw is the original message, base64 encoded, for raw sends.
+	Raw string `json:"raw,omitempty"`
+}
+
+// newJSONEmail converts msg, attaching the original message if raw is
+// set.
+func newJSONEmail(msg *apiMessage, raw []byte) jsonEmail {
+	j := jsonEmail{
+		From:    msg.From,
+		To:      msg.To,
+		Cc:      msg.Cc,
+		Bcc:     msg.Bcc,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+		Tags:    msg.Tags,
+	}
+	if j.To == nil {
+		j.To = []string{}
+	}
+	if len(msg.Headers) > 0 {
+		j.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			j.Headers[h.Name] = h.Value
+		}
+	}
+	for _, file := range msg.Attachments {
+		j.Attachments = append(j.Attachments, jsonAttachment{
+			Filename:    file.name,
+			ContentType: file.contentType,
+			Content:     base64.StdEncoding.EncodeToString(file.content),
+		})
+	}
+	if raw != nil {
+		j.Raw = base64.StdEncoding.EncodeToString(raw)
+	}
+	return j
+}
+
+// doAPIRequest sends req and, for an error status, returns apiError's
+// reading of the response body.
+func doAPIRequest(client *http.Client, req *http.Request, apiError func(status int, body []byte) error) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return responseError(resp, apiError(resp.StatusCode, respBody))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package provider
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeAPI records the last request made to a transactional mail API and
+// answers with a fixed status and body.
+type fakeAPI struct {
+	mu     sync.Mutex
+	req    *http.Request
+	body   []byte
+	status int
+	reply  string
+}
+
+// newFakeAPI points *base at a fake server for the duration of the test.
+func newFakeAPI(t *testing.T, base *string, status int, reply string) *fakeAPI {
+	t.Helper()
+
+	f := &fakeAPI{status: status, reply: reply}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.req, f.body = r, body
+		f.mu.Unlock()
+		w.WriteHeader(f.status)
+		_, _ = w.Write([]byte(f.reply))
+	}))
+	t.Cleanup(server.Close)
+
+	orig := *base
+	*base = server.URL
+	t.Cleanup(func() { *base = orig })
+	return f
+}
+
+func (f *fakeAPI) last() (*http.Request, []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.req, f.body
+}
+
+func TestNewAPIMessage_RawSplitsEnvelope(t *testing.T) {
+	raw := "From: me@example.com\r\nTo: alice@example.com\r\nSubject: Raw\r\n\r\nHello\r\n"
+	msg, err := newAPIMessage("me@example.com", &Email{
+		To:   []string{"alice@example.com", "hidden@example.com"},
+		Tags: []string{"raw"},
+		Raw:  []byte(raw),
+	})
+	if err != nil {
+		t.Fatalf("newAPIMessage() error = %v", err)
+	}
+	if len(msg.To) != 1 || msg.To[0] != "alice@example.com" {
+		t.Fatalf("To = %v, want [alice@example.com]", msg.To)
+	}
+	if len(msg.Bcc) != 1 || msg.Bcc[0] != "hidden@example.com" {
+		t.Fatalf("Bcc = %v, want [hidden@example.com]", msg.Bcc)
+	}
+	if msg.Subject != "Raw" || msg.Text != "Hello\r\n" || len(msg.Tags) != 1 {
+		t.Fatalf("message = %+v", msg)
+	}
+}
+
+func TestNewAPIMessage_RequiresRecipient(t *testing.T) {
+	if _, err := newAPIMessage("me@example.com", &Email{Subject: "x", Body: "y"}); err == nil {
+		t.Fatal("newAPIMessage() error = nil, want error")
+	}
+}
@@ -0,0 +1,22 @@
+package provider
+
+import "context"
+
+// Drafter is implemented by providers that can save a message as a draft
+// for a person to review and send later.
+type Drafter interface {
+	// CreateDraft saves email as a draft and returns its ID, which may be
+	// empty if the server does not report one.
+	CreateDraft(ctx context.Context, email *Email) (string, error)
+	ListDrafts(ctx context.Context, limit int) ([]Draft, error)
+	SendDraft(ctx context.Context, id string) error
+	DeleteDraft(ctx context.Context, id string) error
+}
+
+// Draft summarizes a saved draft.
+type Draft struct {
+	ID      string
+	To      string
+	Subject string
+	Date    string
+}
@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// DryRun describes what sending an email would do, without contacting
+// the provider: the envelope and the message email-cli builds for it.
+type DryRun struct {
+	// From is the sender; To, Cc and Bcc are the envelope recipients,
+	// with Bcc those that appear in no header.
+	From string
+	To   []string
+	Cc   []string
+	Bcc  []string
+
+	// Header is the message header, in order.
+	Header []Header
+	// Parts is the MIME tree, depth first.
+	Parts   []DryRunPart
+	Message []byte
+}
+
+// DryRunPart is one node of a message's MIME tree. Size is the decoded
+// content size of a leaf, or the encoded size of a multipart container.
+type DryRunPart struct {
+	Depth       int
+	ContentType string
+	Filename    string
+	Size        int
+}
+
+// NewDryRun checks the sender and recipients, reads the attachments and
+// builds the message that would be sent for email.
+func NewDryRun(defaultFrom string, email *Email) (*DryRun, error) {
+	from := defaultFrom
+	if email.From != "" {
+		from = email.From
+	}
+	from = sanitizeHeaderValue(from)
+	if from == "" {
+		return nil, fmt.Errorf("from address is required")
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+
+	recipients := email.envelopeRecipients()
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one recipient is required")
+	}
+	for _, addr := range recipients {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", addr, err)
+		}
+	}
+
+	d := &DryRun{From: from}
+	if email.Raw != nil {
+		parsed, err := parseRawMessage(email.Raw)
+		if err != nil {
+			return nil, err
+		}
+		d.Message = email.Raw
+		d.To, d.Cc, d.Bcc = envelopeSplit(parsed, recipients)
+	} else {
+		msg, err := buildMessage(from, email)
+		if err != nil {
+			return nil, err
+		}
+		d.Message = msg
+		d.To = sanitizeAddressList(email.To)
+		d.Cc = sanitizeAddressList(email.Cc)
+		d.Bcc = sanitizeAddressList(email.Bcc)
+	}
+
+	d.Header = orderedHeader(d.Message)
+	msg, err := mail.ReadMessage(bytes.NewReader(d.Message))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	if err := d.walk(textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DryRun) walk(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("failed to read mime part: %w", err)
+		}
+		d.Parts = append(d.Parts, DryRunPart{Depth: depth, ContentType: mediaType, Size: len(content)})
+		mr := multipart.NewReader(bytes.NewReader(content), params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read mime part: %w", err)
+			}
+			if err := d.walk(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode mime part: %w", err)
+	}
+	_, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	d.Parts = append(d.Parts, DryRunPart{Depth: depth, ContentType: mediaType, Filename: filename, Size: len(content)})
+	return nil
+}
+
+// orderedHeader returns a message's header fields in order, with folded
+// lines joined.
+func orderedHeader(msg []byte) []Header {
+	var fields []Header
+	for _, line := range strings.Split(string(msg), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, _ := strings.Cut(line, ":")
+		fields = append(fields, Header{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return fields
+}
+
+// Previewer is implemented by providers that can build the exact request
+// they would make for an email without making it.
+type Previewer interface {
+	Preview(email *Email) (*Preview, error)
+}
+
+// Preview is a request a provider would make, with credentials redacted.
+type Preview struct {
+	// Request says what would be called, such as "POST https://...".
+	Request string
+	Header  http.Header
+	Body    []byte
+}
+
+// previewRequest reads req into a Preview, redacting Authorization and
+// the named headers.
+func previewRequest(req *http.Request, secretHeaders ...string) (*Preview, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("failed to read request: %w", err)
+		}
+	}
+	header := req.Header.Clone()
+	for _, name := range append([]string{"Authorization"}, secretHeaders...) {
+		if header.Get(name) != "" {
+			header.Set(name, "[REDACTED]")
+		}
+	}
+	return &Preview{
+		Request: req.Method + " " + req.URL.Redacted(),
+		Header:  header,
+		Body:    body,
+	}, nil
+}
@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// DSN requests RFC 3461 delivery status notifications for a message.
+// Only SMTP-based providers can honour it.
+type DSN struct {
+	Notify []string // success, failure, delay, or never
+	Return string   // full or hdrs
+	EnvID  string
+}
+
+// NewDSN validates the requested notify conditions and return type and
+// assigns a random envelope ID when envID is empty.
+func NewDSN(notify []string, ret, envID string) (*DSN, error) {
+	dsn := &DSN{}
+
+	seen := make(map[string]bool)
+	for _, n := range notify {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "" || seen[n] {
+			continue
+		}
+		switch n {
+		case "success", "failure", "delay", "never":
+		default:
+			return nil, fmt.Errorf("invalid dsn condition %q: must be success, failure, delay, or never", n)
+		}
+		seen[n] = true
+		dsn.Notify = append(dsn.Notify, n)
+	}
+	if seen["never"] && len(dsn.Notify) > 1 {
+		return nil, fmt.Errorf("dsn condition \"never\" cannot be combined with other conditions")
+	}
+
+	ret = strings.ToLower(strings.TrimSpace(ret))
+	switch ret {
+	case "", "full", "hdrs":
+		dsn.Return = ret
+	default:
+		return nil, fmt.Errorf("invalid dsn return %q: must be full or hdrs", ret)
+	}
+
+	if envID == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate dsn envelope id: %w", err)
+		}
+		envID = hex.EncodeToString(b)
+	}
+	dsn.EnvID = envID
+
+	return dsn, nil
+}
+
+// mailParams returns the MAIL FROM parameters for this DSN request.
+func (d *DSN) mailParams() []string {
+	var params []string
+	if d.Return != "" {
+		params = append(params, "RET="+strings.ToUpper(d.Return))
+	}
+	if d.EnvID != "" {
+		params = append(params, "ENVID="+xtext(d.EnvID))
+	}
+	return params
+}
+
+// rcptParams returns the RCPT TO parameters for this DSN request.
+func (d *DSN) rcptParams(rcpt string) []string {
+	var params []string
+	if len(d.Notify) > 0 {
+		params = append(params, "NOTIFY="+strings.ToUpper(strings.Join(d.Notify, ",")))
+	}
+	// ORCPT's rfc822 address type cannot carry UTF-8 addresses.
+	if isASCII(rcpt) {
+		params = append(params, "ORCPT=rfc822;"+xtext(rcpt))
+	}
+	return params
+}
+
+// xtext encodes a value as RFC 3461 xtext: printable ASCII other than
+// "+" and "=" passes through, everything else becomes "+XX".
+func xtext(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= '!' && c <= '~' && c != '+' && c != '=' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "+%02X", c)
+	}
+	return b.String()
+}
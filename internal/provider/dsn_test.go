@@ -0,0 +1,45 @@
+package provider
+
+import "testing"
+
+func TestNewDSN_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		notify  []string
+		ret     string
+		wantErr bool
+	}{
+		{name: "all conditions", notify: []string{"success", "failure", "delay"}, ret: "full"},
+		{name: "never alone", notify: []string{"never"}},
+		{name: "headers only", ret: "HDRS"},
+		{name: "never combined", notify: []string{"never", "failure"}, wantErr: true},
+		{name: "unknown condition", notify: []string{"bounce"}, wantErr: true},
+		{name: "unknown return", ret: "body", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dsn, err := NewDSN(tt.notify, tt.ret, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewDSN() expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDSN() error = %v", err)
+			}
+			if dsn.EnvID == "" {
+				t.Fatal("NewDSN() did not generate an envelope id")
+			}
+		})
+	}
+}
+
+func TestXText(t *testing.T) {
+	got := xtext("a+b=c dé")
+	want := "a+2Bb+3Dc+20d+C3+A9"
+	if got != want {
+		t.Fatalf("xtext() = %q, want %q", got, want)
+	}
+}
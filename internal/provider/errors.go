@@ -0,0 +1,284 @@
+package provider
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
+)
+
+// StatusError is an error response from an HTTP API. It reads as the
+// provider's own error; Status and RetryAfter are kept so the failure can
+// be classified.
+type StatusError struct {
+	Status     int
+	RetryAfter time.Duration
+	Err        error
+}
+
+func statusError(status int, err error) error {
+	return &StatusError{Status: status, Err: err}
+}
+
+// responseError wraps err, read from an error response, as a StatusError.
+func responseError(resp *http.Response, err error) error {
+	retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"))
+	return &StatusError{Status: resp.StatusCode, RetryAfter: retryAfter, Err: err}
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// transientError marks a failure the provider itself reported as
+// temporary, such as a plugin response with "transient": true.
+type transientError struct {
+	err error
+}
+
+func (e *transientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *transientError) Unwrap() error {
+	return e.err
+}
+
+// exTempFail is the sysexits.h status sendmail-compatible commands exit
+// with when delivery should be tried again later.
+const exTempFail = 75
+
+// ErrorKind is how a send failed, which decides whether it is worth
+// trying again; see Classify.
+type ErrorKind int
+
+const (
+	// KindPermanent failures would recur on any attempt through any
+	// provider, such as a rejected recipient or an invalid message.
+	KindPermanent ErrorKind = iota
+	// KindTransient failures might not recur: network failures and
+	// timeouts, HTTP 408 and 5xx responses, 4xx SMTP replies, and pipe
+	// commands exiting with EX_TEMPFAIL.
+	KindTransient
+	// KindAuth failures are rejected credentials. They recur until the
+	// provider's config is fixed.
+	KindAuth
+	// KindRateLimited failures are the provider, or a client-side rate
+	// limit or quota, asking for sends to slow down. RetryAfter says for
+	// how long, when known.
+	KindRateLimited
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case KindTransient:
+		return "transient"
+	case KindAuth:
+		return "auth"
+	case KindRateLimited:
+		return "rate limited"
+	default:
+		return "permanent"
+	}
+}
+
+// Classify returns the kind of failure err is. A send the user
+// interrupted is permanent.
+func Classify(err error) ErrorKind {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return KindPermanent
+	}
+
+	var temporary *transientError
+	if errors.As(err, &temporary) {
+		return KindTransient
+	}
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return KindRateLimited
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusKind(statusErr.Status)
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return statusKind(googleErr.Code)
+	}
+	var tokenErr *oauth2.RetrieveError
+	if errors.As(err, &tokenErr) {
+		if tokenErr.Response == nil {
+			return KindPermanent
+		}
+		// The token endpoint answers a revoked or expired refresh token
+		// with 400 invalid_grant.
+		if tokenErr.Response.StatusCode == http.StatusBadRequest {
+			return KindAuth
+		}
+		return statusKind(tokenErr.Response.StatusCode)
+	}
+	var replyErr *textproto.Error
+	if errors.As(err, &replyErr) {
+		return replyKind(replyErr)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() == exTempFail {
+			return KindTransient
+		}
+		return KindPermanent
+	}
+
+	// A certificate the client rejects will be rejected again.
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return KindPermanent
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return KindTransient
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) {
+		return KindTransient
+	}
+	return KindPermanent
+}
+
+// IsTransient reports whether a send failed for a reason that another
+// attempt, or another provider, might not hit: a transient or rate
+// limited failure (see Classify) that did not leave the email possibly
+// delivered (see IsAmbiguous).
+func IsTransient(err error) bool {
+	switch Classify(err) {
+	case KindTransient, KindRateLimited:
+		return !IsAmbiguous(err)
+	}
+	return false
+}
+
+func statusKind(status int) ErrorKind {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return KindAuth
+	case status == http.StatusTooManyRequests:
+		return KindRateLimited
+	case status == http.StatusRequestTimeout || status >= 500:
+		return KindTransient
+	}
+	return KindPermanent
+}
+
+// replyKind classifies an SMTP or LMTP reply. 4xx replies are temporary;
+// those with a 4.7.x enhanced status code are policy deferrals such as
+// rate limits and greylisting.
+func replyKind(reply *textproto.Error) ErrorKind {
+	switch {
+	case reply.Code == 530 || reply.Code == 534 || reply.Code == 535:
+		return KindAuth
+	case reply.Code >= 400 && reply.Code < 500:
+		if strings.HasPrefix(reply.Msg, "4.7.") {
+			return KindRateLimited
+		}
+		return KindTransient
+	}
+	return KindPermanent
+}
+
+// ambiguousError marks a send that failed after the provider may already
+// have accepted the email, such as a connection lost while waiting for
+// the reply to SMTP DATA.
+type ambiguousError struct {
+	err error
+}
+
+func (e *ambiguousError) Error() string {
+	return e.err.Error() + " (the message may have been delivered)"
+}
+
+func (e *ambiguousError) Unwrap() error {
+	return e.err
+}
+
+// IsAmbiguous reports whether a send failed in a way that leaves it
+// unknown whether the email was delivered. Sending it again could deliver
+// it twice.
+func IsAmbiguous(err error) bool {
+	var ambiguous *ambiguousError
+	return errors.As(err, &ambiguous)
+}
+
+// trackAmbiguous calls send, marking its error ambiguous if an HTTP
+// request had been sent in full and was still waiting for its response:
+// the provider may have acted on it.
+func trackAmbiguous(ctx context.Context, send func(context.Context) error) error {
+	var waiting atomic.Bool
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			waiting.Store(info.Err == nil)
+		},
+		GotFirstResponseByte: func() {
+			waiting.Store(false)
+		},
+	})
+	err := send(ctx)
+	if err != nil && waiting.Load() && !IsAmbiguous(err) {
+		return &ambiguousError{err}
+	}
+	return err
+}
+
+// RetryAfter returns how long the provider asked to wait before trying
+// again, if it did: an HTTP Retry-After header, or when a client-side
+// rate limit or quota allows the next send.
+func RetryAfter(err error) (time.Duration, bool) {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return max(time.Until(limitErr.Reset), 0), true
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		return statusErr.RetryAfter, true
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		return parseRetryAfter(googleErr.Header.Get("Retry-After"))
+	}
+	return 0, false
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number
+// of seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,116 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Member is one provider in a failover chain or pool. Timeout, when set,
+// bounds each attempt through it.
+type Member struct {
+	Name     string
+	Provider Provider
+	Timeout  time.Duration
+	// Limiter, when set, enforces the member's own rate limit and quotas.
+	Limiter *Limiter
+}
+
+// Failover sends through its members in order. A member that fails with
+// a transient error (see IsTransient) is skipped in favour of the next;
+// a permanent error is returned at once, since another provider would
+// reject the message too.
+type Failover struct {
+	members []Member
+
+	// OnFailover, when set, is called each time a member fails and the
+	// next one is tried.
+	OnFailover func(failed Member, err error, next Member)
+
+	delivered *Member
+}
+
+func NewFailover(members []Member) (*Failover, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("failover needs at least one provider")
+	}
+	return &Failover{members: members}, nil
+}
+
+func (f *Failover) Name() string {
+	return "failover"
+}
+
+func (f *Failover) Send(ctx context.Context, email *Email) error {
+	f.delivered = nil
+
+	var failures []string
+	for i := range f.members {
+		m := &f.members[i]
+		err := m.send(ctx, email)
+		if err == nil {
+			f.delivered = m
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !IsTransient(err) {
+			if len(failures) == 0 {
+				return fmt.Errorf("%s: %w", m.Name, err)
+			}
+			return fmt.Errorf("%s: %w (after %s)", m.Name, err, strings.Join(failures, "; "))
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %v", m.Name, err))
+		if i+1 < len(f.members) && f.OnFailover != nil {
+			f.OnFailover(*m, err, f.members[i+1])
+		}
+	}
+	return fmt.Errorf("all %d providers failed: %s", len(f.members), strings.Join(failures, "; "))
+}
+
+// send sends email through m, within its limits and bounded by its
+// timeout. Waiting for the rate limit does not count toward the timeout.
+func (m *Member) send(ctx context.Context, email *Email) error {
+	return m.Limiter.Limit(ctx, email, func() error {
+		return trackAmbiguous(ctx, func(ctx context.Context) error {
+			return m.sendTimeout(ctx, email)
+		})
+	})
+}
+
+func (m *Member) sendTimeout(ctx context.Context, email *Email) error {
+	if m.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Timeout)
+		defer cancel()
+	}
+	err := m.Provider.Send(ctx, email)
+	if err != nil && m.Timeout > 0 && ctx.Err() == context.DeadlineExceeded {
+		// Keep the provider's error: it may say the email was possibly
+		// delivered, which must stop it being sent again elsewhere.
+		return fmt.Errorf("timed out after %s: %w", m.Timeout, err)
+	}
+	return err
+}
+
+// Delegator is implemented by providers that send through other
+// providers. Delivered returns the member that delivered the last email
+// sent, if any.
+type Delegator interface {
+	Delivered() (Member, bool)
+}
+
+func (f *Failover) Delivered() (Member, bool) {
+	if f.delivered == nil {
+		return Member{}, false
+	}
+	return *f.delivered, true
+}
+
+// Members returns the chain's providers, in the order they are tried.
+func (f *Failover) Members() []Member {
+	return f.members
+}
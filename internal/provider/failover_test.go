@@ -0,0 +1,179 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubProvider fails with err, if set, and counts its sends.
+type stubProvider struct {
+	name  string
+	err   error
+	sends int
+}
+
+func (s *stubProvider) Name() string {
+	return s.name
+}
+
+func (s *stubProvider) Send(ctx context.Context, email *Email) error {
+	s.sends++
+	return s.err
+}
+
+func TestFailoverSend(t *testing.T) {
+	unavailable := fmt.Errorf("rcpt to failed: %w", &textproto.Error{Code: 421, Msg: "try again later"})
+	badRecipient := fmt.Errorf("rcpt to failed: %w", &textproto.Error{Code: 550, Msg: "no such user"})
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantSends []int
+		delivered string
+		wantErr   string
+	}{
+		{"first delivers", []error{nil, nil}, []int{1, 0}, "a", ""},
+		{"transient falls over", []error{unavailable, statusError(503, errors.New("down"))}, []int{1, 1, 1}, "c", ""},
+		{"permanent stops", []error{badRecipient, nil}, []int{1, 0}, "", "a: rcpt to failed: 550"},
+		{"all fail", []error{unavailable, unavailable}, []int{1, 1}, "", "all 2 providers failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stubs []*stubProvider
+			var members []Member
+			for i := range tt.wantSends {
+				stub := &stubProvider{name: "stub"}
+				if i < len(tt.errs) {
+					stub.err = tt.errs[i]
+				}
+				stubs = append(stubs, stub)
+				members = append(members, Member{Name: string(rune('a' + i)), Provider: stub})
+			}
+			f, err := NewFailover(members)
+			if err != nil {
+				t.Fatalf("NewFailover() error = %v", err)
+			}
+			var failedOver []string
+			f.OnFailover = func(failed Member, err error, next Member) {
+				failedOver = append(failedOver, failed.Name+"->"+next.Name)
+			}
+
+			err = f.Send(context.Background(), &Email{To: []string{"a@example.com"}})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Send() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Send() error = %v, want %q", err, tt.wantErr)
+			}
+			for i, stub := range stubs {
+				if stub.sends != tt.wantSends[i] {
+					t.Errorf("member %d sent %d times, want %d", i, stub.sends, tt.wantSends[i])
+				}
+			}
+			m, ok := f.Delivered()
+			if ok != (tt.delivered != "") || m.Name != tt.delivered {
+				t.Errorf("Delivered() = %q, %v, want %q", m.Name, ok, tt.delivered)
+			}
+			if tt.delivered == "c" && strings.Join(failedOver, ",") != "a->b,b->c" {
+				t.Errorf("OnFailover calls = %v", failedOver)
+			}
+		})
+	}
+}
+
+func TestFailoverSend_MemberTimeout(t *testing.T) {
+	slow := &blockingProvider{}
+	backup := &stubProvider{name: "stub"}
+	f, err := NewFailover([]Member{
+		{Name: "slow", Provider: slow, Timeout: 10 * time.Millisecond},
+		{Name: "backup", Provider: backup},
+	})
+	if err != nil {
+		t.Fatalf("NewFailover() error = %v", err)
+	}
+	if err := f.Send(context.Background(), &Email{To: []string{"a@example.com"}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if m, _ := f.Delivered(); m.Name != "backup" {
+		t.Fatalf("delivered by %q, want backup", m.Name)
+	}
+}
+
+func TestFailoverSend_AmbiguousTimeout(t *testing.T) {
+	backup := &stubProvider{name: "stub"}
+	f, err := NewFailover([]Member{
+		{Name: "slow", Provider: ambiguousBlockingProvider{}, Timeout: 10 * time.Millisecond},
+		{Name: "backup", Provider: backup},
+	})
+	if err != nil {
+		t.Fatalf("NewFailover() error = %v", err)
+	}
+	err = f.Send(context.Background(), &Email{To: []string{"a@example.com"}})
+	if err == nil || !IsAmbiguous(err) {
+		t.Fatalf("Send() error = %v, want an ambiguous error", err)
+	}
+	if backup.sends != 0 {
+		t.Fatalf("backup sent %d times, want 0 after an ambiguous timeout", backup.sends)
+	}
+}
+
+type blockingProvider struct{}
+
+func (blockingProvider) Name() string { return "blocking" }
+
+func (blockingProvider) Send(ctx context.Context, email *Email) error {
+	<-ctx.Done()
+	return fmt.Errorf("send aborted: %w", ctx.Err())
+}
+
+// ambiguousBlockingProvider times out waiting for the reply to SMTP DATA,
+// when the server may already have accepted the email.
+type ambiguousBlockingProvider struct{}
+
+func (ambiguousBlockingProvider) Name() string { return "blocking" }
+
+func (ambiguousBlockingProvider) Send(ctx context.Context, email *Email) error {
+	<-ctx.Done()
+	return &ambiguousError{fmt.Errorf("data reply: %w", ctx.Err())}
+}
+
+func TestIsTransient(t *testing.T) {
+	exitErr := func(code int) error {
+		err := exec.Command("sh", "-c", fmt.Sprintf("exit %d", code)).Run()
+		return fmt.Errorf("pipe command sh failed: %w", err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", fmt.Errorf("dial failed: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"http 503", statusError(503, errors.New("sendgrid error: busy (status 503)")), true},
+		{"http 429", statusError(429, errors.New("rate limited")), true},
+		{"http 401", statusError(401, errors.New("bad key")), false},
+		{"http 422", statusError(422, errors.New("invalid recipient")), false},
+		{"smtp 421", fmt.Errorf("mail from failed: %w", &textproto.Error{Code: 421, Msg: "closing"}), true},
+		{"smtp 535", fmt.Errorf("auth failed: %w", &textproto.Error{Code: 535, Msg: "bad credentials"}), false},
+		{"plugin transient", &transientError{err: errors.New("upstream timed out")}, true},
+		{"pipe tempfail", exitErr(exTempFail), true},
+		{"pipe failure", exitErr(1), false},
+		{"interrupted", fmt.Errorf("send aborted: %w", context.Canceled), false},
+		{"timed out", fmt.Errorf("send aborted: %w", context.DeadlineExceeded), true},
+		{"other", errors.New("at least one recipient is required"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// File writes each message into a directory instead of sending it, for
+// development and CI. Messages are written as <time>-<random>.eml files,
+// or with Maildir set, delivered into a Maildir through tmp/ and new/.
+// Envelope-only recipients are kept in a Bcc header, so the file records
+// everyone the message would have gone to.
+type File struct {
+	from   string
+	config *config.FileConfig
+}
+
+func NewFile(from string, cfg *config.FileConfig) (*File, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("file path is required")
+	}
+	return &File{from: from, config: cfg}, nil
+}
+
+func (f *File) Name() string {
+	return "file"
+}
+
+func (f *File) Send(ctx context.Context, email *Email) error {
+	if len(email.envelopeRecipients()) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+	msg, err := bccHeaderMessage(email, func(e *Email) ([]byte, error) {
+		return buildMessage(f.from, e)
+	})
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("send aborted: %w", err)
+	}
+
+	if f.config.Maildir {
+		// Maildir files use local line endings, as delivery agents write them.
+		return deliverMaildir(f.config.Path, bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n")))
+	}
+	return writeEML(f.config.Path, crlf(msg))
+}
+
+// writeEML writes msg to a new .eml file in dir. The file is written under
+// a dot name and renamed, so watchers never see a partial message.
+func writeEML(dir string, msg []byte) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("failed to name message file: %w", err)
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	tmp := filepath.Join(dir, "."+name)
+	if err := os.WriteFile(tmp, msg, 0o600); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return nil
+}
+
+var maildirDeliveries atomic.Int64
+
+// deliverMaildir delivers msg into the Maildir at dir, creating it if
+// needed: the message is written to tmp/ under a unique name, synced, and
+// renamed into new/.
+func deliverMaildir(dir string, msg []byte) error {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return fmt.Errorf("failed to create maildir %s: %w", dir, err)
+		}
+	}
+
+	name := maildirName(time.Now())
+	tmp := filepath.Join(dir, "tmp", name)
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to deliver to maildir: %w", err)
+	}
+	_, err = file.Write(msg)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(dir, "new", name))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to deliver to maildir: %w", err)
+	}
+	return nil
+}
+
+// maildirName returns a unique Maildir file name of the form
+// <seconds>.M<microseconds>P<pid>Q<count>.<host>.
+func maildirName(now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	host = strings.NewReplacer("/", `\057`, ":", `\072`).Replace(host)
+	return fmt.Sprintf("%d.M%dP%dQ%d.%s", now.Unix(), now.Nanosecond()/1000, os.Getpid(), maildirDeliveries.Add(1), host)
+}
@@ -0,0 +1,102 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func TestFileSend_EML(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "out")
+	f, err := NewFile("dev@example.com", &config.FileConfig{Path: dir})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err := f.Send(context.Background(), &Email{
+			To:          []string{"alice@example.com"},
+			Bcc:         []string{"audit@example.com"},
+			Subject:     "Report",
+			Body:        "Attached",
+			Attachments: []Attachment{{Filename: "report.txt", Content: []byte("numbers")}},
+		})
+		if err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d files, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".eml") || strings.HasPrefix(entry.Name(), ".") {
+			t.Fatalf("unexpected file %s", entry.Name())
+		}
+	}
+
+	msg, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	for _, want := range []string{
+		"Bcc: audit@example.com\r\nFrom: dev@example.com\r\nTo: alice@example.com\r\nSubject: Report\r\n",
+		`filename="report.txt"`,
+	} {
+		if !strings.Contains(string(msg), want) {
+			t.Fatalf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestFileSend_Maildir(t *testing.T) {
+	dir := t.TempDir()
+	f, err := NewFile("dev@example.com", &config.FileConfig{Path: dir, Maildir: true})
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+
+	raw := "From: dev@example.com\r\nTo: ops@example.com\r\nSubject: Raw\r\n\r\nBody\r\n"
+	if err := f.Send(context.Background(), &Email{To: []string{"ops@example.com"}, Raw: []byte(raw)}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	for sub, want := range map[string]int{"tmp": 0, "new": 1, "cur": 0} {
+		entries, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil {
+			t.Fatalf("ReadDir(%s) error = %v", sub, err)
+		}
+		if len(entries) != want {
+			t.Fatalf("%s has %d entries, want %d", sub, len(entries), want)
+		}
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "new"))
+	msg, err := os.ReadFile(filepath.Join(dir, "new", entries[0].Name()))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if want := strings.ReplaceAll(raw, "\r\n", "\n"); string(msg) != want {
+		t.Fatalf("message = %q, want %q", msg, want)
+	}
+}
+
+func TestMaildirName_Unique(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		name := maildirName(time.Unix(1700000000, 0))
+		if seen[name] || strings.ContainsAny(name, "/:") {
+			t.Fatalf("bad or duplicate name %q", name)
+		}
+		seen[name] = true
+	}
+}
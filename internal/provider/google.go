@@ -9,51 +9,90 @@ import (
 	"fmt"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/mail"
 	"net/textproto"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tnm/email-cli/internal/config"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
+	"golang.org/x/oauth2/jwt"
 	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/googleapi"
 	"google.golang.org/api/option"
 )
 
 type Google struct {
-	from    string
-	config  *config.GoogleConfig
-	service *gmail.Service
+	from      string
+	config    *config.GoogleConfig
+	transport *Transport
+
+	// saveToken, if set, persists access tokens obtained by refreshing.
+	saveToken func(*oauth2.Token) error
 }
 
-func NewGoogle(from string, cfg *config.GoogleConfig) (*Google, error) {
-	g := &Google{
-		from:   from,
-		config: cfg,
-	}
+func NewGoogle(from string, cfg *config.GoogleConfig, t *Transport) (*Google, error) {
+	return &Google{
+		from:      from,
+		config:    cfg,
+		transport: t,
+	}, nil
+}
 
-	if err := g.initService(); err != nil {
-		return nil, err
-	}
+// googleEndpoint is a variable so tests can point OAuth at a fake server.
+var googleEndpoint = google.Endpoint
 
-	return g, nil
-}
+// gmailEndpoint overrides the Gmail API base URL when set, for tests.
+var gmailEndpoint string
 
-func googleOAuthConfig(clientID, clientSecret string) *oauth2.Config {
+// googleOAuthConfig returns the OAuth client config. redirectURL is only
+// needed for the local callback flow.
+func googleOAuthConfig(clientID, clientSecret, redirectURL string) *oauth2.Config {
 	return &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
-		Endpoint:     google.Endpoint,
-		Scopes:       []string{gmail.GmailSendScope},
-		RedirectURL:  googleRedirectURL,
+		Endpoint:     googleEndpoint,
+		Scopes:       []string{gmail.GmailSendScope, gmail.GmailComposeScope},
+		RedirectURL:  redirectURL,
 	}
 }
 
-func (g *Google) initService() error {
-	oauth2Config := googleOAuthConfig(g.config.ClientID, g.config.ClientSecret)
+// service builds a Gmail client bound to ctx, so that token refreshes
+// and API calls are both cancelled with it. scope only matters for
+// service accounts; user tokens carry whatever scopes were granted.
+func (g *Google) service(ctx context.Context, scope string) (*gmail.Service, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, g.transport.httpClient())
+
+	source, err := g.tokenSource(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	client := oauth2.NewClient(ctx, source)
+
+	opts := []option.ClientOption{option.WithHTTPClient(client)}
+	if gmailEndpoint != "" {
+		opts = append(opts, option.WithEndpoint(gmailEndpoint))
+	}
+	service, err := gmail.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gmail service: %w", err)
+	}
+
+	return service, nil
+}
+
+func (g *Google) tokenSource(ctx context.Context, scope string) (oauth2.TokenSource, error) {
+	if g.config.ServiceAccountKey != "" {
+		return g.serviceAccountTokenSource(ctx, scope)
+	}
+
+	oauth2Config := googleOAuthConfig(g.config.ClientID, g.config.ClientSecret, "")
 
 	token := &oauth2.Token{
 		AccessToken:  g.config.AccessToken,
@@ -66,61 +105,137 @@ func (g *Google) initService() error {
 		}
 	}
 
-	client := oauth2Config.Client(context.Background(), token)
+	source := oauth2Config.TokenSource(ctx, token)
+	if g.saveToken != nil {
+		source = &persistingTokenSource{base: source, last: token.AccessToken, save: g.saveToken}
+	}
+	return source, nil
+}
 
-	service, err := gmail.NewService(context.Background(), option.WithHTTPClient(client))
+// serviceAccountTokenSource signs JWTs with the service account key to
+// act as the impersonated Workspace user. The domain admin must grant the
+// service account domain-wide delegation for the requested scope
+// (gmail.send, plus gmail.compose for drafts).
+func (g *Google) serviceAccountTokenSource(ctx context.Context, scope string) (oauth2.TokenSource, error) {
+	key, err := ReadGoogleServiceAccountKey(g.config.ServiceAccountKey)
+	if err != nil {
+		return nil, err
+	}
+	jwtConfig, err := googleJWTConfig(key, scope)
 	if err != nil {
-		return fmt.Errorf("failed to create gmail service: %w", err)
+		return nil, err
 	}
 
-	g.service = service
-	return nil
+	jwtConfig.Subject = g.config.Subject
+	if jwtConfig.Subject == "" {
+		jwtConfig.Subject = g.from
+		if addr, err := mail.ParseAddress(g.from); err == nil {
+			jwtConfig.Subject = addr.Address
+		}
+	}
+	if jwtConfig.Subject == "" {
+		return nil, fmt.Errorf("google service account auth requires a subject or from address to impersonate")
+	}
+
+	return jwtConfig.TokenSource(ctx), nil
 }
 
-func (g *Google) Name() string {
-	return "google"
+func googleJWTConfig(key []byte, scope string) (*jwt.Config, error) {
+	jwtConfig, err := google.JWTConfigFromJSON(key, scope)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google service account key: %w", err)
+	}
+	return jwtConfig, nil
+}
+
+// ValidateGoogleServiceAccountKey checks that key is a service account
+// JSON key that can sign tokens.
+func ValidateGoogleServiceAccountKey(key []byte) error {
+	_, err := googleJWTConfig(key, gmail.GmailSendScope)
+	return err
 }
 
-func (g *Google) Send(email *Email) error {
-	if len(email.Bcc) == 0 {
-		return g.sendSingle(email)
+// ReadGoogleServiceAccountKey returns the service account JSON key given
+// either inline (as resolved from the keychain) or as a file path.
+func ReadGoogleServiceAccountKey(value string) ([]byte, error) {
+	if strings.HasPrefix(strings.TrimSpace(value), "{") {
+		return []byte(value), nil
 	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read google service account key: %w", err)
+	}
+	return data, nil
+}
 
-	visible := &Email{
-		To:          append([]string(nil), email.To...),
-		Cc:          append([]string(nil), email.Cc...),
-		Subject:     email.Subject,
-		Body:        email.Body,
-		HTML:        email.HTML,
-		Attachments: email.Attachments,
+// persistingTokenSource saves each newly refreshed token, so the next run
+// can reuse it and a rotated refresh token is not lost.
+type persistingTokenSource struct {
+	base oauth2.TokenSource
+	save func(*oauth2.Token) error
+
+	mu   sync.Mutex
+	last string
+}
+
+func (s *persistingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.base.Token()
+	if err != nil {
+		return nil, err
 	}
 
-	if len(visible.To)+len(visible.Cc) > 0 {
-		if err := g.sendSingle(visible); err != nil {
-			return err
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if token.AccessToken != s.last {
+		s.last = token.AccessToken
+		// The send can still succeed; the next run just refreshes again.
+		if err := s.save(token); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save refreshed OAuth token: %v\n", err)
 		}
 	}
+	return token, nil
+}
 
-	for _, bccRecipient := range sanitizeAddressList(email.Bcc) {
-		private := &Email{
-			To:          []string{bccRecipient},
-			Subject:     email.Subject,
-			Body:        email.Body,
-			HTML:        email.HTML,
-			Attachments: email.Attachments,
-		}
-		if err := g.sendSingle(private); err != nil {
-			return err
-		}
+func (g *Google) Name() string {
+	return "google"
+}
+
+func (g *Google) Send(ctx context.Context, email *Email) error {
+	service, err := g.service(ctx, gmail.GmailSendScope)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	// A single message with a Bcc header: Gmail delivers to every
+	// recipient and strips Bcc from the copies it sends.
+	raw, err := g.message(email)
+	if err != nil {
+		return err
+	}
+	return g.sendRaw(ctx, service, raw)
 }
 
-func (g *Google) sendSingle(email *Email) error {
+// message renders email for the Gmail API, which takes recipients from
+// the headers and strips the Bcc header before delivery.
+func (g *Google) message(email *Email) (string, error) {
+	msg, err := bccHeaderMessage(email, func(e *Email) ([]byte, error) {
+		raw, err := g.buildRaw(e)
+		return []byte(raw), err
+	})
+	return string(msg), err
+}
+
+// buildRaw renders email as an RFC 5322 message. An email's own From
+// overrides the configured one; Gmail sends from it only if it is one of
+// the account's send-as addresses, and otherwise rewrites it.
+func (g *Google) buildRaw(email *Email) (string, error) {
 	var msg strings.Builder
 
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", sanitizeHeaderValue(g.from)))
+	from := g.from
+	if email.From != "" {
+		from = email.From
+	}
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", sanitizeHeaderValue(from)))
 
 	to := sanitizeAddressList(email.To)
 	if len(to) > 0 {
@@ -133,10 +248,13 @@ func (g *Google) sendSingle(email *Email) error {
 	}
 
 	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", sanitizeHeaderValue(email.Subject)))
+	for _, h := range email.Headers {
+		msg.WriteString(fmt.Sprintf("%s: %s\r\n", sanitizeHeaderValue(h.Name), sanitizeHeaderValue(h.Value)))
+	}
 	msg.WriteString("MIME-Version: 1.0\r\n")
 
 	if len(email.Attachments) > 0 {
-		return g.sendWithAttachments(email, &msg)
+		return g.buildWithAttachments(email, &msg)
 	}
 
 	contentType := "text/plain"
@@ -147,10 +265,10 @@ func (g *Google) sendSingle(email *Email) error {
 	msg.WriteString("\r\n")
 	msg.WriteString(email.Body)
 
-	return g.sendRaw(msg.String())
+	return msg.String(), nil
 }
 
-func (g *Google) sendWithAttachments(email *Email, headerBuilder *strings.Builder) error {
+func (g *Google) buildWithAttachments(email *Email, headerBuilder *strings.Builder) (string, error) {
 	var buf strings.Builder
 	writer := multipart.NewWriter(&buf)
 	boundary := writer.Boundary()
@@ -169,10 +287,10 @@ func (g *Google) sendWithAttachments(email *Email, headerBuilder *strings.Builde
 	bodyHeader.Set("Content-Type", fmt.Sprintf("%s; charset=\"UTF-8\"", contentType))
 	bodyPart, err := writer.CreatePart(bodyHeader)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if _, err := bodyPart.Write([]byte(email.Body)); err != nil {
-		return fmt.Errorf("failed to write body: %w", err)
+		return "", fmt.Errorf("failed to write body: %w", err)
 	}
 
 	for _, att := range email.Attachments {
@@ -180,7 +298,7 @@ func (g *Google) sendWithAttachments(email *Email, headerBuilder *strings.Builde
 		if content == nil && att.Path != "" {
 			data, err := os.ReadFile(att.Path)
 			if err != nil {
-				return fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
+				return "", fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
 			}
 			content = data
 		}
@@ -203,28 +321,28 @@ func (g *Google) sendWithAttachments(email *Email, headerBuilder *strings.Builde
 
 		attPart, err := writer.CreatePart(attHeader)
 		if err != nil {
-			return err
+			return "", err
 		}
 
 		encoded := base64.StdEncoding.EncodeToString(content)
 		if _, err := attPart.Write([]byte(encoded)); err != nil {
-			return fmt.Errorf("failed to write attachment %s: %w", filename, err)
+			return "", fmt.Errorf("failed to write attachment %s: %w", filename, err)
 		}
 	}
 
 	if err := writer.Close(); err != nil {
-		return fmt.Errorf("failed to finalize mime message: %w", err)
+		return "", fmt.Errorf("failed to finalize mime message: %w", err)
 	}
 
-	return g.sendRaw(buf.String())
+	return buf.String(), nil
 }
 
-func (g *Google) sendRaw(raw string) error {
+func (g *Google) sendRaw(ctx context.Context, service *gmail.Service, raw string) error {
 	message := &gmail.Message{
 		Raw: base64.RawURLEncoding.EncodeToString([]byte(raw)),
 	}
 
-	_, err := g.service.Users.Messages.Send("me", message).Do()
+	_, err := service.Users.Messages.Send("me", message).Context(ctx).Do()
 	if err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
@@ -232,7 +350,95 @@ func (g *Google) sendRaw(raw string) error {
 	return nil
 }
 
-const googleRedirectURL = "http://127.0.0.1:8089/callback"
+func (g *Google) CreateDraft(ctx context.Context, email *Email) (string, error) {
+	service, err := g.service(ctx, gmail.GmailComposeScope)
+	if err != nil {
+		return "", err
+	}
+	raw, err := g.message(email)
+	if err != nil {
+		return "", err
+	}
+
+	draft, err := service.Users.Drafts.Create("me", &gmail.Draft{
+		Message: &gmail.Message{Raw: base64.RawURLEncoding.EncodeToString([]byte(raw))},
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", googleDraftError("failed to create draft", err)
+	}
+	return draft.Id, nil
+}
+
+func (g *Google) ListDrafts(ctx context.Context, limit int) ([]Draft, error) {
+	service, err := g.service(ctx, gmail.GmailComposeScope)
+	if err != nil {
+		return nil, err
+	}
+
+	call := service.Users.Drafts.List("me").Context(ctx)
+	if limit > 0 {
+		call = call.MaxResults(int64(limit))
+	}
+	resp, err := call.Do()
+	if err != nil {
+		return nil, googleDraftError("failed to list drafts", err)
+	}
+
+	drafts := make([]Draft, 0, len(resp.Drafts))
+	for _, d := range resp.Drafts {
+		full, err := service.Users.Drafts.Get("me", d.Id).Format("metadata").Context(ctx).Do()
+		if err != nil {
+			return nil, googleDraftError("failed to get draft "+d.Id, err)
+		}
+		draft := Draft{ID: d.Id}
+		if full.Message != nil && full.Message.Payload != nil {
+			for _, h := range full.Message.Payload.Headers {
+				switch strings.ToLower(h.Name) {
+				case "to":
+					draft.To = h.Value
+				case "subject":
+					draft.Subject = h.Value
+				case "date":
+					draft.Date = h.Value
+				}
+			}
+		}
+		drafts = append(drafts, draft)
+	}
+	return drafts, nil
+}
+
+func (g *Google) SendDraft(ctx context.Context, id string) error {
+	service, err := g.service(ctx, gmail.GmailComposeScope)
+	if err != nil {
+		return err
+	}
+	if _, err := service.Users.Drafts.Send("me", &gmail.Draft{Id: id}).Context(ctx).Do(); err != nil {
+		return googleDraftError("failed to send draft", err)
+	}
+	return nil
+}
+
+func (g *Google) DeleteDraft(ctx context.Context, id string) error {
+	service, err := g.service(ctx, gmail.GmailComposeScope)
+	if err != nil {
+		return err
+	}
+	if err := service.Users.Drafts.Delete("me", id).Context(ctx).Do(); err != nil {
+		return googleDraftError("failed to delete draft", err)
+	}
+	return nil
+}
+
+// googleDraftError explains the likely cause of a permission error:
+// providers authorized before drafts were supported only hold gmail.send.
+func googleDraftError(msg string, err error) error {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden {
+		return fmt.Errorf("%s: %w (drafts need the gmail.compose scope; re-authorize by removing and re-adding the provider)", msg, err)
+	}
+	return fmt.Errorf("%s: %w", msg, err)
+}
 
 func GenerateGoogleOAuthState() (string, error) {
 	bytes := make([]byte, 16)
@@ -242,19 +448,42 @@ func GenerateGoogleOAuthState() (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-func GetGoogleAuthURL(clientID, clientSecret, state string) string {
-	oauth2Config := googleOAuthConfig(clientID, clientSecret)
+// GenerateGoogleCodeVerifier returns a PKCE code verifier for the local
+// callback flow.
+func GenerateGoogleCodeVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// ListenGoogleAuthCallback listens on a free loopback port for the local
+// callback flow and returns the redirect URL that reaches it. Google
+// accepts any loopback port for desktop OAuth clients.
+func ListenGoogleAuthCallback() (net.Listener, string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to listen for oauth callback: %w", err)
+	}
+	redirectURL := fmt.Sprintf("http://%s/callback", listener.Addr().String())
+	return listener, redirectURL, nil
+}
+
+// GetGoogleAuthURL returns the consent URL, with an S256 PKCE challenge
+// derived from verifier.
+func GetGoogleAuthURL(clientID, clientSecret, redirectURL, state, verifier string) string {
+	oauth2Config := googleOAuthConfig(clientID, clientSecret, redirectURL)
 	return oauth2Config.AuthCodeURL(
 		state,
 		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("prompt", "consent"),
+		oauth2.S256ChallengeOption(verifier),
 	)
 }
 
-func ExchangeGoogleCode(clientID, clientSecret, code string) (*oauth2.Token, error) {
-	oauth2Config := googleOAuthConfig(clientID, clientSecret)
+// ExchangeGoogleCode redeems an authorization code. redirectURL and
+// verifier must match those passed to GetGoogleAuthURL.
+func ExchangeGoogleCode(ctx context.Context, clientID, clientSecret, redirectURL, code, verifier string) (*oauth2.Token, error) {
+	oauth2Config := googleOAuthConfig(clientID, clientSecret, redirectURL)
 
-	token, err := oauth2Config.Exchange(context.Background(), code)
+	token, err := oauth2Config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
 	if err != nil {
 		return nil, fmt.Errorf("failed to exchange code: %w", err)
 	}
@@ -262,10 +491,10 @@ func ExchangeGoogleCode(clientID, clientSecret, code string) (*oauth2.Token, err
 	return token, nil
 }
 
-func GetGoogleDeviceAuth(clientID, clientSecret string) (*oauth2.DeviceAuthResponse, error) {
-	oauth2Config := googleOAuthConfig(clientID, clientSecret)
+func GetGoogleDeviceAuth(ctx context.Context, clientID, clientSecret string) (*oauth2.DeviceAuthResponse, error) {
+	oauth2Config := googleOAuthConfig(clientID, clientSecret, "")
 	auth, err := oauth2Config.DeviceAuth(
-		context.Background(),
+		ctx,
 		oauth2.AccessTypeOffline,
 		oauth2.SetAuthURLParam("prompt", "consent"),
 	)
@@ -275,10 +504,10 @@ func GetGoogleDeviceAuth(clientID, clientSecret string) (*oauth2.DeviceAuthRespo
 	return auth, nil
 }
 
-func ExchangeGoogleDeviceAuth(clientID, clientSecret string, auth *oauth2.DeviceAuthResponse) (*oauth2.Token, error) {
-	oauth2Config := googleOAuthConfig(clientID, clientSecret)
+func ExchangeGoogleDeviceAuth(ctx context.Context, clientID, clientSecret string, auth *oauth2.DeviceAuthResponse) (*oauth2.Token, error) {
+	oauth2Config := googleOAuthConfig(clientID, clientSecret, "")
 	token, err := oauth2Config.DeviceAccessToken(
-		context.Background(),
+		ctx,
 		auth,
 		oauth2.AccessTypeOffline,
 	)
@@ -288,8 +517,12 @@ func ExchangeGoogleDeviceAuth(clientID, clientSecret string, auth *oauth2.Device
 	return token, nil
 }
 
-func RunGoogleAuthServer(expectedState string) (string, error) {
+// RunGoogleAuthServer serves the OAuth callback on listener until it
+// receives the authorization code, ctx is done, or five minutes pass.
+// The listener is closed on return.
+func RunGoogleAuthServer(ctx context.Context, listener net.Listener, expectedState string) (string, error) {
 	if expectedState == "" {
+		listener.Close()
 		return "", fmt.Errorf("expected oauth state is required")
 	}
 
@@ -305,7 +538,6 @@ func RunGoogleAuthServer(expectedState string) (string, error) {
 
 	mux := http.NewServeMux()
 	server := &http.Server{
-		Addr:    "127.0.0.1:8089",
 		Handler: mux,
 	}
 
@@ -336,7 +568,7 @@ func RunGoogleAuthServer(expectedState string) (string, error) {
 	})
 
 	go func() {
-		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			sendErr(err)
 		}
 	}()
@@ -350,9 +582,11 @@ func RunGoogleAuthServer(expectedState string) (string, error) {
 	case err := <-errChan:
 		_ = server.Shutdown(context.Background())
 		return "", err
+	case <-ctx.Done():
+		_ = server.Shutdown(context.Background())
+		return "", ctx.Err()
 	case <-timeout.C:
 		_ = server.Shutdown(context.Background())
 		return "", fmt.Errorf("timed out waiting for oauth callback")
 	}
 }
-
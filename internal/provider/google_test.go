@@ -1,10 +1,26 @@
 package provider
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
 )
 
 func TestGenerateGoogleOAuthState_Format(t *testing.T) {
@@ -21,7 +37,8 @@ func TestGenerateGoogleOAuthState_Format(t *testing.T) {
 }
 
 func TestGetGoogleAuthURL_ContainsStateAndRedirect(t *testing.T) {
-	u := GetGoogleAuthURL("client-id", "client-secret", "state123")
+	verifier := GenerateGoogleCodeVerifier()
+	u := GetGoogleAuthURL("client-id", "client-secret", "http://127.0.0.1:4242/callback", "state123", verifier)
 	parsed, err := url.Parse(u)
 	if err != nil {
 		t.Fatalf("url.Parse() error = %v", err)
@@ -30,8 +47,15 @@ func TestGetGoogleAuthURL_ContainsStateAndRedirect(t *testing.T) {
 	if got := parsed.Query().Get("state"); got != "state123" {
 		t.Fatalf("state = %q, want state123", got)
 	}
-	if got := parsed.Query().Get("redirect_uri"); got != googleRedirectURL {
-		t.Fatalf("redirect_uri = %q, want %q", got, googleRedirectURL)
+	if got := parsed.Query().Get("redirect_uri"); got != "http://127.0.0.1:4242/callback" {
+		t.Fatalf("redirect_uri = %q, want %q", got, "http://127.0.0.1:4242/callback")
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	if got, want := parsed.Query().Get("code_challenge"), base64.RawURLEncoding.EncodeToString(sum[:]); got != want {
+		t.Fatalf("code_challenge = %q, want %q", got, want)
+	}
+	if got := parsed.Query().Get("code_challenge_method"); got != "S256" {
+		t.Fatalf("code_challenge_method = %q, want S256", got)
 	}
 	if !strings.Contains(parsed.Host, "google") {
 		t.Fatalf("unexpected auth host: %q", parsed.Host)
@@ -39,9 +63,315 @@ func TestGetGoogleAuthURL_ContainsStateAndRedirect(t *testing.T) {
 }
 
 func TestRunGoogleAuthServer_RequiresState(t *testing.T) {
-	_, err := RunGoogleAuthServer("")
+	listener, _, err := ListenGoogleAuthCallback()
+	if err != nil {
+		t.Fatalf("ListenGoogleAuthCallback() error = %v", err)
+	}
+	_, err = RunGoogleAuthServer(context.Background(), listener, "")
 	if err == nil {
 		t.Fatalf("RunGoogleAuthServer() expected error for empty state")
 	}
 }
 
+func TestGoogleLocalAuthFlow_EphemeralPortAndPKCE(t *testing.T) {
+	var tokenForm url.Values
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		tokenForm = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"refresh","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	origEndpoint := googleEndpoint
+	googleEndpoint = oauth2.Endpoint{AuthURL: tokenServer.URL + "/auth", TokenURL: tokenServer.URL + "/token"}
+	defer func() { googleEndpoint = origEndpoint }()
+
+	// Two concurrent flows must not collide on a fixed port.
+	first, firstRedirect, err := ListenGoogleAuthCallback()
+	if err != nil {
+		t.Fatalf("ListenGoogleAuthCallback() error = %v", err)
+	}
+	defer first.Close()
+	listener, redirectURL, err := ListenGoogleAuthCallback()
+	if err != nil {
+		t.Fatalf("ListenGoogleAuthCallback() error = %v", err)
+	}
+	if redirectURL == firstRedirect {
+		t.Fatalf("both flows got redirect URL %q", redirectURL)
+	}
+
+	verifier := GenerateGoogleCodeVerifier()
+	codeCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		code, err := RunGoogleAuthServer(context.Background(), listener, "state123")
+		codeCh <- code
+		errCh <- err
+	}()
+
+	resp, err := http.Get(redirectURL + "?state=state123&code=auth-code")
+	if err != nil {
+		t.Fatalf("callback request error = %v", err)
+	}
+	resp.Body.Close()
+
+	code := <-codeCh
+	if err := <-errCh; err != nil {
+		t.Fatalf("RunGoogleAuthServer() error = %v", err)
+	}
+	if code != "auth-code" {
+		t.Fatalf("code = %q, want auth-code", code)
+	}
+
+	token, err := ExchangeGoogleCode(context.Background(), "client-id", "client-secret", redirectURL, code, verifier)
+	if err != nil {
+		t.Fatalf("ExchangeGoogleCode() error = %v", err)
+	}
+	if token.RefreshToken != "refresh" {
+		t.Fatalf("RefreshToken = %q, want refresh", token.RefreshToken)
+	}
+	if got := tokenForm.Get("code_verifier"); got != verifier {
+		t.Fatalf("code_verifier = %q, want %q", got, verifier)
+	}
+	if got := tokenForm.Get("redirect_uri"); got != redirectURL {
+		t.Fatalf("redirect_uri = %q, want %q", got, redirectURL)
+	}
+}
+
+type sequenceTokenSource struct {
+	tokens []string
+	calls  int
+}
+
+func (s *sequenceTokenSource) Token() (*oauth2.Token, error) {
+	token := &oauth2.Token{AccessToken: s.tokens[s.calls]}
+	if s.calls < len(s.tokens)-1 {
+		s.calls++
+	}
+	return token, nil
+}
+
+func TestPersistingTokenSource_SavesOnlyRefreshedTokens(t *testing.T) {
+	var saved []string
+	source := &persistingTokenSource{
+		base: &sequenceTokenSource{tokens: []string{"initial", "initial", "refreshed", "refreshed"}},
+		last: "initial",
+		save: func(token *oauth2.Token) error {
+			saved = append(saved, token.AccessToken)
+			return nil
+		},
+	}
+
+	for i := 0; i < 4; i++ {
+		if _, err := source.Token(); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+	}
+	if len(saved) != 1 || saved[0] != "refreshed" {
+		t.Fatalf("saved tokens = %q, want [refreshed]", saved)
+	}
+}
+
+func TestGoogleServiceAccountTokenSource(t *testing.T) {
+	var assertion url.Values
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("ParseForm() error = %v", err)
+		}
+		assertion = r.PostForm
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"sa-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey() error = %v", err)
+	}
+	keyJSON, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   "sender@project.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":      tokenServer.URL,
+	})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "sa.json")
+	if err := os.WriteFile(keyPath, keyJSON, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	for _, keyValue := range []string{keyPath, string(keyJSON)} {
+		g, err := NewGoogle("Alerts <alerts@example.com>", &config.GoogleConfig{ServiceAccountKey: keyValue}, nil)
+		if err != nil {
+			t.Fatalf("NewGoogle() error = %v", err)
+		}
+		source, err := g.tokenSource(context.Background(), gmail.GmailSendScope)
+		if err != nil {
+			t.Fatalf("tokenSource() error = %v", err)
+		}
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		if token.AccessToken != "sa-token" {
+			t.Fatalf("AccessToken = %q, want sa-token", token.AccessToken)
+		}
+
+		if got := assertion.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Fatalf("grant_type = %q", got)
+		}
+		parts := strings.Split(assertion.Get("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("assertion is not a JWT: %q", assertion.Get("assertion"))
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatalf("decode claims: %v", err)
+		}
+		var claims struct {
+			Scope string `json:"scope"`
+			Sub   string `json:"sub"`
+		}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("unmarshal claims: %v", err)
+		}
+		if claims.Sub != "alerts@example.com" || claims.Scope != gmail.GmailSendScope {
+			t.Fatalf("claims = %+v, want sub alerts@example.com and gmail.send scope", claims)
+		}
+	}
+}
+
+// newFakeGmailServer records each message sent through the Gmail API.
+func newFakeGmailServer(t *testing.T) *[]string {
+	t.Helper()
+
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/gmail/v1/users/me/messages/send" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-access-token" {
+			http.Error(w, "bad authorization "+got, http.StatusUnauthorized)
+			return
+		}
+		var msg gmail.Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		raw, err := base64.URLEncoding.WithPadding(base64.NoPadding).DecodeString(msg.Raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		sent = append(sent, string(raw))
+		_ = json.NewEncoder(w).Encode(gmail.Message{Id: "msg-1"})
+	}))
+	t.Cleanup(server.Close)
+
+	origEndpoint := gmailEndpoint
+	gmailEndpoint = server.URL + "/"
+	t.Cleanup(func() { gmailEndpoint = origEndpoint })
+	return &sent
+}
+
+func TestGoogleSend_BccInSingleMessage(t *testing.T) {
+	sent := newFakeGmailServer(t)
+
+	g, err := NewGoogle("me@example.com", &config.GoogleConfig{AccessToken: "test-access-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewGoogle() error = %v", err)
+	}
+	err = g.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Cc:      []string{"cc@example.com"},
+		Bcc:     []string{"secret1@example.com", "secret2@example.com"},
+		Subject: "Hello",
+		Body:    "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(*sent) != 1 {
+		t.Fatalf("Send() made %d API calls, want 1", len(*sent))
+	}
+	msg := (*sent)[0]
+	for _, want := range []string{
+		"Bcc: secret1@example.com, secret2@example.com\r\n",
+		"To: to@example.com\r\n",
+		"Cc: cc@example.com\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("sent message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestGoogleSend_NoBccHeaderWithoutBcc(t *testing.T) {
+	sent := newFakeGmailServer(t)
+
+	g, err := NewGoogle("me@example.com", &config.GoogleConfig{AccessToken: "test-access-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewGoogle() error = %v", err)
+	}
+	if err := g.Send(context.Background(), &Email{To: []string{"to@example.com"}, Subject: "Hello", Body: "Hi"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(*sent) != 1 || strings.Contains((*sent)[0], "Bcc:") {
+		t.Fatalf("sent messages = %q, want one without a Bcc header", *sent)
+	}
+}
+
+func TestGoogleSend_FromOverridesConfig(t *testing.T) {
+	sent := newFakeGmailServer(t)
+
+	g, err := NewGoogle("me@example.com", &config.GoogleConfig{AccessToken: "test-access-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewGoogle() error = %v", err)
+	}
+	err = g.Send(context.Background(), &Email{
+		From:    "Support <support@example.com>",
+		To:      []string{"to@example.com"},
+		Subject: "Hello",
+		Body:    "Hi",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(*sent) != 1 || !strings.HasPrefix((*sent)[0], "From: Support <support@example.com>\r\n") {
+		t.Fatalf("sent messages = %q, want one from the email's From", *sent)
+	}
+}
+
+func TestGoogleSend_ExtraHeaders(t *testing.T) {
+	sent := newFakeGmailServer(t)
+
+	g, err := NewGoogle("me@example.com", &config.GoogleConfig{AccessToken: "test-access-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewGoogle() error = %v", err)
+	}
+	err = g.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Subject: "Digest",
+		Body:    "Hi",
+		Headers: []Header{{Name: "List-Unsubscribe", Value: "<mailto:unsub@example.com>"}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(*sent) != 1 || !strings.Contains((*sent)[0], "List-Unsubscribe: <mailto:unsub@example.com>\r\n") {
+		t.Fatalf("sent messages = %q, want the extra header", *sent)
+	}
+}
@@ -1,6 +1,10 @@
 package provider
 
-import "strings"
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+)
 
 func sanitizeHeaderValue(value string) string {
 	value = strings.ReplaceAll(value, "\r", "")
@@ -28,3 +32,39 @@ func sanitizeFilename(value string) string {
 	return value
 }
 
+// Header is an extra message header, such as List-Unsubscribe.
+type Header struct {
+	Name  string
+	Value string
+}
+
+// reservedHeaders are written from other Email fields and cannot be set
+// as extra headers.
+var reservedHeaders = map[string]bool{
+	"From":                      true,
+	"To":                        true,
+	"Cc":                        true,
+	"Bcc":                       true,
+	"Subject":                   true,
+	"Mime-Version":              true,
+	"Content-Type":              true,
+	"Content-Transfer-Encoding": true,
+}
+
+// ParseHeader parses a "Name: value" header given on the command line.
+func ParseHeader(s string) (Header, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return Header{}, fmt.Errorf("invalid header %q: expected \"Name: value\"", s)
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' {
+			return Header{}, fmt.Errorf("invalid header name %q", name)
+		}
+	}
+	if reservedHeaders[textproto.CanonicalMIMEHeaderKey(name)] {
+		return Header{}, fmt.Errorf("header %s cannot be set directly", name)
+	}
+	return Header{Name: name, Value: sanitizeHeaderValue(value)}, nil
+}
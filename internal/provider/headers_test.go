@@ -36,3 +36,18 @@ func TestSanitizeFilename_RemovesQuotesAndFallback(t *testing.T) {
 	}
 }
 
+func TestParseHeader(t *testing.T) {
+	h, err := ParseHeader("List-Unsubscribe: <mailto:unsub@example.com>")
+	if err != nil {
+		t.Fatalf("ParseHeader() error = %v", err)
+	}
+	if h != (Header{Name: "List-Unsubscribe", Value: "<mailto:unsub@example.com>"}) {
+		t.Fatalf("ParseHeader() = %+v", h)
+	}
+
+	for _, bad := range []string{"no colon", ": empty name", "Bad Name: x", "subject: override", "BCC: hidden@example.com"} {
+		if _, err := ParseHeader(bad); err == nil {
+			t.Errorf("ParseHeader(%q) error = nil, want error", bad)
+		}
+	}
+}
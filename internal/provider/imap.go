@@ -0,0 +1,356 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+const defaultIMAPPort = 993
+
+// imapClient is the small subset of IMAP4rev1 (RFC 3501) needed to keep
+// drafts in a mailbox: login, LIST, SELECT, APPEND, UID FETCH/STORE and,
+// with UIDPLUS, UID EXPUNGE.
+type imapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+	caps map[string]bool
+	stop func() bool
+}
+
+// imapLine is one server response, with any literals it carried. In text,
+// each literal is left as its {n} marker.
+type imapLine struct {
+	text     string
+	literals [][]byte
+}
+
+// dialIMAP connects and logs in. The connection is closed as soon as ctx
+// is done, which unblocks any pending command.
+func dialIMAP(ctx context.Context, t *Transport, cfg *config.IMAPConfig, username, password string) (*imapClient, error) {
+	port := cfg.Port
+	if port == 0 {
+		port = defaultIMAPPort
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+
+	conn, err := t.dialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("imap dial failed: %w", err)
+	}
+	if port == defaultIMAPPort {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: cfg.Host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("imap tls failed: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	c := &imapClient{conn: conn, r: bufio.NewReader(conn)}
+	c.stop = context.AfterFunc(ctx, func() { c.conn.Close() })
+
+	if err := c.start(cfg.Host, port == defaultIMAPPort, username, password); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *imapClient) start(host string, secure bool, username, password string) error {
+	greeting, err := c.readLine()
+	if err != nil {
+		return fmt.Errorf("imap greeting failed: %w", err)
+	}
+	if !strings.HasPrefix(greeting.text, "* OK") && !strings.HasPrefix(greeting.text, "* PREAUTH") {
+		return fmt.Errorf("imap greeting failed: %s", greeting.text)
+	}
+	if err := c.capability(); err != nil {
+		return err
+	}
+
+	if !secure {
+		switch {
+		case c.caps["STARTTLS"]:
+			if _, _, err := c.cmd("STARTTLS"); err != nil {
+				return fmt.Errorf("imap starttls failed: %w", err)
+			}
+			tlsConn := tls.Client(c.conn, &tls.Config{ServerName: host})
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("imap starttls failed: %w", err)
+			}
+			c.conn = tlsConn
+			c.r = bufio.NewReader(tlsConn)
+			if err := c.capability(); err != nil {
+				return err
+			}
+		case !isLoopback(host):
+			return fmt.Errorf("imap server %s does not support STARTTLS; refusing to log in without TLS", host)
+		}
+	}
+
+	if strings.HasPrefix(greeting.text, "* PREAUTH") {
+		return nil
+	}
+	if _, _, err := c.cmd("LOGIN %s %s", imapQuote(username), imapQuote(password)); err != nil {
+		return fmt.Errorf("imap login failed: %w", err)
+	}
+	// Servers may advertise more capabilities once logged in.
+	return c.capability()
+}
+
+func (c *imapClient) capability() error {
+	untagged, _, err := c.cmd("CAPABILITY")
+	if err != nil {
+		return fmt.Errorf("imap capability failed: %w", err)
+	}
+	c.caps = make(map[string]bool)
+	for _, line := range untagged {
+		if fields := strings.Fields(line.text); len(fields) > 1 && strings.EqualFold(fields[1], "CAPABILITY") {
+			for _, capability := range fields[2:] {
+				c.caps[strings.ToUpper(capability)] = true
+			}
+		}
+	}
+	return nil
+}
+
+func (c *imapClient) Close() error {
+	c.stop()
+	return c.conn.Close()
+}
+
+func (c *imapClient) logout() {
+	_, _, _ = c.cmd("LOGOUT")
+	c.Close()
+}
+
+func (c *imapClient) nextTag() string {
+	c.tag++
+	return fmt.Sprintf("A%03d", c.tag)
+}
+
+// readLine reads one response, including any literals it contains.
+func (c *imapClient) readLine() (*imapLine, error) {
+	line := &imapLine{}
+	var text strings.Builder
+	for {
+		s, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		s = strings.TrimRight(s, "\r\n")
+		text.WriteString(s)
+
+		n, ok := literalSize(s)
+		if !ok {
+			break
+		}
+		literal := make([]byte, n)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return nil, err
+		}
+		line.literals = append(line.literals, literal)
+	}
+	line.text = text.String()
+	return line, nil
+}
+
+// literalSize reports whether s ends with a literal marker such as {42}.
+func literalSize(s string) (int, bool) {
+	if !strings.HasSuffix(s, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(s, '{')
+	if open < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[open+1 : len(s)-1])
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// cmd sends a tagged command and returns the untagged responses and the
+// text of the tagged OK.
+func (c *imapClient) cmd(format string, args ...any) ([]*imapLine, string, error) {
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, "", err
+	}
+	return c.wait(tag)
+}
+
+func (c *imapClient) wait(tag string) ([]*imapLine, string, error) {
+	var untagged []*imapLine
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return nil, "", err
+		}
+		if rest, ok := strings.CutPrefix(line.text, tag+" "); ok {
+			if !strings.HasPrefix(strings.ToUpper(rest), "OK") {
+				return nil, "", fmt.Errorf("imap: %s", rest)
+			}
+			return untagged, rest, nil
+		}
+		untagged = append(untagged, line)
+	}
+}
+
+var appendUIDPattern = regexp.MustCompile(`(?i)\[APPENDUID \d+ (\d+)\]`)
+
+// append stores msg in mailbox with the given flags and returns its UID
+// when the server reports one (UIDPLUS).
+func (c *imapClient) append(mailbox, flags string, msg []byte) (string, error) {
+	msg = crlf(msg)
+	tag := c.nextTag()
+	if _, err := fmt.Fprintf(c.conn, "%s APPEND %s (%s) {%d}\r\n", tag, imapQuote(mailbox), flags, len(msg)); err != nil {
+		return "", err
+	}
+
+	line, err := c.readLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line.text, "+") {
+		return "", fmt.Errorf("imap: %s", strings.TrimPrefix(line.text, tag+" "))
+	}
+	if _, err := c.conn.Write(append(msg, '\r', '\n')); err != nil {
+		return "", err
+	}
+
+	_, status, err := c.wait(tag)
+	if err != nil {
+		return "", err
+	}
+	if m := appendUIDPattern.FindStringSubmatch(status); m != nil {
+		return m[1], nil
+	}
+	return "", nil
+}
+
+// draftsMailbox returns the configured drafts mailbox, else the one the
+// server marks \Drafts (RFC 6154), else "Drafts".
+func (c *imapClient) draftsMailbox(configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	untagged, _, err := c.cmd(`LIST "" "*"`)
+	if err != nil {
+		return "", fmt.Errorf("imap list failed: %w", err)
+	}
+	for _, line := range untagged {
+		attrs, name, ok := parseListResponse(line)
+		if !ok {
+			continue
+		}
+		for _, attr := range attrs {
+			if strings.EqualFold(attr, `\Drafts`) {
+				return name, nil
+			}
+		}
+	}
+	return "Drafts", nil
+}
+
+// expunge permanently removes a message already flagged \Deleted. Without
+// UIDPLUS the only way to do that is a plain EXPUNGE, which would also
+// remove every other message flagged \Deleted in the mailbox, perhaps by
+// a mail client that meant to keep them until it expunged them itself. So
+// the message is left flagged \Deleted, for the next client that expunges
+// the mailbox; drafts list already skips it.
+func (c *imapClient) expunge(uid string) error {
+	if !c.caps["UIDPLUS"] {
+		return nil
+	}
+	_, _, err := c.cmd("UID EXPUNGE %s", uid)
+	return err
+}
+
+// parseListResponse parses `* LIST (attrs) delimiter name`.
+func parseListResponse(line *imapLine) (attrs []string, name string, ok bool) {
+	rest, found := strings.CutPrefix(line.text, "* LIST (")
+	if !found {
+		return nil, "", false
+	}
+	attrText, rest, found := strings.Cut(rest, ") ")
+	if !found {
+		return nil, "", false
+	}
+	attrs = strings.Fields(attrText)
+
+	// Skip the hierarchy delimiter: a quoted character or NIL.
+	if strings.HasPrefix(rest, `"`) {
+		end := 1
+		if strings.HasPrefix(rest[1:], `\`) {
+			end++
+		}
+		rest = rest[min(end+2, len(rest)):]
+	} else {
+		_, rest, _ = strings.Cut(rest, " ")
+	}
+	rest = strings.TrimSpace(rest)
+
+	switch {
+	case strings.HasPrefix(rest, `"`):
+		name, ok = imapUnquote(rest)
+		return attrs, name, ok
+	case strings.HasPrefix(rest, "{") && len(line.literals) > 0:
+		return attrs, string(line.literals[len(line.literals)-1]), true
+	default:
+		return attrs, rest, rest != ""
+	}
+}
+
+// imapQuote renders s as an IMAP quoted string.
+func imapQuote(s string) string {
+	s = strings.NewReplacer("\r", "", "\n", "").Replace(s)
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func imapUnquote(s string) (string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", false
+	}
+	var out strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				out.WriteByte(s[i])
+			}
+		case '"':
+			return out.String(), true
+		default:
+			out.WriteByte(s[i])
+		}
+	}
+	return "", false
+}
+
+// crlf normalizes line endings to CRLF, as IMAP requires.
+func crlf(msg []byte) []byte {
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(msg, []byte("\n"), []byte("\r\n"))
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,351 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// fakeIMAPServer is a plaintext IMAP server with a single drafts mailbox,
+// named mailbox and marked \Drafts.
+type fakeIMAPServer struct {
+	ln      net.Listener
+	mailbox string
+
+	mu        sync.Mutex
+	noUIDPlus bool
+	nextUID   int
+	messages  map[int]string
+	deleted   map[int]bool
+	commands  []string
+}
+
+func newFakeIMAPServer(t *testing.T, mailbox string) *fakeIMAPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	f := &fakeIMAPServer{
+		ln:       ln,
+		mailbox:  mailbox,
+		nextUID:  1,
+		messages: make(map[int]string),
+		deleted:  make(map[int]bool),
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeIMAPServer) config() *config.IMAPConfig {
+	return &config.IMAPConfig{Host: "127.0.0.1", Port: f.ln.Addr().(*net.TCPAddr).Port}
+}
+
+func (f *fakeIMAPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...any) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	reply("* OK fake IMAP ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		f.mu.Unlock()
+
+		tag, command, _ := strings.Cut(line, " ")
+		verb, args, _ := strings.Cut(command, " ")
+		if strings.EqualFold(verb, "UID") {
+			verb, args, _ = strings.Cut(args, " ")
+			verb = "UID " + verb
+		}
+
+		switch strings.ToUpper(verb) {
+		case "CAPABILITY":
+			f.mu.Lock()
+			caps := "IMAP4rev1 UIDPLUS"
+			if f.noUIDPlus {
+				caps = "IMAP4rev1"
+			}
+			f.mu.Unlock()
+			reply("* CAPABILITY %s", caps)
+			reply("%s OK done", tag)
+		case "LOGIN":
+			if args != `"me@example.com" "secret"` {
+				reply("%s NO bad credentials", tag)
+				continue
+			}
+			reply("%s OK logged in", tag)
+		case "LIST":
+			reply(`* LIST (\HasNoChildren) "/" "INBOX"`)
+			reply(`* LIST (\HasNoChildren \Drafts) "/" %s`, imapQuote(f.mailbox))
+			reply("%s OK done", tag)
+		case "SELECT", "EXAMINE":
+			reply("%s OK [READ-WRITE] selected", tag)
+		case "APPEND":
+			n, _ := literalSize(args)
+			reply("+ ready")
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			_, _ = r.ReadString('\n')
+			f.mu.Lock()
+			uid := f.nextUID
+			f.nextUID++
+			f.messages[uid] = string(msg)
+			f.mu.Unlock()
+			reply("%s OK [APPENDUID 1 %d] appended", tag, uid)
+		case "UID SEARCH":
+			reply("* SEARCH %s", strings.Join(f.uids(), " "))
+			reply("%s OK done", tag)
+		case "UID FETCH":
+			set, items, _ := strings.Cut(args, " ")
+			for i, uid := range strings.Split(set, ",") {
+				n, _ := strconv.Atoi(uid)
+				f.mu.Lock()
+				msg, ok := f.messages[n]
+				f.mu.Unlock()
+				if !ok {
+					continue
+				}
+				if strings.Contains(items, "HEADER.FIELDS") {
+					header, _, _ := strings.Cut(msg, "\r\n\r\n")
+					var kept []string
+					for _, h := range strings.Split(header, "\r\n") {
+						name, _, _ := strings.Cut(h, ":")
+						if name == "To" || name == "Subject" || name == "Date" {
+							kept = append(kept, h)
+						}
+					}
+					msg = strings.Join(kept, "\r\n") + "\r\n\r\n"
+				}
+				fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", i+1, n, len(msg), msg)
+			}
+			reply("%s OK done", tag)
+		case "UID STORE":
+			n, _ := strconv.Atoi(strings.Fields(args)[0])
+			f.mu.Lock()
+			f.deleted[n] = true
+			f.mu.Unlock()
+			reply("%s OK done", tag)
+		case "UID EXPUNGE":
+			n, _ := strconv.Atoi(args)
+			f.mu.Lock()
+			if f.deleted[n] {
+				delete(f.messages, n)
+			}
+			f.mu.Unlock()
+			reply("%s OK done", tag)
+		case "EXPUNGE":
+			f.mu.Lock()
+			for n := range f.deleted {
+				delete(f.messages, n)
+			}
+			f.mu.Unlock()
+			reply("%s OK done", tag)
+		case "LOGOUT":
+			reply("* BYE")
+			reply("%s OK done", tag)
+			return
+		default:
+			reply("%s BAD unknown command", tag)
+		}
+	}
+}
+
+func (f *fakeIMAPServer) uids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var nums []int
+	for uid := range f.messages {
+		if !f.deleted[uid] {
+			nums = append(nums, uid)
+		}
+	}
+	sort.Ints(nums)
+	out := make([]string, len(nums))
+	for i, n := range nums {
+		out[i] = strconv.Itoa(n)
+	}
+	return out
+}
+
+func (f *fakeIMAPServer) message(uid int) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.messages[uid]
+}
+
+func (f *fakeIMAPServer) hasCommand(prefix string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, cmd := range f.commands {
+		if _, rest, ok := strings.Cut(cmd, " "); ok && strings.HasPrefix(rest, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSMTPDrafts_CreateListSendDelete(t *testing.T) {
+	imapServer := newFakeIMAPServer(t, "[Gmail]/Drafts")
+	smtpServer := newFakeSMTPServer(t)
+
+	smtpCfg := smtpServer.config()
+	smtpCfg.IMAP = imapServer.config()
+	smtpCfg.IMAP.Username = "me@example.com"
+	smtpCfg.IMAP.Password = "secret"
+	s, err := NewSMTP("me@example.com", smtpCfg, nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+	ctx := context.Background()
+
+	first, err := s.CreateDraft(ctx, &Email{
+		To:      []string{"alice@example.com"},
+		Bcc:     []string{"hidden@example.com"},
+		Subject: "First",
+		Body:    "one",
+	})
+	if err != nil {
+		t.Fatalf("CreateDraft() error = %v", err)
+	}
+	if first != "1" {
+		t.Fatalf("CreateDraft() = %q, want %q", first, "1")
+	}
+	if !imapServer.hasCommand(`APPEND "[Gmail]/Drafts" (\Draft \Seen)`) {
+		t.Fatal("draft was not appended to the \\Drafts mailbox")
+	}
+	if msg := imapServer.message(1); !strings.Contains(msg, "Bcc: hidden@example.com\r\n") {
+		t.Fatalf("saved draft is missing its Bcc header:\n%s", msg)
+	}
+
+	if _, err := s.CreateDraft(ctx, &Email{To: []string{"bob@example.com"}, Subject: "Second", Body: "two"}); err != nil {
+		t.Fatalf("CreateDraft() error = %v", err)
+	}
+
+	drafts, err := s.ListDrafts(ctx, 10)
+	if err != nil {
+		t.Fatalf("ListDrafts() error = %v", err)
+	}
+	if len(drafts) != 2 || drafts[0].Subject != "Second" || drafts[1].Subject != "First" {
+		t.Fatalf("ListDrafts() = %+v, want Second then First", drafts)
+	}
+	if drafts[1].To != "alice@example.com" {
+		t.Fatalf("ListDrafts()[1].To = %q, want %q", drafts[1].To, "alice@example.com")
+	}
+
+	if err := s.SendDraft(ctx, first); err != nil {
+		t.Fatalf("SendDraft() error = %v", err)
+	}
+	rcpts := smtpServer.commandsWithPrefix("RCPT TO:")
+	if len(rcpts) != 2 || !strings.Contains(rcpts[1], "hidden@example.com") {
+		t.Fatalf("RCPT commands = %v, want alice and hidden", rcpts)
+	}
+	sent := smtpServer.receivedMessages()
+	if len(sent) != 1 || strings.Contains(sent[0], "hidden@example.com") {
+		t.Fatalf("sent message leaks Bcc or is missing:\n%v", sent)
+	}
+
+	if err := s.DeleteDraft(ctx, "2"); err != nil {
+		t.Fatalf("DeleteDraft() error = %v", err)
+	}
+	if got := imapServer.uids(); len(got) != 0 {
+		t.Fatalf("drafts left after send and delete = %v, want none", got)
+	}
+}
+
+func TestSMTPDrafts_DeleteWithoutUIDPlus(t *testing.T) {
+	imapServer := newFakeIMAPServer(t, "Drafts")
+	imapServer.mu.Lock()
+	imapServer.noUIDPlus = true
+	// A message another client flagged \Deleted but has not expunged.
+	imapServer.messages[99] = "Subject: theirs\r\n\r\nkeep me"
+	imapServer.deleted[99] = true
+	imapServer.mu.Unlock()
+
+	smtpCfg := &config.SMTPConfig{Host: "smtp.example.com", IMAP: imapServer.config()}
+	smtpCfg.IMAP.Username = "me@example.com"
+	smtpCfg.IMAP.Password = "secret"
+	s, err := NewSMTP("me@example.com", smtpCfg, nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+	ctx := context.Background()
+
+	id, err := s.CreateDraft(ctx, &Email{To: []string{"alice@example.com"}, Subject: "Hi", Body: "one"})
+	if err != nil {
+		t.Fatalf("CreateDraft() error = %v", err)
+	}
+	if err := s.DeleteDraft(ctx, id); err != nil {
+		t.Fatalf("DeleteDraft() error = %v", err)
+	}
+	if imapServer.hasCommand("EXPUNGE") {
+		t.Fatal("DeleteDraft() sent a plain EXPUNGE without UIDPLUS")
+	}
+	if got := imapServer.uids(); len(got) != 0 {
+		t.Fatalf("drafts left after delete = %v, want the draft flagged \\Deleted", got)
+	}
+	if imapServer.message(99) == "" {
+		t.Fatal("another client's deleted message was expunged")
+	}
+}
+
+func TestSMTPDrafts_RequireIMAP(t *testing.T) {
+	s, err := NewSMTP("me@example.com", &config.SMTPConfig{Host: "smtp.example.com"}, nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+	_, err = s.CreateDraft(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "imap-host") {
+		t.Fatalf("CreateDraft() error = %v, want a hint about imap-host", err)
+	}
+}
+
+func TestParseListResponse(t *testing.T) {
+	tests := []struct {
+		line     string
+		wantName string
+		wantAttr string
+	}{
+		{`* LIST (\HasNoChildren \Drafts) "/" "[Gmail]/Drafts"`, "[Gmail]/Drafts", `\Drafts`},
+		{`* LIST (\Drafts) "." Drafts`, "Drafts", `\Drafts`},
+		{`* LIST (\Drafts) NIL "Entw\"urfe"`, `Entw"urfe`, `\Drafts`},
+	}
+	for _, tt := range tests {
+		attrs, name, ok := parseListResponse(&imapLine{text: tt.line})
+		if !ok || name != tt.wantName {
+			t.Fatalf("parseListResponse(%q) name = %q, %v, want %q", tt.line, name, ok, tt.wantName)
+		}
+		if len(attrs) == 0 || attrs[len(attrs)-1] != tt.wantAttr {
+			t.Fatalf("parseListResponse(%q) attrs = %v, want %s last", tt.line, attrs, tt.wantAttr)
+		}
+	}
+}
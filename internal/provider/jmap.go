@@ -0,0 +1,486 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+const (
+	jmapCore       = "urn:ietf:params:jmap:core"
+	jmapMail       = "urn:ietf:params:jmap:mail"
+	jmapSubmission = "urn:ietf:params:jmap:submission"
+)
+
+// JMAP sends through a JMAP server (RFC 8620, RFC 8621) such as Fastmail
+// or Stalwart. Each send creates an Email in the Drafts mailbox and an
+// EmailSubmission for it in one request; once submitted, the server
+// moves the email to the Sent mailbox.
+type JMAP struct {
+	from   string
+	config *config.JMAPConfig
+	client *http.Client
+}
+
+func NewJMAP(from string, cfg *config.JMAPConfig, t *Transport) (*JMAP, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("jmap url is required")
+	}
+	if cfg.Token == "" && (cfg.Username == "" || cfg.Password == "") {
+		return nil, fmt.Errorf("jmap token, or username and password, are required")
+	}
+	return &JMAP{
+		from:   from,
+		config: cfg,
+		client: t.httpClient(),
+	}, nil
+}
+
+func (j *JMAP) Name() string {
+	return "jmap"
+}
+
+// jmapSession is the part of the session resource used for sending.
+type jmapSession struct {
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+	PrimaryAccounts map[string]string          `json:"primaryAccounts"`
+	APIURL          string                     `json:"apiUrl"`
+	UploadURL       string                     `json:"uploadUrl"`
+}
+
+type jmapAddress struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email"`
+}
+
+type jmapBodyPart struct {
+	PartID      string `json:"partId,omitempty"`
+	BlobID      string `json:"blobId,omitempty"`
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	Disposition string `json:"disposition,omitempty"`
+}
+
+type jmapIdentity struct {
+	ID    string `json:"id"`
+	Email string `json:"email"`
+}
+
+type jmapMailbox struct {
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+// jmapInvocation is a method call or response: [name, arguments, call id].
+type jmapInvocation [3]any
+
+type jmapSetError struct {
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+func (e jmapSetError) String() string {
+	if e.Description != "" {
+		return e.Type + ": " + e.Description
+	}
+	return e.Type
+}
+
+func (j *JMAP) Send(ctx context.Context, email *Email) error {
+	from := j.from
+	if email.From != "" {
+		from = email.From
+	}
+	sender, err := mail.ParseAddress(sanitizeHeaderValue(from))
+	if err != nil {
+		return fmt.Errorf("invalid from address %q: %w", from, err)
+	}
+	recipients := email.envelopeRecipients()
+	if len(recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	session, err := j.session(ctx)
+	if err != nil {
+		return err
+	}
+	accountID := session.PrimaryAccounts[jmapMail]
+	if accountID == "" {
+		return fmt.Errorf("jmap session has no mail account")
+	}
+	if _, ok := session.Capabilities[jmapSubmission]; !ok {
+		return fmt.Errorf("jmap server does not support email submission")
+	}
+
+	identityID, drafts, sent, err := j.lookup(ctx, session, accountID, sender.Address)
+	if err != nil {
+		return err
+	}
+
+	// New mail goes into Drafts (or Sent, without Drafts) and is moved
+	// to Sent once the submission is created, as RFC 8621 suggests.
+	mailboxes := map[string]bool{}
+	keywords := map[string]bool{"$seen": true}
+	switch {
+	case drafts != "":
+		mailboxes[drafts] = true
+		keywords["$draft"] = true
+	case sent != "":
+		mailboxes[sent] = true
+	default:
+		return fmt.Errorf("jmap account has no drafts or sent mailbox")
+	}
+
+	var create jmapInvocation
+	if email.Raw != nil {
+		blobID, err := j.upload(ctx, session, accountID, "message/rfc822", email.Raw)
+		if err != nil {
+			return err
+		}
+		create = jmapInvocation{"Email/import", map[string]any{
+			"accountId": accountID,
+			"emails": map[string]any{
+				"draft": map[string]any{
+					"blobId":     blobID,
+					"mailboxIds": mailboxes,
+					"keywords":   keywords,
+				},
+			},
+		}, "0"}
+	} else {
+		draft, err := j.emailObject(ctx, session, accountID, sender, email)
+		if err != nil {
+			return err
+		}
+		draft["mailboxIds"] = mailboxes
+		draft["keywords"] = keywords
+		create = jmapInvocation{"Email/set", map[string]any{
+			"accountId": accountID,
+			"create":    map[string]any{"draft": draft},
+		}, "0"}
+	}
+
+	rcptTo := make([]jmapAddress, 0, len(recipients))
+	for _, addr := range recipients {
+		if parsed, err := mail.ParseAddress(addr); err == nil {
+			addr = parsed.Address
+		}
+		rcptTo = append(rcptTo, jmapAddress{Email: addr})
+	}
+	submit := map[string]any{
+		"accountId": accountID,
+		"create": map[string]any{
+			"send": map[string]any{
+				"identityId": identityID,
+				"emailId":    "#draft",
+				"envelope": map[string]any{
+					"mailFrom": jmapAddress{Email: sender.Address},
+					"rcptTo":   rcptTo,
+				},
+			},
+		},
+	}
+	switch {
+	case drafts != "" && sent != "":
+		submit["onSuccessUpdateEmail"] = map[string]any{
+			"#send": map[string]any{
+				"mailboxIds/" + drafts: nil,
+				"mailboxIds/" + sent:   true,
+				"keywords/$draft":      nil,
+			},
+		}
+	case drafts != "":
+		// Nowhere to file the sent copy; don't leave it in Drafts.
+		submit["onSuccessDestroyEmail"] = []string{"#send"}
+	}
+
+	responses, err := j.call(ctx, session, create, jmapInvocation{"EmailSubmission/set", submit, "1"})
+	if err != nil {
+		return err
+	}
+	for _, resp := range responses {
+		if err := jmapNotCreated(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emailObject builds the Email/set create arguments for email, uploading
+// attachments as blobs first.
+func (j *JMAP) emailObject(ctx context.Context, session *jmapSession, accountID string, sender *mail.Address, email *Email) (map[string]any, error) {
+	obj := map[string]any{
+		"from":       []jmapAddress{{Name: sender.Name, Email: sender.Address}},
+		"subject":    sanitizeHeaderValue(email.Subject),
+		"bodyValues": map[string]any{"body": map[string]string{"value": email.Body}},
+	}
+	if to := jmapAddresses(email.To); len(to) > 0 {
+		obj["to"] = to
+	}
+	if cc := jmapAddresses(email.Cc); len(cc) > 0 {
+		obj["cc"] = cc
+	}
+	if email.HTML {
+		obj["htmlBody"] = []jmapBodyPart{{PartID: "body", Type: "text/html"}}
+	} else {
+		obj["textBody"] = []jmapBodyPart{{PartID: "body", Type: "text/plain"}}
+	}
+	for _, h := range email.Headers {
+		obj["header:"+sanitizeHeaderValue(h.Name)+":asText"] = sanitizeHeaderValue(h.Value)
+	}
+
+	var attachments []jmapBodyPart
+	for _, att := range email.Attachments {
+		file, err := readAttachment(att)
+		if err != nil {
+			return nil, err
+		}
+		blobID, err := j.upload(ctx, session, accountID, file.contentType, file.content)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, jmapBodyPart{
+			BlobID:      blobID,
+			Type:        file.contentType,
+			Name:        sanitizeFilename(file.name),
+			Disposition: "attachment",
+		})
+	}
+	if len(attachments) > 0 {
+		obj["attachments"] = attachments
+	}
+	return obj, nil
+}
+
+// lookup finds the identity for from and the drafts and sent mailboxes.
+func (j *JMAP) lookup(ctx context.Context, session *jmapSession, accountID, from string) (identityID, drafts, sent string, err error) {
+	responses, err := j.call(ctx, session,
+		jmapInvocation{"Identity/get", map[string]any{"accountId": accountID, "properties": []string{"id", "email"}}, "0"},
+		jmapInvocation{"Mailbox/get", map[string]any{"accountId": accountID, "properties": []string{"id", "role"}}, "1"},
+	)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var identities, mailboxes struct {
+		List json.RawMessage `json:"list"`
+	}
+	if err := jmapDecode(responses, "Identity/get", &identities); err != nil {
+		return "", "", "", err
+	}
+	if err := jmapDecode(responses, "Mailbox/get", &mailboxes); err != nil {
+		return "", "", "", err
+	}
+
+	var idList []jmapIdentity
+	if err := json.Unmarshal(identities.List, &idList); err != nil {
+		return "", "", "", fmt.Errorf("invalid jmap Identity/get response: %w", err)
+	}
+	_, domain, _ := strings.Cut(from, "@")
+	for _, identity := range idList {
+		if strings.EqualFold(identity.Email, from) {
+			identityID = identity.ID
+			break
+		}
+		if identityID == "" && strings.EqualFold(identity.Email, "*@"+domain) {
+			identityID = identity.ID
+		}
+	}
+	if identityID == "" {
+		return "", "", "", fmt.Errorf("no jmap identity can send as %s", from)
+	}
+
+	var boxes []jmapMailbox
+	if err := json.Unmarshal(mailboxes.List, &boxes); err != nil {
+		return "", "", "", fmt.Errorf("invalid jmap Mailbox/get response: %w", err)
+	}
+	for _, box := range boxes {
+		switch box.Role {
+		case "drafts":
+			drafts = box.ID
+		case "sent":
+			sent = box.ID
+		}
+	}
+	return identityID, drafts, sent, nil
+}
+
+// sessionURL returns the configured session resource, or the well-known
+// location when only a server URL is configured.
+func (j *JMAP) sessionURL() (string, error) {
+	u, err := url.Parse(j.config.URL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid jmap url %q", j.config.URL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/.well-known/jmap"
+	}
+	return u.String(), nil
+}
+
+func (j *JMAP) session(ctx context.Context) (*jmapSession, error) {
+	sessionURL, err := j.sessionURL()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, sessionURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	var session jmapSession
+	if err := j.do(req, &session); err != nil {
+		return nil, fmt.Errorf("failed to fetch jmap session: %w", err)
+	}
+	if session.APIURL == "" || session.UploadURL == "" {
+		return nil, fmt.Errorf("jmap session is missing apiUrl or uploadUrl")
+	}
+	return &session, nil
+}
+
+func (j *JMAP) upload(ctx context.Context, session *jmapSession, accountID, contentType string, data []byte) (string, error) {
+	uploadURL := strings.ReplaceAll(session.UploadURL, "{accountId}", url.PathEscape(accountID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	var blob struct {
+		BlobID string `json:"blobId"`
+	}
+	if err := j.do(req, &blob); err != nil {
+		return "", fmt.Errorf("failed to upload to jmap: %w", err)
+	}
+	if blob.BlobID == "" {
+		return "", fmt.Errorf("jmap upload returned no blobId")
+	}
+	return blob.BlobID, nil
+}
+
+// call makes one JMAP API request and returns its method responses,
+// failing on any method-level error.
+func (j *JMAP) call(ctx context.Context, session *jmapSession, calls ...jmapInvocation) ([]json.RawMessage, error) {
+	body, err := json.Marshal(map[string]any{
+		"using":       []string{jmapCore, jmapMail, jmapSubmission},
+		"methodCalls": calls,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, session.APIURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp struct {
+		MethodResponses []json.RawMessage `json:"methodResponses"`
+	}
+	if err := j.do(req, &resp); err != nil {
+		return nil, err
+	}
+
+	for _, raw := range resp.MethodResponses {
+		var inv []json.RawMessage
+		if err := json.Unmarshal(raw, &inv); err != nil || len(inv) != 3 {
+			return nil, fmt.Errorf("invalid jmap method response: %s", raw)
+		}
+		var name string
+		_ = json.Unmarshal(inv[0], &name)
+		if name == "error" {
+			var methodErr jmapSetError
+			_ = json.Unmarshal(inv[1], &methodErr)
+			return nil, fmt.Errorf("jmap error: %s", methodErr)
+		}
+	}
+	return resp.MethodResponses, nil
+}
+
+// do sends an authenticated request and decodes the JSON response into out.
+func (j *JMAP) do(req *http.Request, out any) error {
+	if j.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+j.config.Token)
+	} else {
+		req.SetBasicAuth(j.config.Username, j.config.Password)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 400 {
+		// Request-level errors are RFC 7807 problem details.
+		var problem struct {
+			Type   string `json:"type"`
+			Detail string `json:"detail"`
+		}
+		if json.Unmarshal(respBody, &problem) == nil && problem.Detail != "" {
+			return responseError(resp, fmt.Errorf("jmap error: %s (status %d)", problem.Detail, resp.StatusCode))
+		}
+		return responseError(resp, fmt.Errorf("jmap error: %s (status %d)", strings.TrimSpace(string(respBody)), resp.StatusCode))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("invalid jmap response: %w", err)
+	}
+	return nil
+}
+
+// jmapDecode decodes the arguments of the named method response.
+func jmapDecode(responses []json.RawMessage, method string, out any) error {
+	for _, raw := range responses {
+		var inv []json.RawMessage
+		if json.Unmarshal(raw, &inv) != nil || len(inv) != 3 {
+			continue
+		}
+		var name string
+		if json.Unmarshal(inv[0], &name) == nil && name == method {
+			return json.Unmarshal(inv[1], out)
+		}
+	}
+	return fmt.Errorf("jmap response is missing %s", method)
+}
+
+// jmapNotCreated reports the first object a /set or /import response
+// failed to create.
+func jmapNotCreated(raw json.RawMessage) error {
+	var inv []json.RawMessage
+	if json.Unmarshal(raw, &inv) != nil || len(inv) != 3 {
+		return nil
+	}
+	var name string
+	_ = json.Unmarshal(inv[0], &name)
+	var args struct {
+		NotCreated map[string]jmapSetError `json:"notCreated"`
+	}
+	if json.Unmarshal(inv[1], &args) != nil {
+		return nil
+	}
+	for _, setErr := range args.NotCreated {
+		return fmt.Errorf("jmap %s failed: %s", name, setErr)
+	}
+	return nil
+}
+
+func jmapAddresses(addrs []string) []jmapAddress {
+	var out []jmapAddress
+	for _, addr := range sanitizeAddressList(addrs) {
+		if parsed, err := mail.ParseAddress(addr); err == nil {
+			out = append(out, jmapAddress{Name: parsed.Name, Email: parsed.Address})
+			continue
+		}
+		out = append(out, jmapAddress{Email: addr})
+	}
+	return out
+}
@@ -0,0 +1,281 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// fakeJMAP is a minimal in-process JMAP server with one account, a
+// Drafts and a Sent mailbox, and one identity.
+type fakeJMAP struct {
+	server *httptest.Server
+
+	mu          sync.Mutex
+	noSent      bool
+	blobs       map[string][]byte
+	emails      map[string]map[string]any
+	submissions []map[string]any
+	calls       []string
+}
+
+func newFakeJMAP(t *testing.T) *fakeJMAP {
+	t.Helper()
+	f := &fakeJMAP{blobs: make(map[string][]byte), emails: make(map[string]map[string]any)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeJMAP) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer jmap-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"type":"about:blank","status":401,"detail":"Invalid credentials"}`))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/.well-known/jmap":
+		http.Redirect(w, r, "/jmap/session", http.StatusTemporaryRedirect)
+	case r.URL.Path == "/jmap/session":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"capabilities": map[string]any{
+				jmapCore: map[string]any{}, jmapMail: map[string]any{}, jmapSubmission: map[string]any{},
+			},
+			"primaryAccounts": map[string]string{jmapMail: "u1", jmapSubmission: "u1"},
+			"apiUrl":          f.server.URL + "/jmap/api/",
+			"uploadUrl":       f.server.URL + "/jmap/upload/{accountId}/",
+		})
+	case r.URL.Path == "/jmap/upload/u1/":
+		data, _ := io.ReadAll(r.Body)
+		id := fmt.Sprintf("B%d", len(f.blobs)+1)
+		f.blobs[id] = data
+		_ = json.NewEncoder(w).Encode(map[string]any{"accountId": "u1", "blobId": id, "type": r.Header.Get("Content-Type"), "size": len(data)})
+	case r.URL.Path == "/jmap/api/":
+		var req struct {
+			MethodCalls [][3]json.RawMessage `json:"methodCalls"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var responses []any
+		created := map[string]string{}
+		for _, call := range req.MethodCalls {
+			var name, callID string
+			var args map[string]any
+			_ = json.Unmarshal(call[0], &name)
+			_ = json.Unmarshal(call[1], &args)
+			_ = json.Unmarshal(call[2], &callID)
+			f.calls = append(f.calls, name)
+			responses = append(responses, []any{name, f.method(name, args, created), callID})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"methodResponses": responses, "sessionState": "s1"})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeJMAP) method(name string, args map[string]any, created map[string]string) map[string]any {
+	switch name {
+	case "Identity/get":
+		return map[string]any{"accountId": "u1", "list": []any{
+			map[string]any{"id": "I1", "email": "me@fastmail.com"},
+			map[string]any{"id": "I2", "email": "*@example.org"},
+		}}
+	case "Mailbox/get":
+		list := []any{
+			map[string]any{"id": "M-inbox", "role": "inbox"},
+			map[string]any{"id": "M-drafts", "role": "drafts"},
+		}
+		if !f.noSent {
+			list = append(list, map[string]any{"id": "M-sent", "role": "sent"})
+		}
+		return map[string]any{"accountId": "u1", "list": list}
+	case "Email/set", "Email/import":
+		key := "create"
+		if name == "Email/import" {
+			key = "emails"
+		}
+		out := map[string]any{}
+		for cid, obj := range args[key].(map[string]any) {
+			id := fmt.Sprintf("E%d", len(f.emails)+1)
+			f.emails[id] = obj.(map[string]any)
+			created[cid] = id
+			out[cid] = map[string]any{"id": id}
+		}
+		return map[string]any{"accountId": "u1", "created": out}
+	case "EmailSubmission/set":
+		out := map[string]any{}
+		for cid, obj := range args["create"].(map[string]any) {
+			sub := obj.(map[string]any)
+			emailID := sub["emailId"].(string)
+			if strings.HasPrefix(emailID, "#") {
+				emailID = created[strings.TrimPrefix(emailID, "#")]
+			}
+			if _, ok := f.emails[emailID]; !ok {
+				return map[string]any{"notCreated": map[string]any{cid: map[string]any{"type": "invalidProperties", "description": "unknown emailId"}}}
+			}
+			sub["emailId"] = emailID
+			f.submissions = append(f.submissions, sub)
+			out[cid] = map[string]any{"id": "S1"}
+			created[cid] = emailID
+		}
+		if update, ok := args["onSuccessUpdateEmail"].(map[string]any); ok {
+			for ref, patch := range update {
+				email := f.emails[created[strings.TrimPrefix(ref, "#")]]
+				for path, value := range patch.(map[string]any) {
+					prop, key, _ := strings.Cut(path, "/")
+					m := email[prop].(map[string]any)
+					if value == nil {
+						delete(m, key)
+					} else {
+						m[key] = value
+					}
+				}
+			}
+		}
+		if destroy, ok := args["onSuccessDestroyEmail"].([]any); ok {
+			for _, ref := range destroy {
+				delete(f.emails, created[strings.TrimPrefix(ref.(string), "#")])
+			}
+		}
+		return map[string]any{"accountId": "u1", "created": out}
+	}
+	return map[string]any{}
+}
+
+func newTestJMAP(t *testing.T, f *fakeJMAP, from string) *JMAP {
+	t.Helper()
+	j, err := NewJMAP(from, &config.JMAPConfig{URL: f.server.URL, Token: "jmap-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewJMAP() error = %v", err)
+	}
+	return j
+}
+
+func TestJMAPSend_CreatesEmailAndSubmission(t *testing.T) {
+	f := newFakeJMAP(t)
+	j := newTestJMAP(t, f, "Me <me@fastmail.com>")
+
+	err := j.Send(context.Background(), &Email{
+		To:          []string{"Alice <alice@example.com>"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if got := strings.Join(f.calls, ","); got != "Identity/get,Mailbox/get,Email/set,EmailSubmission/set" {
+		t.Fatalf("method calls = %s", got)
+	}
+	if len(f.blobs) != 1 || string(f.blobs["B1"]) != "%PDF" {
+		t.Fatalf("blobs = %v", f.blobs)
+	}
+	if len(f.emails) != 1 || len(f.submissions) != 1 {
+		t.Fatalf("emails = %v, submissions = %v", f.emails, f.submissions)
+	}
+
+	email := f.emails["E1"]
+	got, _ := json.Marshal(email)
+	for _, want := range []string{
+		`"mailboxIds":{"M-sent":true}`,
+		`"keywords":{"$seen":true}`,
+		`"to":[{"email":"alice@example.com","name":"Alice"}]`,
+		`"attachments":[{"blobId":"B1","disposition":"attachment","name":"report.pdf","type":"application/pdf"}]`,
+		`"header:X-Campaign:asText":"q3"`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Fatalf("email missing %s:\n%s", want, got)
+		}
+	}
+	if strings.Contains(string(got), "audit@example.com") {
+		t.Fatalf("Bcc recipient leaked into the email object:\n%s", got)
+	}
+
+	sub, _ := json.Marshal(f.submissions[0])
+	for _, want := range []string{
+		`"identityId":"I1"`,
+		`"mailFrom":{"email":"me@fastmail.com"}`,
+		`"rcptTo":[{"email":"alice@example.com"},{"email":"audit@example.com"}]`,
+	} {
+		if !strings.Contains(string(sub), want) {
+			t.Fatalf("submission missing %s:\n%s", want, sub)
+		}
+	}
+}
+
+func TestJMAPSend_RawImportAndWildcardIdentity(t *testing.T) {
+	f := newFakeJMAP(t)
+	j := newTestJMAP(t, f, "alerts@example.org")
+
+	raw := "From: alerts@example.org\r\nTo: ops@example.com\r\nSubject: Raw\r\n\r\nBody\r\n"
+	if err := j.Send(context.Background(), &Email{To: []string{"ops@example.com"}, Raw: []byte(raw)}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.calls[2] != "Email/import" || string(f.blobs["B1"]) != raw {
+		t.Fatalf("calls = %v, blob = %q", f.calls, f.blobs["B1"])
+	}
+	if f.submissions[0]["identityId"] != "I2" {
+		t.Fatalf("identityId = %v, want the wildcard identity I2", f.submissions[0]["identityId"])
+	}
+}
+
+func TestJMAPSend_NoSentMailboxDestroysDraft(t *testing.T) {
+	f := newFakeJMAP(t)
+	f.noSent = true
+	j := newTestJMAP(t, f, "me@fastmail.com")
+
+	if err := j.Send(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.submissions) != 1 || len(f.emails) != 0 {
+		t.Fatalf("submissions = %d, emails left = %v; want the draft destroyed after sending", len(f.submissions), f.emails)
+	}
+}
+
+func TestJMAPSend_UnknownIdentity(t *testing.T) {
+	f := newFakeJMAP(t)
+	j := newTestJMAP(t, f, "stranger@example.net")
+
+	err := j.Send(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "no jmap identity") {
+		t.Fatalf("Send() error = %v, want no identity error", err)
+	}
+}
+
+func TestJMAPSend_AuthError(t *testing.T) {
+	f := newFakeJMAP(t)
+	j, err := NewJMAP("me@fastmail.com", &config.JMAPConfig{URL: f.server.URL, Token: "wrong"}, nil)
+	if err != nil {
+		t.Fatalf("NewJMAP() error = %v", err)
+	}
+
+	err = j.Send(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "Invalid credentials (status 401)") {
+		t.Fatalf("Send() error = %v, want the problem detail", err)
+	}
+}
@@ -0,0 +1,292 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/state"
+)
+
+// Limits are a provider's rate limit and quotas. Zero values are unset.
+type Limits struct {
+	// Rate sends per Per, with up to Burst (default 1) at once: a token
+	// bucket holding Burst tokens, refilled at Rate per Per.
+	Rate  int
+	Per   time.Duration
+	Burst int
+	// Daily and Monthly cap the recipients sent to per UTC day and
+	// calendar month.
+	Daily   int
+	Monthly int
+}
+
+// Limiter enforces a provider's limits across every email-cli run, keeping
+// its token bucket and quota usage in a state file.
+type Limiter struct {
+	name   string
+	limits Limits
+	state  *state.File
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// limitState is what a limiter remembers between runs.
+type limitState struct {
+	// Tokens were left in the bucket at Refilled. They go negative while
+	// sends wait for tokens still to come.
+	Tokens   float64   `json:"tokens"`
+	Refilled time.Time `json:"refilled"`
+	// Day and Month count the recipients sent to in the current periods.
+	Day   *QuotaUsage `json:"day,omitempty"`
+	Month *QuotaUsage `json:"month,omitempty"`
+}
+
+// QuotaUsage is how many recipients were sent to in Period (UTC,
+// YYYY-MM-DD for days and YYYY-MM for months).
+type QuotaUsage struct {
+	Period string `json:"period"`
+	Sent   int    `json:"sent"`
+}
+
+// LimitError is returned when a send would exceed a rate limit or quota.
+// Another provider might still send the email, so it is transient.
+type LimitError struct {
+	Provider string
+	Reason   string
+	// Reset is when the send would be allowed.
+	Reset time.Time
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %s until %s", e.Provider, e.Reason, e.Reset.UTC().Format("2006-01-02 15:04:05 UTC"))
+}
+
+// LimiterFor returns the limiter for a provider's configured limits, or
+// nil if it has none. A nil *Limiter allows every send.
+func LimiterFor(cfg *config.ProviderConfig) (*Limiter, error) {
+	if !cfg.HasLimits() {
+		return nil, nil
+	}
+	rate, per, err := cfg.Rate()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RateBurst < 0 || cfg.DailyQuota < 0 || cfg.MonthlyQuota < 0 {
+		return nil, fmt.Errorf("rate_burst and quotas must not be negative")
+	}
+	file, err := openState("quota", cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	return NewLimiter(cfg.Name, Limits{
+		Rate:    rate,
+		Per:     per,
+		Burst:   cfg.RateBurst,
+		Daily:   cfg.DailyQuota,
+		Monthly: cfg.MonthlyQuota,
+	}, file), nil
+}
+
+func NewLimiter(name string, limits Limits, file *state.File) *Limiter {
+	if limits.Burst <= 0 {
+		limits.Burst = 1
+	}
+	return &Limiter{name: name, limits: limits, state: file, now: time.Now, sleep: sleepContext}
+}
+
+// Limit calls send once email is within the limits, waiting for the rate
+// limit if need be. It fails with a *LimitError if a quota would be
+// exceeded, or if the wait would outlast ctx. Recipients of a failed send
+// do not count toward the quotas.
+func (l *Limiter) Limit(ctx context.Context, email *Email, send func() error) error {
+	if l == nil {
+		return send()
+	}
+
+	recipients := len(email.To) + len(email.Cc) + len(email.Bcc)
+	wait, err := l.reserve(ctx, recipients)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		if err := l.sleep(ctx, wait); err != nil {
+			return l.release(recipients, err)
+		}
+	}
+	if err := send(); err != nil {
+		return l.release(recipients, err)
+	}
+	return nil
+}
+
+// reserve takes a token and counts recipients against the quotas,
+// returning how long to wait for the token.
+func (l *Limiter) reserve(ctx context.Context, recipients int) (time.Duration, error) {
+	var wait time.Duration
+	var st limitState
+	err := l.state.Update(&st, func() error {
+		now := l.now()
+		day, month := periods(now)
+		for _, q := range []struct {
+			name, period string
+			limit        int
+			usage        *QuotaUsage
+			reset        time.Time
+		}{
+			{"daily", day, l.limits.Daily, st.Day, nextDay(now)},
+			{"monthly", month, l.limits.Monthly, st.Month, nextMonth(now)},
+		} {
+			if q.limit == 0 {
+				continue
+			}
+			if recipients > q.limit {
+				return fmt.Errorf("%s: %d recipients is more than the %s quota of %d", l.name, recipients, q.name, q.limit)
+			}
+			if q.usage.sent(q.period)+recipients > q.limit {
+				return &LimitError{Provider: l.name, Reason: fmt.Sprintf("has reached its %s quota of %d recipients", q.name, q.limit), Reset: q.reset}
+			}
+		}
+
+		if l.limits.Rate > 0 {
+			l.refill(&st, now)
+			if st.Tokens < 1 {
+				wait = time.Duration((1 - st.Tokens) * float64(l.limits.Per) / float64(l.limits.Rate))
+				if deadline, ok := ctx.Deadline(); ok && wait > time.Until(deadline) {
+					return &LimitError{Provider: l.name, Reason: "is rate limited to " + l.rateString(), Reset: now.Add(wait)}
+				}
+			}
+			st.Tokens--
+		}
+		st.Day = st.Day.add(day, recipients)
+		st.Month = st.Month.add(month, recipients)
+		return nil
+	})
+	return wait, err
+}
+
+// release gives back the quota reserved for a send that failed with err,
+// and returns err.
+func (l *Limiter) release(recipients int, err error) error {
+	var st limitState
+	if releaseErr := l.state.Update(&st, func() error {
+		day, month := periods(l.now())
+		st.Day = st.Day.add(day, -recipients)
+		st.Month = st.Month.add(month, -recipients)
+		return nil
+	}); releaseErr != nil {
+		return fmt.Errorf("%w (and %v)", err, releaseErr)
+	}
+	return err
+}
+
+// refill adds the tokens earned since the bucket was last refilled.
+func (l *Limiter) refill(st *limitState, now time.Time) {
+	burst := float64(l.limits.Burst)
+	if st.Refilled.IsZero() {
+		st.Tokens = burst
+	} else if elapsed := now.Sub(st.Refilled); elapsed > 0 {
+		st.Tokens = min(burst, st.Tokens+float64(l.limits.Rate)*float64(elapsed)/float64(l.limits.Per))
+	}
+	st.Refilled = now
+}
+
+// LimitStatus is a provider's current usage of its limits.
+type LimitStatus struct {
+	Limits
+	// Available is how many sends the rate limit allows now, and
+	// NextSend how long until it allows another when none are.
+	Available int
+	NextSend  time.Duration
+	// Today and Month are the recipients sent to this UTC day and month,
+	// which reset at DayReset and MonthReset.
+	Today, Month         int
+	DayReset, MonthReset time.Time
+}
+
+// Status returns the limiter's current usage without changing it.
+func (l *Limiter) Status() (*LimitStatus, error) {
+	var st limitState
+	if err := l.state.Load(&st); err != nil {
+		return nil, err
+	}
+	now := l.now()
+	day, month := periods(now)
+	status := &LimitStatus{
+		Limits:     l.limits,
+		Today:      st.Day.sent(day),
+		Month:      st.Month.sent(month),
+		DayReset:   nextDay(now),
+		MonthReset: nextMonth(now),
+	}
+	if l.limits.Rate > 0 {
+		l.refill(&st, now)
+		if st.Tokens >= 1 {
+			status.Available = int(st.Tokens)
+		} else {
+			status.NextSend = time.Duration((1 - st.Tokens) * float64(l.limits.Per) / float64(l.limits.Rate))
+		}
+	}
+	return status, nil
+}
+
+func (l *Limiter) rateString() string {
+	per := l.limits.Per.String()
+	switch l.limits.Per {
+	case time.Second:
+		per = "s"
+	case time.Minute:
+		per = "min"
+	case time.Hour:
+		per = "h"
+	case 24 * time.Hour:
+		per = "day"
+	}
+	return fmt.Sprintf("%d/%s", l.limits.Rate, per)
+}
+
+func (u *QuotaUsage) sent(period string) int {
+	if u == nil || u.Period != period {
+		return 0
+	}
+	return u.Sent
+}
+
+func (u *QuotaUsage) add(period string, n int) *QuotaUsage {
+	return &QuotaUsage{Period: period, Sent: max(u.sent(period)+n, 0)}
+}
+
+// periods returns the UTC day and month containing t.
+func periods(t time.Time) (day, month string) {
+	t = t.UTC()
+	return t.Format("2006-01-02"), t.Format("2006-01")
+}
+
+func nextDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
+
+func nextMonth(t time.Time) time.Time {
+	y, m, _ := t.UTC().Date()
+	return time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openState opens the state file for the named provider's kind of state,
+// such as quota-gmail.json.
+func openState(kind, name string) (*state.File, error) {
+	return state.Open(kind + "-" + strings.NewReplacer("/", "_", `\`, "_").Replace(name) + ".json")
+}
@@ -0,0 +1,122 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/state"
+)
+
+// newTestLimiter returns a limiter whose clock stands still at *now and
+// whose waits are recorded in *waits instead of slept.
+func newTestLimiter(t *testing.T, limits Limits, now *time.Time, waits *[]time.Duration) *Limiter {
+	t.Helper()
+	l := NewLimiter("relay", limits, state.NewFile(filepath.Join(t.TempDir(), "quota.json")))
+	l.now = func() time.Time { return *now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return l
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	l := newTestLimiter(t, Limits{Rate: 30, Per: time.Minute, Burst: 2}, &now, &waits)
+
+	email := &Email{To: []string{"a@example.com"}}
+	for i := 0; i < 4; i++ {
+		if err := l.Limit(context.Background(), email, func() error { return nil }); err != nil {
+			t.Fatalf("Limit() %d error = %v", i, err)
+		}
+	}
+	// The burst goes at once; after that each send waits its turn, 2s
+	// apart, as the clock has not moved.
+	want := []time.Duration{2 * time.Second, 4 * time.Second}
+	if len(waits) != len(want) || waits[0] != want[0] || waits[1] != want[1] {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+
+	now = now.Add(time.Minute)
+	status, err := l.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Available != 2 {
+		t.Errorf("Status().Available = %d a minute later, want the full burst of 2", status.Available)
+	}
+
+	// A wait that would outlast the deadline fails at once.
+	waits = nil
+	for i := 0; i < 2; i++ {
+		_ = l.Limit(context.Background(), email, func() error { return nil })
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = l.Limit(ctx, email, func() error { return nil })
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !IsTransient(err) {
+		t.Fatalf("Limit() error = %v, want a transient *LimitError", err)
+	}
+	if len(waits) != 0 {
+		t.Errorf("waits = %v, want none", waits)
+	}
+}
+
+func TestLimiter_Quotas(t *testing.T) {
+	now := time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	l := newTestLimiter(t, Limits{Daily: 3, Monthly: 5}, &now, &waits)
+
+	two := &Email{To: []string{"a@example.com"}, Cc: []string{"b@example.com"}}
+	send := func() error { return nil }
+	if err := l.Limit(context.Background(), two, send); err != nil {
+		t.Fatalf("Limit() error = %v", err)
+	}
+	err := l.Limit(context.Background(), two, send)
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !strings.Contains(err.Error(), "daily quota of 3") {
+		t.Fatalf("Limit() error = %v, want the daily quota reached", err)
+	}
+	if want := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC); !limitErr.Reset.Equal(want) {
+		t.Errorf("Reset = %v, want %v", limitErr.Reset, want)
+	}
+
+	// A failed send gives its recipients back.
+	failed := errors.New("connection refused")
+	if err := l.Limit(context.Background(), &Email{To: []string{"c@example.com"}}, func() error { return failed }); err != failed {
+		t.Fatalf("Limit() error = %v, want the send's error", err)
+	}
+	status, err := l.Status()
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	if status.Today != 2 || status.Month != 2 {
+		t.Errorf("Status() today = %d, month = %d, want 2 and 2", status.Today, status.Month)
+	}
+
+	// A new day and month start the counts again.
+	now = now.Add(2 * time.Hour)
+	if err := l.Limit(context.Background(), two, send); err != nil {
+		t.Fatalf("Limit() the next day error = %v", err)
+	}
+
+	// More recipients than the quota can never be sent.
+	four := &Email{To: []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"}}
+	if err := l.Limit(context.Background(), four, send); err == nil || IsTransient(err) {
+		t.Errorf("Limit() error = %v, want a permanent error", err)
+	}
+}
+
+func TestLimiter_Nil(t *testing.T) {
+	var l *Limiter
+	sent := false
+	if err := l.Limit(context.Background(), &Email{}, func() error { sent = true; return nil }); err != nil || !sent {
+		t.Errorf("nil Limit() = %v, sent = %v", err, sent)
+	}
+}
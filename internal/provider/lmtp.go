@@ -0,0 +1,170 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+const defaultLMTPPort = 24
+
+// LMTP delivers into a local mail store such as Dovecot over RFC 2033
+// LMTP, on a Unix socket or TCP. Unlike SMTP, the server answers DATA
+// once per recipient, so a message can be accepted for some recipients
+// and rejected for others.
+type LMTP struct {
+	from      string
+	config    *config.LMTPConfig
+	transport *Transport
+}
+
+func NewLMTP(from string, cfg *config.LMTPConfig, t *Transport) (*LMTP, error) {
+	if cfg.Socket == "" && cfg.Host == "" {
+		return nil, fmt.Errorf("lmtp socket or host is required")
+	}
+	return &LMTP{
+		from:      from,
+		config:    cfg,
+		transport: t,
+	}, nil
+}
+
+func (l *LMTP) Name() string {
+	return "lmtp"
+}
+
+func (l *LMTP) address() (network, addr string) {
+	if l.config.Socket != "" {
+		return "unix", l.config.Socket
+	}
+	port := l.config.Port
+	if port == 0 {
+		port = defaultLMTPPort
+	}
+	return "tcp", net.JoinHostPort(l.config.Host, strconv.Itoa(port))
+}
+
+func (l *LMTP) Send(ctx context.Context, email *Email) error {
+	if len(email.envelopeRecipients()) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	network, addr := l.address()
+	var (
+		conn net.Conn
+		err  error
+	)
+	if network == "unix" {
+		conn, err = (&net.Dialer{Timeout: l.transport.connectTimeout()}).DialContext(ctx, network, addr)
+	} else {
+		conn, err = l.transport.dialContext(ctx, network, addr)
+	}
+	if err != nil {
+		return contextError(ctx, fmt.Errorf("dial failed: %w", err))
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	text := textproto.NewConn(conn)
+	defer text.Close()
+
+	return contextError(ctx, l.deliver(text, addr, email))
+}
+
+func (l *LMTP) deliver(text *textproto.Conn, addr string, email *Email) error {
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("lmtp greeting failed: %w", err)
+	}
+
+	extensions, err := lhlo(text)
+	if err != nil {
+		return fmt.Errorf("lhlo failed: %w", err)
+	}
+	extension := func(name string) bool {
+		_, ok := extensions[name]
+		return ok
+	}
+
+	tx, err := prepareTransaction(l.from, email, extension, "lmtp server "+addr)
+	if err != nil {
+		return err
+	}
+
+	if _, err := lmtpCmd(text, 250, "MAIL FROM:<"+tx.from+">", tx.mailParams); err != nil {
+		return fmt.Errorf("mail from failed: %w", err)
+	}
+	for _, rcpt := range tx.recipients {
+		if _, err := lmtpCmd(text, 25, "RCPT TO:<"+rcpt+">", tx.rcptParams(rcpt)); err != nil {
+			return fmt.Errorf("rcpt to failed: %w", err)
+		}
+	}
+	if _, err := lmtpCmd(text, 354, "DATA", nil); err != nil {
+		return fmt.Errorf("data failed: %w", err)
+	}
+
+	w := text.DotWriter()
+	if _, err := w.Write(tx.msg); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("close failed: %w", &ambiguousError{err})
+	}
+
+	// One reply per accepted recipient, in RCPT order.
+	var failed []string
+	for _, rcpt := range tx.recipients {
+		if _, _, err := text.ReadResponse(250); err != nil {
+			if _, ok := err.(*textproto.Error); !ok {
+				return fmt.Errorf("data failed: %w", &ambiguousError{err})
+			}
+			failed = append(failed, fmt.Sprintf("%s: %v", rcpt, err))
+		}
+	}
+
+	_, _ = lmtpCmd(text, 221, "QUIT", nil)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("lmtp delivery failed for %d of %d recipients: %s",
+			len(failed), len(tx.recipients), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// lhlo greets the server and returns the extensions it advertises, keyed
+// by upper-case keyword.
+func lhlo(text *textproto.Conn) (map[string]string, error) {
+	msg, err := lmtpCmd(text, 250, "LHLO localhost", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	extensions := make(map[string]string)
+	lines := strings.Split(msg, "\n")
+	for _, line := range lines[1:] {
+		keyword, args, _ := strings.Cut(line, " ")
+		extensions[strings.ToUpper(keyword)] = args
+	}
+	return extensions, nil
+}
+
+func lmtpCmd(text *textproto.Conn, expectCode int, line string, params []string) (string, error) {
+	if strings.ContainsAny(line, "\r\n") {
+		return "", fmt.Errorf("lmtp: a line must not contain CR or LF")
+	}
+	if len(params) > 0 {
+		line += " " + strings.Join(params, " ")
+	}
+	id, err := text.Cmd("%s", line)
+	if err != nil {
+		return "", err
+	}
+	text.StartResponse(id)
+	defer text.EndResponse(id)
+	_, msg, err := text.ReadResponse(expectCode)
+	return msg, err
+}
@@ -0,0 +1,158 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// fakeLMTPServer accepts one session on a Unix socket and rejects the
+// recipients in reject after DATA.
+type fakeLMTPServer struct {
+	socket string
+	reject map[string]bool
+
+	mu       sync.Mutex
+	commands []string
+	message  string
+}
+
+func newFakeLMTPServer(t *testing.T, reject ...string) *fakeLMTPServer {
+	t.Helper()
+
+	f := &fakeLMTPServer{
+		socket: filepath.Join(t.TempDir(), "lmtp.sock"),
+		reject: make(map[string]bool),
+	}
+	for _, r := range reject {
+		f.reject[r] = true
+	}
+
+	ln, err := net.Listen("unix", f.socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		f.serve(conn)
+	}()
+	return f
+}
+
+func (f *fakeLMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	var rcpts []string
+
+	_ = tp.PrintfLine("220 localhost LMTP ready")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		f.mu.Unlock()
+
+		switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+		case "LHLO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250-8BITMIME")
+			_ = tp.PrintfLine("250 PIPELINING")
+		case "MAIL":
+			_ = tp.PrintfLine("250 OK")
+		case "RCPT":
+			rcpts = append(rcpts, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.message = string(data)
+			f.mu.Unlock()
+			for _, rcpt := range rcpts {
+				if f.reject[rcpt] {
+					_ = tp.PrintfLine("552 5.2.2 <%s> Mailbox full", rcpt)
+				} else {
+					_ = tp.PrintfLine("250 2.0.0 <%s> Saved", rcpt)
+				}
+			}
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+func (f *fakeLMTPServer) sent() ([]string, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...), f.message
+}
+
+func TestLMTPSend(t *testing.T) {
+	server := newFakeLMTPServer(t)
+	l, err := NewLMTP("sender@example.com", &config.LMTPConfig{Socket: server.socket}, nil)
+	if err != nil {
+		t.Fatalf("NewLMTP() error = %v", err)
+	}
+
+	err = l.Send(context.Background(), &Email{
+		To:      []string{"alice@example.com"},
+		Cc:      []string{"bob@example.com"},
+		Subject: "stored",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	commands, message := server.sent()
+	want := []string{
+		"LHLO localhost",
+		"MAIL FROM:<sender@example.com>",
+		"RCPT TO:<alice@example.com>",
+		"RCPT TO:<bob@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands = %q, want %q", commands, want)
+	}
+	if !strings.Contains(message, "Subject: stored\n") || !strings.HasSuffix(message, "hello\n") {
+		t.Fatalf("unexpected message: %q", message)
+	}
+}
+
+func TestLMTPSend_PartialFailure(t *testing.T) {
+	server := newFakeLMTPServer(t, "bob@example.com")
+	l, err := NewLMTP("sender@example.com", &config.LMTPConfig{Socket: server.socket}, nil)
+	if err != nil {
+		t.Fatalf("NewLMTP() error = %v", err)
+	}
+
+	err = l.Send(context.Background(), &Email{
+		To:      []string{"alice@example.com", "bob@example.com"},
+		Subject: "stored",
+		Body:    "hello",
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 recipients") || !strings.Contains(err.Error(), "bob@example.com: 552") {
+		t.Fatalf("Send() error = %v, want per-recipient failure", err)
+	}
+}
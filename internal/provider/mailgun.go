@@ -0,0 +1,185 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// Mailgun keeps US and EU domains on separate API hosts.
+var (
+	mailgunAPIBase   = "https://api.mailgun.net"
+	mailgunEUAPIBase = "https://api.eu.mailgun.net"
+)
+
+// Mailgun sends through the Mailgun messages API for one sending domain.
+type Mailgun struct {
+	from    string
+	apiKey  string
+	domain  string
+	apiBase string
+	client  *http.Client
+}
+
+func NewMailgun(from string, cfg *config.MailgunConfig, t *Transport) (*Mailgun, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("mailgun api_key is required")
+	}
+	if cfg.Domain == "" {
+		return nil, fmt.Errorf("mailgun domain is required")
+	}
+
+	var apiBase string
+	switch strings.ToLower(cfg.Region) {
+	case "", "us":
+		apiBase = mailgunAPIBase
+	case "eu":
+		apiBase = mailgunEUAPIBase
+	default:
+		return nil, fmt.Errorf("invalid mailgun region %q: must be us or eu", cfg.Region)
+	}
+
+	return &Mailgun{
+		from:    from,
+		apiKey:  cfg.APIKey,
+		domain:  cfg.Domain,
+		apiBase: apiBase,
+		client:  t.httpClient(),
+	}, nil
+}
+
+func (m *Mailgun) Name() string {
+	return "mailgun"
+}
+
+type mailgunError struct {
+	Message string `json:"message"`
+}
+
+func (m *Mailgun) Send(ctx context.Context, email *Email) error {
+	httpReq, err := m.request(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return doAPIRequest(m.client, httpReq, func(status int, respBody []byte) error {
+		var apiErr mailgunError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("mailgun error: %s (status %d)", apiErr.Message, status)
+		}
+		return fmt.Errorf("mailgun error: %s (status %d)", strings.TrimSpace(string(respBody)), status)
+	})
+}
+
+// Preview builds the request Send would make, with the API key redacted.
+func (m *Mailgun) Preview(email *Email) (*Preview, error) {
+	httpReq, err := m.request(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+	return previewRequest(httpReq, "Authorization")
+}
+
+func (m *Mailgun) request(ctx context.Context, email *Email) (*http.Request, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+
+	endpoint := "/messages"
+	if email.Raw != nil {
+		// messages.mime sends the message as-is; "to" is the envelope.
+		endpoint = "/messages.mime"
+		recipients := email.envelopeRecipients()
+		if len(recipients) == 0 {
+			return nil, fmt.Errorf("at least one recipient is required")
+		}
+		for _, addr := range recipients {
+			_ = form.WriteField("to", addr)
+		}
+		for _, tag := range email.Tags {
+			_ = form.WriteField("o:tag", tag)
+		}
+		part, err := form.CreateFormFile("message", "message.eml")
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+		if _, err := part.Write(email.Raw); err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+	} else {
+		msg, err := newAPIMessage(m.from, email)
+		if err != nil {
+			return nil, err
+		}
+		if err := mailgunForm(form, msg); err != nil {
+			return nil, err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/v3/%s%s", m.apiBase, url.PathEscape(m.domain), endpoint)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.SetBasicAuth("api", m.apiKey)
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	return httpReq, nil
+}
+
+// mailgunForm writes msg as the form fields of a messages request.
+func mailgunForm(form *multipart.Writer, msg *apiMessage) error {
+	var err error
+	write := func(key string, values ...string) {
+		for _, value := range values {
+			if err == nil {
+				err = form.WriteField(key, value)
+			}
+		}
+	}
+	write("from", msg.From)
+	write("to", msg.To...)
+	write("cc", msg.Cc...)
+	write("bcc", msg.Bcc...)
+	write("subject", msg.Subject)
+	if msg.Text != "" {
+		write("text", msg.Text)
+	}
+	if msg.HTML != "" {
+		write("html", msg.HTML)
+	}
+	write("o:tag", msg.Tags...)
+	for _, h := range msg.Headers {
+		write("h:"+h.Name, h.Value)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+
+	for _, file := range msg.Attachments {
+		part, err := form.CreatePart(mailgunFileHeader(file))
+		if err != nil {
+			return fmt.Errorf("failed to build request: %w", err)
+		}
+		if _, err := part.Write(file.content); err != nil {
+			return fmt.Errorf("failed to build request: %w", err)
+		}
+	}
+	return nil
+}
+
+func mailgunFileHeader(file attachmentFile) textproto.MIMEHeader {
+	return textproto.MIMEHeader{
+		"Content-Disposition": {fmt.Sprintf(`form-data; name="attachment"; filename="%s"`, sanitizeFilename(file.name))},
+		"Content-Type":        {file.contentType},
+	}
+}
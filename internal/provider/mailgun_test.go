@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// mailgunFormValues parses a captured multipart request into its fields
+// and the contents of its file parts.
+func mailgunFormValues(t *testing.T, req *http.Request, body []byte) (map[string][]string, map[string]string) {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	fields := make(map[string][]string)
+	files := make(map[string]string)
+	r := multipart.NewReader(strings.NewReader(string(body)), params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart() error = %v", err)
+		}
+		data, _ := io.ReadAll(part)
+		if part.FileName() != "" {
+			files[part.FormName()+"/"+part.FileName()] = string(data)
+			continue
+		}
+		fields[part.FormName()] = append(fields[part.FormName()], string(data))
+	}
+	return fields, files
+}
+
+func TestMailgunSend_EURegionForm(t *testing.T) {
+	api := newFakeAPI(t, &mailgunEUAPIBase, http.StatusOK, `{"id":"<1@mg.example.com>","message":"Queued. Thank you."}`)
+
+	m, err := NewMailgun("alerts@mg.example.com", &config.MailgunConfig{
+		APIKey: "key-test",
+		Domain: "mg.example.com",
+		Region: "eu",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewMailgun() error = %v", err)
+	}
+	err = m.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com", "bob@example.com"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+		Tags:        []string{"reports", "weekly"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.URL.Path != "/v3/mg.example.com/messages" {
+		t.Fatalf("path = %q", req.URL.Path)
+	}
+	if user, pass, _ := req.BasicAuth(); user != "api" || pass != "key-test" {
+		t.Fatalf("basic auth = %q:%q, want api:key-test", user, pass)
+	}
+	fields, files := mailgunFormValues(t, req, body)
+	if got := strings.Join(fields["to"], ","); got != "alice@example.com,bob@example.com" {
+		t.Fatalf("to = %q", got)
+	}
+	if got := strings.Join(fields["o:tag"], ","); got != "reports,weekly" {
+		t.Fatalf("o:tag = %q", got)
+	}
+	if fields["bcc"][0] != "audit@example.com" || fields["h:X-Campaign"][0] != "q3" || fields["text"][0] != "Attached" {
+		t.Fatalf("fields = %v", fields)
+	}
+	if files["attachment/report.pdf"] != "%PDF" {
+		t.Fatalf("files = %v", files)
+	}
+}
+
+func TestMailgunSend_RawUsesMIMEEndpoint(t *testing.T) {
+	api := newFakeAPI(t, &mailgunAPIBase, http.StatusOK, `{"message":"Queued. Thank you."}`)
+
+	m, err := NewMailgun("alerts@mg.example.com", &config.MailgunConfig{APIKey: "key-test", Domain: "mg.example.com"}, nil)
+	if err != nil {
+		t.Fatalf("NewMailgun() error = %v", err)
+	}
+	raw := "From: alerts@mg.example.com\r\nTo: alice@example.com\r\nSubject: Raw\r\n\r\nBody\r\n"
+	err = m.Send(context.Background(), &Email{To: []string{"alice@example.com", "hidden@example.com"}, Raw: []byte(raw)})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.URL.Path != "/v3/mg.example.com/messages.mime" {
+		t.Fatalf("path = %q", req.URL.Path)
+	}
+	fields, files := mailgunFormValues(t, req, body)
+	if got := strings.Join(fields["to"], ","); got != "alice@example.com,hidden@example.com" {
+		t.Fatalf("to = %q", got)
+	}
+	if files["message/message.eml"] != raw {
+		t.Fatalf("message = %q", files["message/message.eml"])
+	}
+}
+
+func TestMailgunSend_HTTPError(t *testing.T) {
+	newFakeAPI(t, &mailgunAPIBase, http.StatusUnauthorized, `{"message":"Invalid private key"}`)
+
+	m, err := NewMailgun("alerts@mg.example.com", &config.MailgunConfig{APIKey: "bad", Domain: "mg.example.com"}, nil)
+	if err != nil {
+		t.Fatalf("NewMailgun() error = %v", err)
+	}
+	err = m.Send(context.Background(), &Email{To: []string{"alice@example.com"}, Subject: "x", Body: "y"})
+	want := "mailgun error: Invalid private key (status 401)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+}
+
+func TestNewMailgun_RejectsUnknownRegion(t *testing.T) {
+	_, err := NewMailgun("a@example.com", &config.MailgunConfig{APIKey: "k", Domain: "d", Region: "ap"}, nil)
+	if err == nil {
+		t.Fatal("NewMailgun() error = nil, want error")
+	}
+}
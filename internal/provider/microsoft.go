@@ -0,0 +1,376 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// These are variables so tests can point at a fake server.
+var (
+	microsoftAuthority = "https://login.microsoftonline.com"
+	graphAPIBase       = "https://graph.microsoft.com/v1.0"
+)
+
+const (
+	microsoftMailSendScope = "https://graph.microsoft.com/Mail.Send"
+
+	// Graph rejects requests over 4 MB. Attachments go inline while their
+	// base64 encoding totals at most graphRequestBudget, leaving room for
+	// the rest of the request. The others are added to a draft, one
+	// request each, or through an upload session when even one would not
+	// fit. Upload chunks must be multiples of 320 KiB.
+	graphRequestBudget         = 3584 * 1024
+	graphInlineAttachmentLimit = graphRequestBudget / 4 * 3
+	graphUploadChunkSize       = 10 * 320 * 1024
+)
+
+// Microsoft sends through the Microsoft Graph API as the signed-in user,
+// for Microsoft 365 and Outlook.com accounts where SMTP AUTH is disabled.
+type Microsoft struct {
+	from      string
+	config    *config.MicrosoftConfig
+	transport *Transport
+
+	// saveToken, if set, persists access tokens obtained by refreshing.
+	saveToken func(*oauth2.Token) error
+}
+
+func NewMicrosoft(from string, cfg *config.MicrosoftConfig, t *Transport) (*Microsoft, error) {
+	if cfg.ClientID == "" {
+		return nil, fmt.Errorf("microsoft client_id is required")
+	}
+	return &Microsoft{
+		from:      from,
+		config:    cfg,
+		transport: t,
+	}, nil
+}
+
+func (m *Microsoft) Name() string {
+	return "microsoft"
+}
+
+// microsoftOAuthConfig returns the public client config for tenant
+// (default "common").
+func microsoftOAuthConfig(clientID, tenant string) *oauth2.Config {
+	if tenant == "" {
+		tenant = "common"
+	}
+	base := fmt.Sprintf("%s/%s/oauth2/v2.0", microsoftAuthority, url.PathEscape(tenant))
+	return &oauth2.Config{
+		ClientID: clientID,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:       base + "/authorize",
+			DeviceAuthURL: base + "/devicecode",
+			TokenURL:      base + "/token",
+			AuthStyle:     oauth2.AuthStyleInParams,
+		},
+		Scopes: []string{microsoftMailSendScope, "offline_access"},
+	}
+}
+
+func GetMicrosoftDeviceAuth(ctx context.Context, clientID, tenant string) (*oauth2.DeviceAuthResponse, error) {
+	auth, err := microsoftOAuthConfig(clientID, tenant).DeviceAuth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start device auth flow: %w", err)
+	}
+	return auth, nil
+}
+
+func ExchangeMicrosoftDeviceAuth(ctx context.Context, clientID, tenant string, auth *oauth2.DeviceAuthResponse) (*oauth2.Token, error) {
+	token, err := microsoftOAuthConfig(clientID, tenant).DeviceAccessToken(ctx, auth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete device auth flow: %w", err)
+	}
+	return token, nil
+}
+
+// client returns an HTTP client that authorizes Graph requests and
+// refreshes the access token as needed.
+func (m *Microsoft) client(ctx context.Context) *http.Client {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, m.transport.httpClient())
+
+	token := &oauth2.Token{
+		AccessToken:  m.config.AccessToken,
+		RefreshToken: m.config.RefreshToken,
+	}
+	if m.config.TokenExpiry != "" {
+		if expiry, err := time.Parse(time.RFC3339, m.config.TokenExpiry); err == nil {
+			token.Expiry = expiry
+		}
+	}
+
+	source := microsoftOAuthConfig(m.config.ClientID, m.config.Tenant).TokenSource(ctx, token)
+	if m.saveToken != nil {
+		source = &persistingTokenSource{base: source, last: token.AccessToken, save: m.saveToken}
+	}
+	return oauth2.NewClient(ctx, source)
+}
+
+type graphRecipient struct {
+	EmailAddress graphEmailAddress `json:"emailAddress"`
+}
+
+type graphEmailAddress struct {
+	Address string `json:"address"`
+	Name    string `json:"name,omitempty"`
+}
+
+type graphBody struct {
+	ContentType string `json:"contentType"`
+	Content     string `json:"content"`
+}
+
+type graphAttachment struct {
+	ODataType    string `json:"@odata.type"`
+	Name         string `json:"name"`
+	ContentType  string `json:"contentType"`
+	ContentBytes string `json:"contentBytes"`
+}
+
+type graphMessage struct {
+	Subject       string            `json:"subject"`
+	Body          graphBody         `json:"body"`
+	From          *graphRecipient   `json:"from,omitempty"`
+	ToRecipients  []graphRecipient  `json:"toRecipients,omitempty"`
+	CcRecipients  []graphRecipient  `json:"ccRecipients,omitempty"`
+	BccRecipients []graphRecipient  `json:"bccRecipients,omitempty"`
+	Attachments   []graphAttachment `json:"attachments,omitempty"`
+}
+
+type graphError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (m *Microsoft) Send(ctx context.Context, email *Email) error {
+	if len(email.envelopeRecipients()) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+	client := m.client(ctx)
+
+	if email.Raw != nil {
+		return m.sendMIME(ctx, client, email)
+	}
+
+	msg := graphMessage{
+		Subject:       email.Subject,
+		Body:          graphBody{ContentType: "Text", Content: email.Body},
+		ToRecipients:  graphRecipients(email.To),
+		CcRecipients:  graphRecipients(email.Cc),
+		BccRecipients: graphRecipients(email.Bcc),
+	}
+	if email.HTML {
+		msg.Body.ContentType = "HTML"
+	}
+	if email.From != "" {
+		msg.From = graphSender(email.From)
+	}
+
+	// The budget is shared by every inline attachment, so several that
+	// each fit may still not fit together.
+	var separate []graphAttachment
+	var large []attachmentFile
+	inline := len(msg.Body.Content)
+	for _, att := range email.Attachments {
+		file, err := readAttachment(att)
+		if err != nil {
+			return err
+		}
+		if len(file.content) > graphInlineAttachmentLimit {
+			large = append(large, file)
+			continue
+		}
+		attachment := graphAttachment{
+			ODataType:    "#microsoft.graph.fileAttachment",
+			Name:         file.name,
+			ContentType:  file.contentType,
+			ContentBytes: base64.StdEncoding.EncodeToString(file.content),
+		}
+		if inline+len(attachment.ContentBytes) > graphRequestBudget {
+			separate = append(separate, attachment)
+			continue
+		}
+		inline += len(attachment.ContentBytes)
+		msg.Attachments = append(msg.Attachments, attachment)
+	}
+
+	if len(separate) == 0 && len(large) == 0 {
+		return m.do(ctx, client, http.MethodPost, "/me/sendMail", "application/json", map[string]any{
+			"message":         msg,
+			"saveToSentItems": true,
+		}, nil)
+	}
+	return m.sendWithUploads(ctx, client, msg, separate, large)
+}
+
+// sendWithUploads creates a draft, adds the attachments that did not fit
+// inline to it, uploading large ones in chunks, then sends the draft. The
+// draft is deleted if any step fails.
+func (m *Microsoft) sendWithUploads(ctx context.Context, client *http.Client, msg graphMessage, separate []graphAttachment, large []attachmentFile) error {
+	var draft struct {
+		ID string `json:"id"`
+	}
+	if err := m.do(ctx, client, http.MethodPost, "/me/messages", "application/json", msg, &draft); err != nil {
+		return err
+	}
+	if draft.ID == "" {
+		return fmt.Errorf("microsoft graph did not return a draft id")
+	}
+	draftPath := "/me/messages/" + url.PathEscape(draft.ID)
+
+	err := func() error {
+		for _, attachment := range separate {
+			if err := m.do(ctx, client, http.MethodPost, draftPath+"/attachments", "application/json", attachment, nil); err != nil {
+				return fmt.Errorf("failed to attach %s: %w", attachment.Name, err)
+			}
+		}
+		for _, file := range large {
+			if err := m.upload(ctx, client, draftPath, file); err != nil {
+				return err
+			}
+		}
+		return m.do(ctx, client, http.MethodPost, draftPath+"/send", "", nil, nil)
+	}()
+	if err != nil {
+		// Best effort: don't leave a half-built draft in the mailbox.
+		_ = m.do(context.WithoutCancel(ctx), client, http.MethodDelete, draftPath, "", nil, nil)
+		return err
+	}
+	return nil
+}
+
+func (m *Microsoft) upload(ctx context.Context, client *http.Client, draftPath string, file attachmentFile) error {
+	var session struct {
+		UploadURL string `json:"uploadUrl"`
+	}
+	err := m.do(ctx, client, http.MethodPost, draftPath+"/attachments/createUploadSession", "application/json", map[string]any{
+		"AttachmentItem": map[string]any{
+			"attachmentType": "file",
+			"name":           file.name,
+			"size":           len(file.content),
+			"contentType":    file.contentType,
+		},
+	}, &session)
+	if err != nil {
+		return fmt.Errorf("failed to start upload of %s: %w", file.name, err)
+	}
+
+	// The upload URL is pre-authorized and rejects an Authorization
+	// header, so chunks go through the plain transport.
+	plain := m.transport.httpClient()
+	total := len(file.content)
+	for start := 0; start < total; start += graphUploadChunkSize {
+		end := min(start+graphUploadChunkSize, total)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, session.UploadURL, bytes.NewReader(file.content[start:end]))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/octet-stream")
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end-1, total))
+
+		resp, err := plain.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", file.name, err)
+		}
+		err = graphResponseError(resp)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", file.name, err)
+		}
+	}
+	return nil
+}
+
+// sendMIME sends a caller-supplied message as base64 MIME. Graph reads the
+// recipients from the headers, so envelope-only recipients are added as Bcc.
+func (m *Microsoft) sendMIME(ctx context.Context, client *http.Client, email *Email) error {
+	msg, err := bccHeaderMessage(email, nil)
+	if err != nil {
+		return err
+	}
+	body := base64.StdEncoding.EncodeToString(crlf(msg))
+	return m.do(ctx, client, http.MethodPost, "/me/sendMail", "text/plain", body, nil)
+}
+
+// do sends a Graph request. A string body is sent as is; anything else is
+// encoded as JSON. out, if set, receives the decoded response.
+func (m *Microsoft) do(ctx context.Context, client *http.Client, method, path, contentType string, body any, out any) error {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, graphAPIBase+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := graphResponseError(resp); err != nil {
+		return err
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode microsoft graph response: %w", err)
+		}
+	}
+	return nil
+}
+
+func graphResponseError(resp *http.Response) error {
+	if resp.StatusCode < 400 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	var apiErr graphError
+	if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+		return responseError(resp, fmt.Errorf("microsoft graph error: %s: %s (status %d)", apiErr.Error.Code, apiErr.Error.Message, resp.StatusCode))
+	}
+	return responseError(resp, fmt.Errorf("microsoft graph error: %s (status %d)", string(respBody), resp.StatusCode))
+}
+
+func graphRecipients(addrs []string) []graphRecipient {
+	var out []graphRecipient
+	for _, addr := range sanitizeAddressList(addrs) {
+		out = append(out, *graphSender(addr))
+	}
+	return out
+}
+
+// graphSender splits "Name <addr>" into Graph's address and name fields.
+func graphSender(addr string) *graphRecipient {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return &graphRecipient{EmailAddress: graphEmailAddress{Address: parsed.Address, Name: parsed.Name}}
+	}
+	return &graphRecipient{EmailAddress: graphEmailAddress{Address: addr}}
+}
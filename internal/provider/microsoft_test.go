@@ -0,0 +1,348 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"golang.org/x/oauth2"
+)
+
+// fakeGraph stands in for Microsoft Graph and the Microsoft identity
+// platform token endpoints.
+type fakeGraph struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	sendMail map[string]any
+	mimeBody []byte
+	draft    map[string]any
+	uploads  map[string][]byte
+	attached []string
+	ranges   []string
+	sent     bool
+	deleted  bool
+	failSend bool
+}
+
+func newFakeGraph(t *testing.T) *fakeGraph {
+	t.Helper()
+
+	f := &fakeGraph{uploads: make(map[string][]byte)}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.server.Close)
+
+	origAuthority, origBase := microsoftAuthority, graphAPIBase
+	microsoftAuthority = f.server.URL
+	graphAPIBase = f.server.URL + "/v1.0"
+	t.Cleanup(func() { microsoftAuthority, graphAPIBase = origAuthority, origBase })
+	return f
+}
+
+func (f *fakeGraph) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+
+	if strings.HasPrefix(r.URL.Path, "/upload/") {
+		if r.Header.Get("Authorization") != "" {
+			http.Error(w, "upload URLs must not be sent an Authorization header", http.StatusUnauthorized)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		name := strings.TrimPrefix(r.URL.Path, "/upload/")
+		f.uploads[name] = append(f.uploads[name], data...)
+		f.ranges = append(f.ranges, r.Header.Get("Content-Range"))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/v1.0/") && r.Header.Get("Authorization") != "Bearer test-access-token" {
+		http.Error(w, `{"error":{"code":"InvalidAuthenticationToken","message":"bad token"}}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method + " " + r.URL.Path {
+	case "POST /contoso/oauth2/v2.0/devicecode":
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://microsoft.com/devicelogin",
+			"expires_in":       900,
+			"interval":         1,
+		})
+	case "POST /contoso/oauth2/v2.0/token":
+		_ = r.ParseForm()
+		if r.Form.Get("client_id") != "client-123" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("device_code") == "" && r.Form.Get("refresh_token") != "old-refresh" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "test-access-token",
+			"refresh_token": "new-refresh",
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+		})
+	case "POST /v1.0/me/sendMail":
+		data, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") == "text/plain" {
+			f.mimeBody, _ = base64.StdEncoding.DecodeString(string(data))
+		} else {
+			_ = json.Unmarshal(data, &f.sendMail)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	case "POST /v1.0/me/messages":
+		_ = json.NewDecoder(r.Body).Decode(&f.draft)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": "draft-1"})
+	case "POST /v1.0/me/messages/draft-1/attachments/createUploadSession":
+		var req struct {
+			AttachmentItem struct {
+				Name string `json:"name"`
+				Size int    `json:"size"`
+			}
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(map[string]any{"uploadUrl": f.server.URL + "/upload/" + req.AttachmentItem.Name})
+	case "POST /v1.0/me/messages/draft-1/attachments":
+		var att struct {
+			Name string `json:"name"`
+		}
+		data, _ := io.ReadAll(r.Body)
+		if len(data) > 4*1024*1024 {
+			http.Error(w, `{"error":{"code":"RequestEntityTooLarge","message":"too large"}}`, http.StatusRequestEntityTooLarge)
+			return
+		}
+		_ = json.Unmarshal(data, &att)
+		f.attached = append(f.attached, att.Name)
+		w.WriteHeader(http.StatusCreated)
+	case "POST /v1.0/me/messages/draft-1/send":
+		if f.failSend {
+			http.Error(w, `{"error":{"code":"ErrorQuotaExceeded","message":"mailbox full"}}`, http.StatusForbidden)
+			return
+		}
+		f.sent = true
+		w.WriteHeader(http.StatusAccepted)
+	case "DELETE /v1.0/me/messages/draft-1":
+		f.deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unexpected request", http.StatusNotFound)
+	}
+}
+
+func newTestMicrosoft(t *testing.T) *Microsoft {
+	t.Helper()
+	m, err := NewMicrosoft("me@contoso.com", &config.MicrosoftConfig{
+		ClientID:    "client-123",
+		Tenant:      "contoso",
+		AccessToken: "test-access-token",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewMicrosoft() error = %v", err)
+	}
+	return m
+}
+
+func TestMicrosoftSend_SendMail(t *testing.T) {
+	graph := newFakeGraph(t)
+	m := newTestMicrosoft(t)
+
+	err := m.Send(context.Background(), &Email{
+		To:          []string{"Alice <alice@example.com>"},
+		Bcc:         []string{"hidden@example.com"},
+		Subject:     "Hello",
+		Body:        "<p>Hi</p>",
+		HTML:        true,
+		Attachments: []Attachment{{Filename: "notes.txt", Content: []byte("small")}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	got, err := json.Marshal(graph.sendMail)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	for _, want := range []string{
+		`"address":"alice@example.com","name":"Alice"`,
+		`"bccRecipients":[{"emailAddress":{"address":"hidden@example.com"}}]`,
+		`"contentType":"HTML"`,
+		`"contentBytes":"` + base64.StdEncoding.EncodeToString([]byte("small")) + `"`,
+		`"saveToSentItems":true`,
+	} {
+		if !strings.Contains(string(got), want) {
+			t.Fatalf("sendMail request missing %s:\n%s", want, got)
+		}
+	}
+}
+
+func TestMicrosoftSend_LargeAttachmentUsesUploadSession(t *testing.T) {
+	graph := newFakeGraph(t)
+	m := newTestMicrosoft(t)
+
+	large := bytes.Repeat([]byte("x"), graphInlineAttachmentLimit+graphUploadChunkSize/2)
+	path := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(path, large, 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	err := m.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Subject:     "Big",
+		Body:        "See attached",
+		Attachments: []Attachment{{Path: path}, {Filename: "small.txt", Content: []byte("small")}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if graph.sendMail != nil {
+		t.Fatal("large attachment was sent inline through sendMail")
+	}
+	if !graph.sent {
+		t.Fatalf("draft was not sent; requests = %v", graph.requests)
+	}
+	if !bytes.Equal(graph.uploads["big.bin"], large) {
+		t.Fatalf("uploaded %d bytes, want %d", len(graph.uploads["big.bin"]), len(large))
+	}
+	wantRanges := []string{
+		fmt.Sprintf("bytes 0-%d/%d", graphUploadChunkSize-1, len(large)),
+		fmt.Sprintf("bytes %d-%d/%d", graphUploadChunkSize, len(large)-1, len(large)),
+	}
+	if len(graph.ranges) != 2 || graph.ranges[0] != wantRanges[0] || graph.ranges[1] != wantRanges[1] {
+		t.Fatalf("Content-Range headers = %v, want %v", graph.ranges, wantRanges)
+	}
+	if atts, _ := graph.draft["attachments"].([]any); len(atts) != 1 {
+		t.Fatalf("draft inline attachments = %v, want only the small one", graph.draft["attachments"])
+	}
+}
+
+func TestMicrosoftSend_AttachmentsOverRequestLimit(t *testing.T) {
+	graph := newFakeGraph(t)
+	m := newTestMicrosoft(t)
+
+	// Each fits inline alone, but not both in one 4 MB request.
+	file := bytes.Repeat([]byte("x"), 5*1024*1024/2)
+	err := m.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Subject:     "Two",
+		Body:        "See attached",
+		Attachments: []Attachment{{Filename: "one.bin", Content: file}, {Filename: "two.bin", Content: file}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if graph.sendMail != nil {
+		t.Fatal("both attachments were sent inline through sendMail")
+	}
+	if atts, _ := graph.draft["attachments"].([]any); len(atts) != 1 {
+		t.Fatalf("draft inline attachments = %d, want 1", len(atts))
+	}
+	if len(graph.attached) != 1 || graph.attached[0] != "two.bin" || len(graph.uploads) != 0 {
+		t.Fatalf("attached = %v, uploads = %d, want two.bin added to the draft", graph.attached, len(graph.uploads))
+	}
+	if !graph.sent {
+		t.Fatalf("draft was not sent; requests = %v", graph.requests)
+	}
+}
+
+func TestMicrosoftSend_DeletesDraftWhenSendFails(t *testing.T) {
+	graph := newFakeGraph(t)
+	graph.failSend = true
+	m := newTestMicrosoft(t)
+
+	err := m.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Subject:     "Big",
+		Body:        "See attached",
+		Attachments: []Attachment{{Filename: "big.bin", Content: make([]byte, graphInlineAttachmentLimit+1)}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "mailbox full") {
+		t.Fatalf("Send() error = %v, want the Graph error", err)
+	}
+	if !graph.deleted {
+		t.Fatal("draft was not deleted after the send failed")
+	}
+}
+
+func TestMicrosoftSend_RawMessage(t *testing.T) {
+	graph := newFakeGraph(t)
+	m := newTestMicrosoft(t)
+
+	raw := "From: me@contoso.com\nTo: alice@example.com\nSubject: Raw\n\nBody\n"
+	err := m.Send(context.Background(), &Email{
+		To:  []string{"alice@example.com", "hidden@example.com"},
+		Raw: []byte(raw),
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	got := string(graph.mimeBody)
+	if !strings.HasPrefix(got, "Bcc: hidden@example.com\r\n") || !strings.Contains(got, "Subject: Raw\r\n") {
+		t.Fatalf("MIME body = %q", got)
+	}
+}
+
+func TestMicrosoftDeviceAuthAndRefresh(t *testing.T) {
+	newFakeGraph(t)
+	ctx := context.Background()
+
+	auth, err := GetMicrosoftDeviceAuth(ctx, "client-123", "contoso")
+	if err != nil {
+		t.Fatalf("GetMicrosoftDeviceAuth() error = %v", err)
+	}
+	if auth.UserCode != "ABCD-EFGH" {
+		t.Fatalf("UserCode = %q, want %q", auth.UserCode, "ABCD-EFGH")
+	}
+	token, err := ExchangeMicrosoftDeviceAuth(ctx, "client-123", "contoso", auth)
+	if err != nil {
+		t.Fatalf("ExchangeMicrosoftDeviceAuth() error = %v", err)
+	}
+	if token.AccessToken != "test-access-token" {
+		t.Fatalf("AccessToken = %q, want %q", token.AccessToken, "test-access-token")
+	}
+
+	// An expired token is refreshed before sending and the result saved.
+	m, err := NewMicrosoft("me@contoso.com", &config.MicrosoftConfig{
+		ClientID:     "client-123",
+		Tenant:       "contoso",
+		AccessToken:  "expired",
+		RefreshToken: "old-refresh",
+		TokenExpiry:  time.Now().Add(-time.Hour).Format(time.RFC3339),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewMicrosoft() error = %v", err)
+	}
+	var saved *oauth2.Token
+	m.saveToken = func(token *oauth2.Token) error {
+		saved = token
+		return nil
+	}
+
+	if err := m.Send(ctx, &Email{To: []string{"alice@example.com"}, Subject: "Hi", Body: "Hi"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if saved == nil || saved.AccessToken != "test-access-token" || saved.RefreshToken != "new-refresh" {
+		t.Fatalf("saved token = %+v, want the refreshed token", saved)
+	}
+}
@@ -0,0 +1,188 @@
+package provider
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func generateBoundary() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("boundary-%d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("boundary-%x", b)
+}
+
+// bccHeaderMessage renders email with a Bcc header naming the recipients
+// that would otherwise exist only in the envelope: email.Bcc, or for a
+// caller-supplied message, envelope recipients missing from To and Cc.
+// It is for consumers that read recipients from the headers, such as
+// the Gmail API and saved drafts.
+func bccHeaderMessage(email *Email, build func(*Email) ([]byte, error)) ([]byte, error) {
+	var (
+		msg []byte
+		bcc []string
+	)
+	if email.Raw == nil {
+		var err error
+		if msg, err = build(email); err != nil {
+			return nil, err
+		}
+		bcc = sanitizeAddressList(email.Bcc)
+	} else {
+		parsed, err := parseRawMessage(email.Raw)
+		if err != nil {
+			return nil, err
+		}
+		msg = email.Raw
+		_, _, bcc = envelopeSplit(parsed, email.envelopeRecipients())
+	}
+
+	if len(bcc) == 0 {
+		return msg, nil
+	}
+	header := fmt.Sprintf("Bcc: %s\r\n", strings.Join(bcc, ", "))
+	return append([]byte(header), msg...), nil
+}
+
+// BuildMessage renders email as a MIME message, without a Bcc header, for
+// sending as Raw to a different set of envelope recipients. defaultFrom is
+// used when the email does not set its own From address.
+func BuildMessage(defaultFrom string, email *Email) ([]byte, error) {
+	return buildMessage(defaultFrom, email)
+}
+
+// buildMessage renders email as a MIME message. defaultFrom is used when
+// the email does not set its own From address.
+func buildMessage(defaultFrom string, email *Email) ([]byte, error) {
+	var msg strings.Builder
+
+	from := email.From
+	if from == "" {
+		from = defaultFrom
+	}
+
+	// Headers
+	msg.WriteString(fmt.Sprintf("From: %s\r\n", sanitizeHeaderValue(from)))
+	to := sanitizeAddressList(email.To)
+	if len(to) > 0 {
+		msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ", ")))
+	}
+	cc := sanitizeAddressList(email.Cc)
+	if len(cc) > 0 {
+		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(cc, ", ")))
+	}
+	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", sanitizeHeaderValue(email.Subject)))
+	for _, h := range email.Headers {
+		msg.WriteString(fmt.Sprintf("%s: %s\r\n", sanitizeHeaderValue(h.Name), sanitizeHeaderValue(h.Value)))
+	}
+	msg.WriteString("MIME-Version: 1.0\r\n")
+
+	if len(email.Attachments) > 0 {
+		boundary := generateBoundary()
+		msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
+		msg.WriteString("\r\n")
+
+		// Body part
+		msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+		contentType := "text/plain"
+		if email.HTML {
+			contentType = "text/html"
+		}
+		msg.WriteString(fmt.Sprintf("Content-Type: %s; charset=\"UTF-8\"\r\n", contentType))
+		msg.WriteString("\r\n")
+		msg.WriteString(email.Body)
+		msg.WriteString("\r\n")
+
+		// Attachments
+		for _, att := range email.Attachments {
+			content := att.Content
+			if content == nil && att.Path != "" {
+				data, err := os.ReadFile(att.Path)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
+				}
+				content = data
+			}
+
+			filename := att.Filename
+			if filename == "" && att.Path != "" {
+				filename = filepath.Base(att.Path)
+			}
+			filename = sanitizeFilename(filename)
+
+			mimeType := mime.TypeByExtension(filepath.Ext(filename))
+			if mimeType == "" {
+				mimeType = "application/octet-stream"
+			}
+
+			msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
+			msg.WriteString(fmt.Sprintf("Content-Type: %s\r\n", mimeType))
+			msg.WriteString("Content-Transfer-Encoding: base64\r\n")
+			msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n", filename))
+			msg.WriteString("\r\n")
+
+			encoded := base64.StdEncoding.EncodeToString(content)
+			// Wrap at 76 chars
+			for i := 0; i < len(encoded); i += 76 {
+				end := i + 76
+				if end > len(encoded) {
+					end = len(encoded)
+				}
+				msg.WriteString(encoded[i:end])
+				msg.WriteString("\r\n")
+			}
+		}
+
+		msg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
+	} else {
+		contentType := "text/plain"
+		if email.HTML {
+			contentType = "text/html"
+		}
+		msg.WriteString(fmt.Sprintf("Content-Type: %s; charset=\"UTF-8\"\r\n", contentType))
+		msg.WriteString("\r\n")
+		msg.WriteString(email.Body)
+	}
+
+	return []byte(msg.String()), nil
+}
+
+// attachmentFile is an attachment read into memory.
+type attachmentFile struct {
+	name        string
+	contentType string
+	content     []byte
+}
+
+// readAttachment loads att for providers that upload attachments as API
+// fields rather than MIME parts.
+func readAttachment(att Attachment) (attachmentFile, error) {
+	content := att.Content
+	if att.Path != "" {
+		var err error
+		content, err = os.ReadFile(att.Path)
+		if err != nil {
+			return attachmentFile{}, fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
+		}
+	}
+
+	name := att.Filename
+	if name == "" && att.Path != "" {
+		name = filepath.Base(att.Path)
+	}
+	if name == "" {
+		name = "attachment"
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return attachmentFile{name: name, contentType: contentType, content: content}, nil
+}
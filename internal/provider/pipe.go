@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// Pipe delivers by running a command, such as "/usr/sbin/sendmail -oi -t",
+// with the message on its stdin. Lines end in LF, as local delivery
+// commands expect.
+//
+// In the command, an argument of exactly {recipients} expands to one
+// argument per envelope recipient and {from} is replaced by the envelope
+// sender. Both are also exported as EMAIL_CLI_RECIPIENTS (space-separated)
+// and EMAIL_CLI_FROM. A command given -t instead, as sendmail -t is, reads
+// the recipients from the message, so it gets a Bcc header for it to
+// remove.
+type Pipe struct {
+	from string
+	args []string
+}
+
+func NewPipe(from string, cfg *config.PipeConfig) (*Pipe, error) {
+	args, err := splitCommand(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipe command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("pipe command is required")
+	}
+	return &Pipe{from: from, args: args}, nil
+}
+
+func (p *Pipe) Name() string {
+	return "pipe"
+}
+
+func (p *Pipe) Send(ctx context.Context, email *Email) error {
+	recipients := email.envelopeRecipients()
+	if len(recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	from := sanitizeHeaderValue(p.from)
+	if email.From != "" {
+		from = sanitizeHeaderValue(email.From)
+	}
+
+	var msg []byte
+	var err error
+	if p.recipientsFromHeaders() {
+		msg, err = bccHeaderMessage(email, func(e *Email) ([]byte, error) {
+			return buildMessage(from, e)
+		})
+	} else if msg = email.Raw; msg == nil {
+		msg, err = buildMessage(from, email)
+	}
+	if err != nil {
+		return err
+	}
+	msg = bytes.ReplaceAll(msg, []byte("\r\n"), []byte("\n"))
+
+	var args []string
+	for _, arg := range p.args {
+		if arg == "{recipients}" {
+			args = append(args, recipients...)
+			continue
+		}
+		args = append(args, strings.ReplaceAll(arg, "{from}", from))
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(msg)
+	cmd.Env = append(os.Environ(),
+		"EMAIL_CLI_FROM="+from,
+		"EMAIL_CLI_RECIPIENTS="+strings.Join(recipients, " "),
+	)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("send aborted: %w", ctx.Err())
+		}
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("pipe command %s failed: %w: %s", args[0], err, out)
+		}
+		return fmt.Errorf("pipe command %s failed: %w", args[0], err)
+	}
+	return nil
+}
+
+// recipientsFromHeaders reports whether the command takes its recipients
+// from the message's To, Cc and Bcc headers, as sendmail -t does, rather
+// than from {recipients}.
+func (p *Pipe) recipientsFromHeaders() bool {
+	readsHeaders := false
+	for _, arg := range p.args[1:] {
+		switch arg {
+		case "{recipients}":
+			return false
+		case "-t":
+			readsHeaders = true
+		}
+	}
+	return readsHeaders
+}
+
+// splitCommand splits a command line into arguments, honouring single
+// quotes, double quotes and backslash escapes the way a POSIX shell does.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("\"\\$`", r) {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inWord = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("trailing backslash")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inWord {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
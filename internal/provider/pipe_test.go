@@ -0,0 +1,121 @@
+package provider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"/usr/sbin/sendmail -oi -t", []string{"/usr/sbin/sendmail", "-oi", "-t"}},
+		{`sh -c 'cat > "$1"' deliver`, []string{"sh", "-c", `cat > "$1"`, "deliver"}},
+		{`deliver "two words" a\ b "q\"uote" "back\slash"`, []string{"deliver", "two words", "a b", `q"uote`, `back\slash`}},
+		{`empty ""`, []string{"empty", ""}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.in)
+		if err != nil {
+			t.Fatalf("splitCommand(%q) error = %v", tt.in, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("splitCommand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{`unterminated "quote`, `trailing \`} {
+		if _, err := splitCommand(in); err == nil {
+			t.Fatalf("splitCommand(%q) error = nil, want error", in)
+		}
+	}
+}
+
+func TestPipeSend(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "message")
+	args := filepath.Join(dir, "args")
+
+	p, err := NewPipe("sender@example.com", &config.PipeConfig{
+		Command: `sh -c 'cat > "$0"; printf "%s\n" "$@" "$EMAIL_CLI_RECIPIENTS" > "$1"' ` + out + " " + args + " -f {from} -- {recipients}",
+	})
+	if err != nil {
+		t.Fatalf("NewPipe() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Bcc:     []string{"hidden@example.com"},
+		Subject: "piped",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	msg, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if strings.Contains(string(msg), "\r") {
+		t.Fatalf("message contains CR: %q", msg)
+	}
+	if !strings.Contains(string(msg), "From: sender@example.com\nTo: to@example.com\nSubject: piped\n") {
+		t.Fatalf("unexpected message: %q", msg)
+	}
+
+	gotArgs, err := os.ReadFile(args)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	want := args + "\n-f\nsender@example.com\n--\nto@example.com\nhidden@example.com\nto@example.com hidden@example.com\n"
+	if string(gotArgs) != want {
+		t.Fatalf("args = %q, want %q", gotArgs, want)
+	}
+}
+
+func TestPipeSend_RecipientsFromHeaders(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "message")
+	// Like sendmail -t, the script has only the message to go on.
+	p, err := NewPipe("sender@example.com", &config.PipeConfig{Command: `sh -c 'cat > "$0"' ` + out + " -oi -t"})
+	if err != nil {
+		t.Fatalf("NewPipe() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Bcc:     []string{"hidden@example.com"},
+		Subject: "piped",
+		Body:    "hello",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	msg, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !strings.HasPrefix(string(msg), "Bcc: hidden@example.com\n") || !strings.Contains(string(msg), "To: to@example.com\n") {
+		t.Fatalf("message = %q, want the Bcc recipient in a Bcc header", msg)
+	}
+}
+
+func TestPipeSend_CommandFails(t *testing.T) {
+	p, err := NewPipe("sender@example.com", &config.PipeConfig{Command: `sh -c 'echo "no such user" >&2; exit 67'`})
+	if err != nil {
+		t.Fatalf("NewPipe() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{To: []string{"to@example.com"}, Subject: "s", Body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "exit status 67") || !strings.Contains(err.Error(), "no such user") {
+		t.Fatalf("Send() error = %v, want exit status and stderr", err)
+	}
+}
@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// pluginProtocol is the version of the plugin protocol spoken here.
+const pluginProtocol = 1
+
+// Plugin sends through an external executable. For each request the
+// command is run once, given a single JSON request on stdin, and must
+// write a single JSON response to stdout:
+//
+//	{"protocol": 1, "type": "send", "options": {...}, "email": {...}}
+//	{"ok": true}
+//	{"ok": false, "error": "mailbox unavailable"}
+//	{"ok": false, "error": "upstream timed out", "transient": true}
+//
+// Requests are "capabilities", "health" and "send". Options are the
+// provider's configured options, and email is the same JSON form the
+// webhook provider posts. Errors are permanent unless marked transient.
+// Anything the plugin writes to stderr is included in errors.
+type Plugin struct {
+	from    string
+	args    []string
+	options map[string]string
+
+	caps *pluginCapabilities
+}
+
+func NewPlugin(from string, cfg *config.PluginConfig) (*Plugin, error) {
+	args, err := splitCommand(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("plugin command is required")
+	}
+	return &Plugin{from: from, args: args, options: cfg.Options}, nil
+}
+
+func (p *Plugin) Name() string {
+	return "plugin"
+}
+
+type pluginRequest struct {
+	Protocol int               `json:"protocol"`
+	Type     string            `json:"type"`
+	Options  map[string]string `json:"options,omitempty"`
+	Email    *jsonEmail        `json:"email,omitempty"`
+}
+
+// pluginCapabilities says which parts of an email a plugin can deliver.
+// Tags are dropped for plugins without them, as other providers do; the
+// rest are errors, since the message would otherwise change.
+type pluginCapabilities struct {
+	Protocol    int  `json:"protocol"`
+	HTML        bool `json:"html"`
+	Attachments bool `json:"attachments"`
+	Headers     bool `json:"headers"`
+	Tags        bool `json:"tags"`
+}
+
+type pluginResponse struct {
+	OK           bool                `json:"ok"`
+	Error        string              `json:"error,omitempty"`
+	Transient    bool                `json:"transient,omitempty"`
+	Capabilities *pluginCapabilities `json:"capabilities,omitempty"`
+}
+
+func (p *Plugin) Send(ctx context.Context, email *Email) error {
+	caps, err := p.capabilities(ctx)
+	if err != nil {
+		return err
+	}
+
+	msg, err := newAPIMessage(p.from, email)
+	if err != nil {
+		return err
+	}
+	switch {
+	case msg.HTML != "" && !caps.HTML:
+		return fmt.Errorf("plugin %s does not support HTML bodies", p.args[0])
+	case len(msg.Attachments) > 0 && !caps.Attachments:
+		return fmt.Errorf("plugin %s does not support attachments", p.args[0])
+	case len(msg.Headers) > 0 && !caps.Headers:
+		return fmt.Errorf("plugin %s does not support custom headers", p.args[0])
+	}
+	if !caps.Tags {
+		msg.Tags = nil
+	}
+
+	j := newJSONEmail(msg, email.Raw)
+	_, err = p.call(ctx, "send", &j)
+	return err
+}
+
+// Preview builds the send request the plugin would be given, with option
+// values redacted. The plugin is not run, so its capabilities are not
+// checked.
+func (p *Plugin) Preview(email *Email) (*Preview, error) {
+	msg, err := newAPIMessage(p.from, email)
+	if err != nil {
+		return nil, err
+	}
+	j := newJSONEmail(msg, email.Raw)
+
+	var options map[string]string
+	if len(p.options) > 0 {
+		options = make(map[string]string, len(p.options))
+		for key := range p.options {
+			options[key] = "[REDACTED]"
+		}
+	}
+	body, err := json.Marshal(pluginRequest{Protocol: pluginProtocol, Type: "send", Options: options, Email: &j})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+	return &Preview{Request: "exec " + strings.Join(p.args, " "), Body: body}, nil
+}
+
+// Check asks the plugin whether it is ready to send, for example whether
+// its credentials are valid.
+func (p *Plugin) Check(ctx context.Context) error {
+	if _, err := p.capabilities(ctx); err != nil {
+		return err
+	}
+	_, err := p.call(ctx, "health", nil)
+	return err
+}
+
+func (p *Plugin) capabilities(ctx context.Context) (*pluginCapabilities, error) {
+	if p.caps != nil {
+		return p.caps, nil
+	}
+	resp, err := p.call(ctx, "capabilities", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Capabilities == nil {
+		return nil, fmt.Errorf("plugin %s returned no capabilities", p.args[0])
+	}
+	if resp.Capabilities.Protocol != pluginProtocol {
+		return nil, fmt.Errorf("plugin %s speaks protocol %d, want %d", p.args[0], resp.Capabilities.Protocol, pluginProtocol)
+	}
+	p.caps = resp.Capabilities
+	return p.caps, nil
+}
+
+// call runs the plugin with one request and decodes its response.
+func (p *Plugin) call(ctx context.Context, typ string, email *jsonEmail) (*pluginResponse, error) {
+	req, err := json.Marshal(pluginRequest{
+		Protocol: pluginProtocol,
+		Type:     typ,
+		Options:  p.options,
+		Email:    email,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal plugin request: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.args[0], p.args[1:]...)
+	cmd.Stdin = bytes.NewReader(append(req, '\n'))
+	cmd.Env = append(os.Environ(), fmt.Sprintf("EMAIL_CLI_PLUGIN_PROTOCOL=%d", pluginProtocol))
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("send aborted: %w", ctx.Err())
+	}
+
+	var resp pluginResponse
+	if err := json.Unmarshal(bytes.TrimSpace(stdout.Bytes()), &resp); err != nil {
+		if runErr != nil {
+			if out := strings.TrimSpace(stderr.String()); out != "" {
+				return nil, fmt.Errorf("plugin %s failed: %w: %s", p.args[0], runErr, out)
+			}
+			return nil, fmt.Errorf("plugin %s failed: %w", p.args[0], runErr)
+		}
+		return nil, fmt.Errorf("plugin %s returned an invalid %s response: %w", p.args[0], typ, err)
+	}
+	if !resp.OK {
+		if resp.Error == "" {
+			resp.Error = "no error given"
+		}
+		err := fmt.Errorf("plugin %s %s failed: %s", p.args[0], typ, resp.Error)
+		if resp.Transient {
+			err = &transientError{err: err}
+		}
+		return nil, err
+	}
+	if runErr != nil {
+		return nil, fmt.Errorf("plugin %s failed: %w", p.args[0], runErr)
+	}
+	return &resp, nil
+}
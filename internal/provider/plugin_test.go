@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// writePlugin writes a shell script plugin that saves each request to
+// dir/<type>.json and answers with caps for capabilities requests and
+// reply otherwise.
+func writePlugin(t *testing.T, caps, reply string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "email-cli-provider-test")
+	body := "#!/bin/sh\n" +
+		"req=$(cat)\n" +
+		"case \"$req\" in\n" +
+		"*'\"type\":\"capabilities\"'*) printf '%s\\n' \"$req\" > " + dir + "/capabilities.json; echo '" + caps + "' ;;\n" +
+		"*'\"type\":\"health\"'*) printf '%s\\n' \"$req\" > " + dir + "/health.json; echo '" + reply + "' ;;\n" +
+		"*) printf '%s\\n' \"$req\" > " + dir + "/send.json; echo '" + reply + "' ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(script, []byte(body), 0o755); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return script, dir
+}
+
+const allCaps = `{"ok":true,"capabilities":{"protocol":1,"html":true,"attachments":true,"headers":true,"tags":true}}`
+
+func TestPluginSend(t *testing.T) {
+	script, dir := writePlugin(t, allCaps, `{"ok":true}`)
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{
+		Command: script + " --verbose",
+		Options: map[string]string{"region": "eu"},
+	})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Tags:        []string{"weekly"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "send.json"))
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	var req pluginRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if req.Protocol != 1 || req.Type != "send" || req.Options["region"] != "eu" {
+		t.Fatalf("request = %+v", req)
+	}
+	if req.Email == nil || req.Email.From != "alerts@example.com" || req.Email.Subject != "Report" {
+		t.Fatalf("email = %+v", req.Email)
+	}
+	if len(req.Email.Attachments) != 1 || req.Email.Attachments[0].Content != "JVBERg==" || req.Email.Tags[0] != "weekly" {
+		t.Fatalf("email = %+v", req.Email)
+	}
+}
+
+func TestPluginSend_Error(t *testing.T) {
+	script, _ := writePlugin(t, allCaps, `{"ok":false,"error":"mailbox unavailable"}`)
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{Command: script})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y"})
+	if err == nil || !strings.HasSuffix(err.Error(), "send failed: mailbox unavailable") {
+		t.Fatalf("Send() error = %v, want the plugin's error", err)
+	}
+}
+
+func TestPluginSend_Capabilities(t *testing.T) {
+	script, dir := writePlugin(t, `{"ok":true,"capabilities":{"protocol":1}}`, `{"ok":true}`)
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{Command: script})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{
+		To:          []string{"a@example.com"},
+		Attachments: []Attachment{{Filename: "a.txt", Content: []byte("a")}},
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support attachments") {
+		t.Fatalf("Send() error = %v, want unsupported attachments", err)
+	}
+
+	// Tags are dropped rather than refused.
+	if err := p.Send(context.Background(), &Email{To: []string{"a@example.com"}, Body: "y", Tags: []string{"t"}}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "send.json"))
+	if strings.Contains(string(data), `"tags"`) {
+		t.Fatalf("tags sent to a plugin without them: %s", data)
+	}
+}
+
+func TestPluginSend_ProtocolMismatch(t *testing.T) {
+	script, _ := writePlugin(t, `{"ok":true,"capabilities":{"protocol":2}}`, `{"ok":true}`)
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{Command: script})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{To: []string{"a@example.com"}, Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "speaks protocol 2") {
+		t.Fatalf("Send() error = %v, want protocol mismatch", err)
+	}
+}
+
+func TestPluginCheck(t *testing.T) {
+	script, dir := writePlugin(t, allCaps, `{"ok":true}`)
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{Command: script})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+	if err := p.Check(context.Background()); err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "health.json")); err != nil {
+		t.Fatalf("health request not made: %v", err)
+	}
+}
+
+func TestPluginSend_CommandFailure(t *testing.T) {
+	p, err := NewPlugin("alerts@example.com", &config.PluginConfig{Command: `sh -c 'echo "no credentials" >&2; exit 3'`})
+	if err != nil {
+		t.Fatalf("NewPlugin() error = %v", err)
+	}
+
+	err = p.Send(context.Background(), &Email{To: []string{"a@example.com"}, Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: no credentials") {
+		t.Fatalf("Send() error = %v, want the exit status and stderr", err)
+	}
+}
@@ -0,0 +1,315 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/state"
+)
+
+// Pool strategies.
+const (
+	PoolRoundRobin = "round-robin"
+	PoolWeighted   = "weighted"
+	PoolQuota      = "quota"
+)
+
+// PoolMember is one provider in a pool.
+type PoolMember struct {
+	Member
+	// Weight is the member's share of sends with the weighted strategy.
+	Weight int
+	// DailyLimit, when set, caps the messages sent per UTC day.
+	DailyLimit int
+}
+
+// Pool spreads sends across its members to stay within per-account
+// limits. Each send goes to one member, picked by the strategy from those
+// under their daily limit; if it fails with a transient error, the next
+// pick is tried, as in a failover chain. Usage is kept in a state file,
+// so picks and limits carry over between runs.
+type Pool struct {
+	strategy string
+	members  []PoolMember
+	state    *state.File
+	now      func() time.Time
+
+	// OnFailover, when set, is called each time a member fails and
+	// another is tried.
+	OnFailover func(failed Member, err error, next Member)
+
+	delivered *Member
+}
+
+// poolState is what a pool remembers between runs.
+type poolState struct {
+	// Next is the member round-robin tries next.
+	Next int `json:"next,omitempty"`
+	// Current holds each member's smooth weighted round-robin counter.
+	Current map[string]int `json:"current,omitempty"`
+	// Usage counts each member's sends today.
+	Usage map[string]*PoolUsage `json:"usage,omitempty"`
+}
+
+// PoolUsage is how many messages a member sent on Day (UTC, YYYY-MM-DD).
+type PoolUsage struct {
+	Day  string `json:"day"`
+	Sent int    `json:"sent"`
+}
+
+func NewPool(strategy string, members []PoolMember, file *state.File) (*Pool, error) {
+	switch strategy {
+	case "":
+		strategy = PoolRoundRobin
+	case PoolRoundRobin, PoolWeighted, PoolQuota:
+	default:
+		return nil, fmt.Errorf("invalid pool strategy %q: must be round-robin, weighted or quota", strategy)
+	}
+	if len(members) == 0 {
+		return nil, fmt.Errorf("pool needs at least one provider")
+	}
+	for i := range members {
+		if members[i].Weight < 0 || members[i].DailyLimit < 0 {
+			return nil, fmt.Errorf("pool member %s: weight and daily limit must not be negative", members[i].Name)
+		}
+		if members[i].Weight == 0 {
+			members[i].Weight = 1
+		}
+	}
+	return &Pool{strategy: strategy, members: members, state: file, now: time.Now}, nil
+}
+
+func (p *Pool) Name() string {
+	return "pool"
+}
+
+func (p *Pool) Send(ctx context.Context, email *Email) error {
+	p.delivered = nil
+
+	tried := make([]bool, len(p.members))
+	var failures []string
+	var last *Member
+	var lastErr error
+	for {
+		i, err := p.reserve(tried)
+		if err != nil {
+			if len(failures) > 0 {
+				return fmt.Errorf("%w (after %s)", err, strings.Join(failures, "; "))
+			}
+			return err
+		}
+		if i < 0 {
+			return fmt.Errorf("all pool members failed: %s", strings.Join(failures, "; "))
+		}
+		m := &p.members[i].Member
+		if last != nil && p.OnFailover != nil {
+			p.OnFailover(*last, lastErr, *m)
+		}
+
+		err = m.send(ctx, email)
+		if err == nil {
+			p.delivered = m
+			return nil
+		}
+		if releaseErr := p.release(i); releaseErr != nil {
+			return fmt.Errorf("%s: %w (and %v)", m.Name, err, releaseErr)
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		if !IsTransient(err) {
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+		tried[i] = true
+		failures = append(failures, fmt.Sprintf("%s: %v", m.Name, err))
+		last, lastErr = m, err
+	}
+}
+
+func (p *Pool) Delivered() (Member, bool) {
+	if p.delivered == nil {
+		return Member{}, false
+	}
+	return *p.delivered, true
+}
+
+// Next returns the member the next send would go to, without reserving
+// it.
+func (p *Pool) Next() (Member, error) {
+	var st poolState
+	if err := p.state.Load(&st); err != nil {
+		return Member{}, err
+	}
+	i, err := p.pick(&st, make([]bool, len(p.members)))
+	if err != nil {
+		return Member{}, err
+	}
+	return p.members[i].Member, nil
+}
+
+// Usage returns each member's sends today, by member name.
+func (p *Pool) Usage() (map[string]int, error) {
+	var st poolState
+	if err := p.state.Load(&st); err != nil {
+		return nil, err
+	}
+	usage := make(map[string]int, len(p.members))
+	for _, m := range p.members {
+		usage[m.Name] = st.sentToday(m.Name, p.today())
+	}
+	return usage, nil
+}
+
+// PoolUsageFor returns each member's sends today for the pool configured by
+// cfg, without building its members.
+func PoolUsageFor(cfg *config.ProviderConfig) (map[string]int, error) {
+	if cfg.Pool == nil {
+		return nil, fmt.Errorf("pool config missing")
+	}
+	file, err := openState("pool", cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]PoolMember, len(cfg.Pool.Providers))
+	for i, m := range cfg.Pool.Providers {
+		members[i] = PoolMember{Member: Member{Name: m.Provider}, DailyLimit: m.DailyLimit}
+	}
+	p, err := NewPool(cfg.Pool.Strategy, members, file)
+	if err != nil {
+		return nil, err
+	}
+	return p.Usage()
+}
+
+// Members returns the pool's providers.
+func (p *Pool) Members() []PoolMember {
+	return p.members
+}
+
+// reserve picks a member that has not been tried and counts a send
+// against it, or returns -1 if every member under its limit has been
+// tried.
+func (p *Pool) reserve(tried []bool) (int, error) {
+	picked := -1
+	var st poolState
+	err := p.state.Update(&st, func() error {
+		i, err := p.pick(&st, tried)
+		if err != nil {
+			return err
+		}
+		picked = i
+		if i >= 0 {
+			st.count(p.members[i].Name, p.today(), 1)
+		}
+		return nil
+	})
+	return picked, err
+}
+
+// release gives back a send reserved for member i that was not delivered.
+func (p *Pool) release(i int) error {
+	var st poolState
+	return p.state.Update(&st, func() error {
+		st.count(p.members[i].Name, p.today(), -1)
+		return nil
+	})
+}
+
+// pick chooses a member by the pool's strategy, updating the strategy's
+// state. It returns -1 when every eligible member has been tried, and an
+// error when the rest are all at their daily limits.
+func (p *Pool) pick(st *poolState, tried []bool) (int, error) {
+	today := p.today()
+	var eligible []int
+	limited := false
+	for i, m := range p.members {
+		if tried[i] {
+			continue
+		}
+		if m.DailyLimit > 0 && st.sentToday(m.Name, today) >= m.DailyLimit {
+			limited = true
+			continue
+		}
+		eligible = append(eligible, i)
+	}
+	if len(eligible) == 0 {
+		if limited {
+			return -1, fmt.Errorf("all pool members have reached their daily limits")
+		}
+		return -1, nil
+	}
+
+	switch p.strategy {
+	case PoolWeighted:
+		// Smooth weighted round-robin, as nginx does it: picks are spread
+		// out in proportion to weight rather than bunched together.
+		if st.Current == nil {
+			st.Current = map[string]int{}
+		}
+		best, total := -1, 0
+		for _, i := range eligible {
+			m := p.members[i]
+			st.Current[m.Name] += m.Weight
+			total += m.Weight
+			if best < 0 || st.Current[m.Name] > st.Current[p.members[best].Name] {
+				best = i
+			}
+		}
+		st.Current[p.members[best].Name] -= total
+		return best, nil
+
+	case PoolQuota:
+		best, bestLeft := -1, -1
+		for _, i := range eligible {
+			m := p.members[i]
+			left := math.MaxInt
+			if m.DailyLimit > 0 {
+				left = m.DailyLimit - st.sentToday(m.Name, today)
+			}
+			if left > bestLeft {
+				best, bestLeft = i, left
+			}
+		}
+		return best, nil
+
+	default:
+		n := len(p.members)
+		for k := 0; k < n; k++ {
+			i := (st.Next + k) % n
+			for _, e := range eligible {
+				if e == i {
+					st.Next = (i + 1) % n
+					return i, nil
+				}
+			}
+		}
+		return eligible[0], nil
+	}
+}
+
+func (p *Pool) today() string {
+	return p.now().UTC().Format("2006-01-02")
+}
+
+func (st *poolState) sentToday(name, today string) int {
+	if u := st.Usage[name]; u != nil && u.Day == today {
+		return u.Sent
+	}
+	return 0
+}
+
+func (st *poolState) count(name, today string, n int) {
+	if st.Usage == nil {
+		st.Usage = map[string]*PoolUsage{}
+	}
+	u := st.Usage[name]
+	if u == nil || u.Day != today {
+		u = &PoolUsage{Day: today}
+		st.Usage[name] = u
+	}
+	u.Sent = max(u.Sent+n, 0)
+}
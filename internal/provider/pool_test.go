@@ -0,0 +1,202 @@
+package provider
+
+import (
+	"context"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/state"
+)
+
+// newTestPool returns a pool of stub members named a, b, c... with the
+// given weights and daily limits, keeping its state in file.
+func newTestPool(t *testing.T, strategy string, file *state.File, weights, limits []int) (*Pool, []*stubProvider) {
+	t.Helper()
+	var stubs []*stubProvider
+	for range weights {
+		stubs = append(stubs, &stubProvider{name: "stub"})
+	}
+	return newStubPool(t, strategy, file, stubs, weights, limits), stubs
+}
+
+func newStubPool(t *testing.T, strategy string, file *state.File, stubs []*stubProvider, weights, limits []int) *Pool {
+	t.Helper()
+	var members []PoolMember
+	for i, stub := range stubs {
+		members = append(members, PoolMember{
+			Member:     Member{Name: string(rune('a' + i)), Provider: stub},
+			Weight:     weights[i],
+			DailyLimit: limits[i],
+		})
+	}
+	p, err := NewPool(strategy, members, file)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	return p
+}
+
+func sends(stubs []*stubProvider) []int {
+	var n []int
+	for _, s := range stubs {
+		n = append(n, s.sends)
+	}
+	return n
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPoolSend_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy  string
+		weights   []int
+		limits    []int
+		n         int
+		wantSends []int
+	}{
+		{PoolRoundRobin, []int{0, 0, 0}, []int{0, 0, 0}, 7, []int{3, 2, 2}},
+		{PoolWeighted, []int{3, 1}, []int{0, 0}, 8, []int{6, 2}},
+		{PoolQuota, []int{0, 0}, []int{10, 4}, 8, []int{7, 1}},
+		{PoolRoundRobin, []int{0, 0}, []int{1, 0}, 4, []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			file := state.NewFile(filepath.Join(t.TempDir(), "pool.json"))
+			_, stubs := newTestPool(t, tt.strategy, file, tt.weights, tt.limits)
+			for i := 0; i < tt.n; i++ {
+				// A new pool each time, as each run of email-cli makes one.
+				p := newStubPool(t, tt.strategy, file, stubs, tt.weights, tt.limits)
+				if err := p.Send(context.Background(), &Email{}); err != nil {
+					t.Fatalf("Send() %d error = %v", i, err)
+				}
+			}
+			if got := sends(stubs); !equalInts(got, tt.wantSends) {
+				t.Errorf("sends = %v, want %v", got, tt.wantSends)
+			}
+		})
+	}
+}
+
+func TestPoolSend_DailyLimit(t *testing.T) {
+	file := state.NewFile(filepath.Join(t.TempDir(), "pool.json"))
+	p, stubs := newTestPool(t, PoolRoundRobin, file, []int{0, 0}, []int{1, 2})
+	day := time.Date(2026, 3, 1, 23, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return day }
+
+	for i := 0; i < 3; i++ {
+		if err := p.Send(context.Background(), &Email{}); err != nil {
+			t.Fatalf("Send() %d error = %v", i, err)
+		}
+	}
+	err := p.Send(context.Background(), &Email{})
+	if err == nil || !strings.Contains(err.Error(), "daily limits") {
+		t.Fatalf("Send() error = %v, want daily limits reached", err)
+	}
+	if got := sends(stubs); !equalInts(got, []int{1, 2}) {
+		t.Errorf("sends = %v, want [1 2]", got)
+	}
+
+	// Limits reset at midnight UTC.
+	day = day.Add(2 * time.Hour)
+	if err := p.Send(context.Background(), &Email{}); err != nil {
+		t.Fatalf("Send() next day error = %v", err)
+	}
+	usage, err := p.Usage()
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if usage["a"]+usage["b"] != 1 {
+		t.Errorf("Usage() = %v, want one send today", usage)
+	}
+}
+
+func TestPoolSend_Failover(t *testing.T) {
+	file := state.NewFile(filepath.Join(t.TempDir(), "pool.json"))
+	p, stubs := newTestPool(t, PoolRoundRobin, file, []int{0, 0}, []int{0, 0})
+	stubs[0].err = &textproto.Error{Code: 421, Msg: "try again later"}
+
+	var failed []string
+	p.OnFailover = func(f Member, err error, next Member) {
+		failed = append(failed, f.Name+"->"+next.Name)
+	}
+	if err := p.Send(context.Background(), &Email{}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if m, ok := p.Delivered(); !ok || m.Name != "b" {
+		t.Errorf("Delivered() = %v, %v, want b", m.Name, ok)
+	}
+	if len(failed) != 1 || failed[0] != "a->b" {
+		t.Errorf("OnFailover calls = %v, want [a->b]", failed)
+	}
+	usage, err := p.Usage()
+	if err != nil {
+		t.Fatalf("Usage() error = %v", err)
+	}
+	if usage["a"] != 0 || usage["b"] != 1 {
+		t.Errorf("Usage() = %v, want the failed send released", usage)
+	}
+
+	// A permanent error is not retried elsewhere.
+	stubs[0].err = &textproto.Error{Code: 550, Msg: "no such user"}
+	if err := p.Send(context.Background(), &Email{}); err == nil || !strings.HasPrefix(err.Error(), "a: ") {
+		t.Errorf("Send() error = %v, want a's permanent failure", err)
+	}
+	if got := sends(stubs); !equalInts(got, []int{2, 1}) {
+		t.Errorf("sends = %v, want [2 1]", got)
+	}
+}
+
+func TestPoolSend_AmbiguousTimeout(t *testing.T) {
+	file := state.NewFile(filepath.Join(t.TempDir(), "pool.json"))
+	backup := &stubProvider{name: "stub"}
+	p, err := NewPool(PoolRoundRobin, []PoolMember{
+		{Member: Member{Name: "a", Provider: ambiguousBlockingProvider{}, Timeout: 10 * time.Millisecond}},
+		{Member: Member{Name: "b", Provider: backup}},
+	}, file)
+	if err != nil {
+		t.Fatalf("NewPool() error = %v", err)
+	}
+	err = p.Send(context.Background(), &Email{})
+	if err == nil || !IsAmbiguous(err) {
+		t.Fatalf("Send() error = %v, want an ambiguous error", err)
+	}
+	if backup.sends != 0 {
+		t.Fatalf("b sent %d times, want 0 after an ambiguous timeout", backup.sends)
+	}
+}
+
+func TestPoolNext(t *testing.T) {
+	file := state.NewFile(filepath.Join(t.TempDir(), "pool.json"))
+	p, _ := newTestPool(t, PoolRoundRobin, file, []int{0, 0}, []int{0, 0})
+	for i := 0; i < 2; i++ {
+		if m, err := p.Next(); err != nil || m.Name != "a" {
+			t.Fatalf("Next() = %v, %v, want a", m.Name, err)
+		}
+	}
+	if err := p.Send(context.Background(), &Email{}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if m, err := p.Next(); err != nil || m.Name != "b" {
+		t.Errorf("Next() after a send = %v, %v, want b", m.Name, err)
+	}
+}
+
+func TestNewPool_InvalidStrategy(t *testing.T) {
+	members := []PoolMember{{Member: Member{Name: "a", Provider: &stubProvider{}}}}
+	if _, err := NewPool("random", members, state.NewFile(filepath.Join(t.TempDir(), "pool.json"))); err == nil {
+		t.Error("NewPool() should reject an unknown strategy")
+	}
+}
@@ -0,0 +1,141 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+var postmarkAPIBase = "https://api.postmarkapp.com"
+
+// Postmark sends through the Postmark email API with a server token.
+type Postmark struct {
+	from          string
+	serverToken   string
+	messageStream string
+	client        *http.Client
+}
+
+func NewPostmark(from string, cfg *config.PostmarkConfig, t *Transport) (*Postmark, error) {
+	if cfg.ServerToken == "" {
+		return nil, fmt.Errorf("postmark server_token is required")
+	}
+	return &Postmark{
+		from:          from,
+		serverToken:   cfg.ServerToken,
+		messageStream: cfg.MessageStream,
+		client:        t.httpClient(),
+	}, nil
+}
+
+func (p *Postmark) Name() string {
+	return "postmark"
+}
+
+type postmarkHeader struct {
+	Name  string `json:"Name"`
+	Value string `json:"Value"`
+}
+
+type postmarkAttachment struct {
+	Name        string `json:"Name"`
+	Content     string `json:"Content"` // base64 encoded
+	ContentType string `json:"ContentType"`
+}
+
+type postmarkRequest struct {
+	From          string               `json:"From"`
+	To            string               `json:"To,omitempty"`
+	Cc            string               `json:"Cc,omitempty"`
+	Bcc           string               `json:"Bcc,omitempty"`
+	Subject       string               `json:"Subject"`
+	TextBody      string               `json:"TextBody,omitempty"`
+	HtmlBody      string               `json:"HtmlBody,omitempty"`
+	Tag           string               `json:"Tag,omitempty"`
+	Headers       []postmarkHeader     `json:"Headers,omitempty"`
+	Attachments   []postmarkAttachment `json:"Attachments,omitempty"`
+	MessageStream string               `json:"MessageStream,omitempty"`
+}
+
+type postmarkError struct {
+	ErrorCode int    `json:"ErrorCode"`
+	Message   string `json:"Message"`
+}
+
+func (p *Postmark) Send(ctx context.Context, email *Email) error {
+	httpReq, err := p.request(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return doAPIRequest(p.client, httpReq, func(status int, respBody []byte) error {
+		var apiErr postmarkError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("postmark error: %s (code %d)", apiErr.Message, apiErr.ErrorCode)
+		}
+		return fmt.Errorf("postmark error: %s (status %d)", string(respBody), status)
+	})
+}
+
+// Preview builds the request Send would make, with the server token redacted.
+func (p *Postmark) Preview(email *Email) (*Preview, error) {
+	httpReq, err := p.request(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+	return previewRequest(httpReq, "X-Postmark-Server-Token")
+}
+
+func (p *Postmark) request(ctx context.Context, email *Email) (*http.Request, error) {
+	msg, err := newAPIMessage(p.from, email)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg.Tags) > 1 {
+		return nil, fmt.Errorf("postmark allows one tag per message, got %d", len(msg.Tags))
+	}
+
+	req := postmarkRequest{
+		From:          msg.From,
+		To:            strings.Join(msg.To, ", "),
+		Cc:            strings.Join(msg.Cc, ", "),
+		Bcc:           strings.Join(msg.Bcc, ", "),
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HtmlBody:      msg.HTML,
+		MessageStream: p.messageStream,
+	}
+	if len(msg.Tags) == 1 {
+		req.Tag = msg.Tags[0]
+	}
+	for _, h := range msg.Headers {
+		req.Headers = append(req.Headers, postmarkHeader{Name: h.Name, Value: h.Value})
+	}
+	for _, file := range msg.Attachments {
+		req.Attachments = append(req.Attachments, postmarkAttachment{
+			Name:        file.name,
+			Content:     base64.StdEncoding.EncodeToString(file.content),
+			ContentType: file.contentType,
+		})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, postmarkAPIBase+"/email", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("X-Postmark-Server-Token", p.serverToken)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	return httpReq, nil
+}
@@ -0,0 +1,81 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func TestPostmarkSend_MapsFields(t *testing.T) {
+	api := newFakeAPI(t, &postmarkAPIBase, http.StatusOK, `{"ErrorCode":0,"Message":"OK"}`)
+
+	p, err := NewPostmark("alerts@example.com", &config.PostmarkConfig{
+		ServerToken:   "server-token",
+		MessageStream: "broadcast",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewPostmark() error = %v", err)
+	}
+	err = p.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com", "bob@example.com"},
+		Cc:          []string{"carol@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+		Tags:        []string{"reports"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.URL.Path != "/email" || req.Header.Get("X-Postmark-Server-Token") != "server-token" {
+		t.Fatalf("request = %s, token %q", req.URL.Path, req.Header.Get("X-Postmark-Server-Token"))
+	}
+	var got postmarkRequest
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got.To != "alice@example.com, bob@example.com" || got.Cc != "carol@example.com" || got.TextBody != "Attached" {
+		t.Fatalf("request = %+v", got)
+	}
+	if got.Tag != "reports" || got.MessageStream != "broadcast" {
+		t.Fatalf("Tag = %q, MessageStream = %q", got.Tag, got.MessageStream)
+	}
+	if len(got.Headers) != 1 || got.Headers[0] != (postmarkHeader{Name: "X-Campaign", Value: "q3"}) {
+		t.Fatalf("Headers = %+v", got.Headers)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].Name != "report.pdf" || got.Attachments[0].ContentType != "application/pdf" {
+		t.Fatalf("Attachments = %+v", got.Attachments)
+	}
+}
+
+func TestPostmarkSend_HTTPError(t *testing.T) {
+	newFakeAPI(t, &postmarkAPIBase, http.StatusUnprocessableEntity,
+		`{"ErrorCode":300,"Message":"Invalid 'To' address: 'nobody'."}`)
+
+	p, err := NewPostmark("alerts@example.com", &config.PostmarkConfig{ServerToken: "server-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewPostmark() error = %v", err)
+	}
+	err = p.Send(context.Background(), &Email{To: []string{"nobody"}, Subject: "x", Body: "y"})
+	want := "postmark error: Invalid 'To' address: 'nobody'. (code 300)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+}
+
+func TestPostmarkSend_RejectsMultipleTags(t *testing.T) {
+	p, err := NewPostmark("alerts@example.com", &config.PostmarkConfig{ServerToken: "server-token"}, nil)
+	if err != nil {
+		t.Fatalf("NewPostmark() error = %v", err)
+	}
+	err = p.Send(context.Background(), &Email{To: []string{"a@example.com"}, Subject: "x", Body: "y", Tags: []string{"a", "b"}})
+	if err == nil {
+		t.Fatal("Send() error = nil, want error for two tags")
+	}
+}
@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"context"
+
 	"github.com/tnm/email-cli/internal/config"
 )
 
@@ -11,7 +13,7 @@ type Proton struct {
 	smtp *SMTP
 }
 
-func NewProton(from string, cfg *config.ProtonConfig) (*Proton, error) {
+func NewProton(from string, cfg *config.ProtonConfig, t *Transport) (*Proton, error) {
 	// Proton Bridge defaults
 	host := cfg.Host
 	if host == "" {
@@ -30,7 +32,7 @@ func NewProton(from string, cfg *config.ProtonConfig) (*Proton, error) {
 		UseTLS:   true, // Bridge uses STARTTLS
 	}
 
-	smtp, err := NewSMTP(from, smtpCfg)
+	smtp, err := NewSMTP(from, smtpCfg, t)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +44,6 @@ func (p *Proton) Name() string {
 	return "proton"
 }
 
-func (p *Proton) Send(email *Email) error {
-	return p.smtp.Send(email)
+func (p *Proton) Send(ctx context.Context, email *Email) error {
+	return p.smtp.Send(ctx, email)
 }
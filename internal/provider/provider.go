@@ -1,9 +1,11 @@
 package provider
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/tnm/email-cli/internal/config"
+	"golang.org/x/oauth2"
 )
 
 type Email struct {
@@ -15,6 +17,28 @@ type Email struct {
 	Body        string
 	HTML        bool
 	Attachments []Attachment
+	DSN         *DSN
+
+	// Headers are added to the message after the standard headers.
+	Headers []Header
+	// Tags label the message in providers that support them (SendGrid
+	// categories, Mailgun and Postmark tags, Resend tags). Others ignore
+	// them.
+	Tags []string
+
+	// Raw, when set, is a complete RFC 5322 message sent as-is. To, Cc and
+	// Bcc are then only the envelope recipients, and From (if set) is the
+	// envelope sender.
+	Raw []byte
+}
+
+// envelopeRecipients returns every sanitized To, Cc and Bcc address.
+func (e *Email) envelopeRecipients() []string {
+	recipients := make([]string, 0, len(e.To)+len(e.Cc)+len(e.Bcc))
+	recipients = append(recipients, sanitizeAddressList(e.To)...)
+	recipients = append(recipients, sanitizeAddressList(e.Cc)...)
+	recipients = append(recipients, sanitizeAddressList(e.Bcc)...)
+	return recipients
 }
 
 type Attachment struct {
@@ -24,11 +48,24 @@ type Attachment struct {
 }
 
 type Provider interface {
-	Send(email *Email) error
+	Send(ctx context.Context, email *Email) error
 	Name() string
 }
 
+// Checker is implemented by providers that can check their configuration
+// without sending anything.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
 func New(cfg *config.ProviderConfig) (Provider, error) {
+	switch cfg.Type {
+	case config.ProviderFailover:
+		return newFailover(cfg)
+	case config.ProviderPool:
+		return newPool(cfg)
+	}
+
 	// Resolve any keychain references before using config
 	resolved, err := cfg.ResolveSecrets()
 	if err != nil {
@@ -36,28 +73,186 @@ func New(cfg *config.ProviderConfig) (Provider, error) {
 	}
 	cfg = resolved
 
+	connectTimeout, _, err := cfg.Timeouts()
+	if err != nil {
+		return nil, err
+	}
+	transport := &Transport{ConnectTimeout: connectTimeout}
+	if cfg.Proxy != "" {
+		transport.Proxy, err = ParseProxyURL(cfg.Proxy)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	switch cfg.Type {
 	case config.ProviderGoogle:
 		if cfg.Google == nil {
 			return nil, fmt.Errorf("google config missing")
 		}
-		return NewGoogle(cfg.From, cfg.Google)
+		g, err := NewGoogle(cfg.From, cfg.Google, transport)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Name != "" {
+			name := cfg.Name
+			g.saveToken = func(token *oauth2.Token) error {
+				return config.SaveGoogleToken(name, token.AccessToken, token.RefreshToken, token.Expiry)
+			}
+		}
+		return g, nil
+	case config.ProviderMicrosoft:
+		if cfg.Microsoft == nil {
+			return nil, fmt.Errorf("microsoft config missing")
+		}
+		m, err := NewMicrosoft(cfg.From, cfg.Microsoft, transport)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Name != "" {
+			name := cfg.Name
+			m.saveToken = func(token *oauth2.Token) error {
+				return config.SaveMicrosoftToken(name, token.AccessToken, token.RefreshToken, token.Expiry)
+			}
+		}
+		return m, nil
+	case config.ProviderSES:
+		if cfg.SES == nil {
+			return nil, fmt.Errorf("ses config missing")
+		}
+		return NewSES(cfg.From, cfg.SES, transport)
+	case config.ProviderSendGrid:
+		if cfg.SendGrid == nil {
+			return nil, fmt.Errorf("sendgrid config missing")
+		}
+		return NewSendGrid(cfg.From, cfg.SendGrid, transport)
+	case config.ProviderMailgun:
+		if cfg.Mailgun == nil {
+			return nil, fmt.Errorf("mailgun config missing")
+		}
+		return NewMailgun(cfg.From, cfg.Mailgun, transport)
+	case config.ProviderPostmark:
+		if cfg.Postmark == nil {
+			return nil, fmt.Errorf("postmark config missing")
+		}
+		return NewPostmark(cfg.From, cfg.Postmark, transport)
+	case config.ProviderResend:
+		if cfg.Resend == nil {
+			return nil, fmt.Errorf("resend config missing")
+		}
+		return NewResend(cfg.From, cfg.Resend, transport)
+	case config.ProviderJMAP:
+		if cfg.JMAP == nil {
+			return nil, fmt.Errorf("jmap config missing")
+		}
+		return NewJMAP(cfg.From, cfg.JMAP, transport)
+	case config.ProviderWebhook:
+		if cfg.Webhook == nil {
+			return nil, fmt.Errorf("webhook config missing")
+		}
+		return NewWebhook(cfg.From, cfg.Webhook, transport)
+	case config.ProviderPlugin:
+		if cfg.Plugin == nil {
+			return nil, fmt.Errorf("plugin config missing")
+		}
+		return NewPlugin(cfg.From, cfg.Plugin)
+	case config.ProviderFile:
+		if cfg.File == nil {
+			return nil, fmt.Errorf("file config missing")
+		}
+		return NewFile(cfg.From, cfg.File)
 	case config.ProviderProton:
 		if cfg.Proton == nil {
 			return nil, fmt.Errorf("proton config missing")
 		}
-		return NewProton(cfg.From, cfg.Proton)
+		return NewProton(cfg.From, cfg.Proton, transport)
 	case config.ProviderSMTP:
 		if cfg.SMTP == nil {
 			return nil, fmt.Errorf("smtp config missing")
 		}
-		return NewSMTP(cfg.From, cfg.SMTP)
+		return NewSMTP(cfg.From, cfg.SMTP, transport)
 	case config.ProviderAgentMail:
 		if cfg.AgentMail == nil {
 			return nil, fmt.Errorf("agentmail config missing")
 		}
-		return NewAgentMail(cfg.AgentMail)
+		return NewAgentMail(cfg.AgentMail, transport)
+	case config.ProviderPipe:
+		if cfg.Pipe == nil {
+			return nil, fmt.Errorf("pipe config missing")
+		}
+		return NewPipe(cfg.From, cfg.Pipe)
+	case config.ProviderLMTP:
+		if cfg.LMTP == nil {
+			return nil, fmt.Errorf("lmtp config missing")
+		}
+		return NewLMTP(cfg.From, cfg.LMTP, transport)
 	default:
 		return nil, fmt.Errorf("unknown provider type: %s", cfg.Type)
 	}
 }
+
+// newFailover builds a failover provider from the member configs filled
+// in by config.GetProvider.
+func newFailover(cfg *config.ProviderConfig) (Provider, error) {
+	if cfg.Failover == nil {
+		return nil, fmt.Errorf("failover config missing")
+	}
+	if len(cfg.Failover.Members) != len(cfg.Failover.Providers) {
+		return nil, fmt.Errorf("failover providers not loaded for %q", cfg.Name)
+	}
+
+	members, err := newMembers(cfg.Failover.Members)
+	if err != nil {
+		return nil, err
+	}
+	return NewFailover(members)
+}
+
+// newPool builds a pool provider from the member configs filled in by
+// config.GetProvider. Its usage is kept in the state file
+// pool-<name>.json.
+func newPool(cfg *config.ProviderConfig) (Provider, error) {
+	if cfg.Pool == nil {
+		return nil, fmt.Errorf("pool config missing")
+	}
+	if len(cfg.Pool.Members) != len(cfg.Pool.Providers) {
+		return nil, fmt.Errorf("pool providers not loaded for %q", cfg.Name)
+	}
+
+	built, err := newMembers(cfg.Pool.Members)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]PoolMember, len(built))
+	for i, m := range built {
+		members[i] = PoolMember{Member: m, Weight: cfg.Pool.Providers[i].Weight, DailyLimit: cfg.Pool.Providers[i].DailyLimit}
+	}
+	file, err := openState("pool", cfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	return NewPool(cfg.Pool.Strategy, members, file)
+}
+
+// newMembers builds the members of a failover or pool provider. Each
+// keeps its own proxy, timeouts and limits.
+func newMembers(configs []config.ProviderConfig) ([]Member, error) {
+	members := make([]Member, 0, len(configs))
+	for i := range configs {
+		memberCfg := &configs[i]
+		p, err := New(memberCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", memberCfg.Name, err)
+		}
+		_, timeout, err := memberCfg.Timeouts()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", memberCfg.Name, err)
+		}
+		limiter, err := LimiterFor(memberCfg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", memberCfg.Name, err)
+		}
+		members = append(members, Member{Name: memberCfg.Name, Provider: p, Timeout: timeout, Limiter: limiter})
+	}
+	return members, nil
+}
@@ -0,0 +1,203 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// parsedMessage is the content of a raw RFC 5322 message, for providers
+// whose APIs take structured fields rather than MIME.
+type parsedMessage struct {
+	Subject     string
+	To          []string
+	Cc          []string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+func parseRawMessage(raw []byte) (*parsedMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+
+	parsed := &parsedMessage{Subject: subject}
+	if parsed.To, err = headerAddresses(msg.Header, "To"); err != nil {
+		return nil, err
+	}
+	if parsed.Cc, err = headerAddresses(msg.Header, "Cc"); err != nil {
+		return nil, err
+	}
+
+	header := textproto.MIMEHeader(msg.Header)
+	if err := parsed.walk(header, msg.Body); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// headerAddresses returns the bare addresses listed in a header.
+func headerAddresses(h mail.Header, key string) ([]string, error) {
+	if h.Get(key) == "" {
+		return nil, nil
+	}
+	list, err := h.AddressList(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %w", key, err)
+	}
+	out := make([]string, 0, len(list))
+	for _, addr := range list {
+		out = append(out, addr.Address)
+	}
+	return out, nil
+}
+
+// walk collects the first text and HTML bodies and every attachment.
+func (p *parsedMessage) walk(header textproto.MIMEHeader, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read mime part: %w", err)
+			}
+			if err := p.walk(part.Header, part); err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := io.ReadAll(decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode mime part: %w", err)
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	switch {
+	case disposition != "attachment" && mediaType == "text/plain" && p.Text == "":
+		p.Text = string(content)
+	case disposition != "attachment" && mediaType == "text/html" && p.HTML == "":
+		p.HTML = string(content)
+	default:
+		p.Attachments = append(p.Attachments, Attachment{
+			Filename: sanitizeFilename(filename),
+			Content:  content,
+		})
+	}
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, newlineStripper{r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// newlineStripper drops CR and LF so wrapped base64 can be decoded.
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (n newlineStripper) Read(p []byte) (int, error) {
+	for {
+		count, err := n.r.Read(p)
+		kept := 0
+		for _, b := range p[:count] {
+			if b != '\r' && b != '\n' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
+
+// envelopeSplit maps envelope recipients onto the message's To and Cc
+// headers. Recipients missing from both headers are returned as bcc.
+func envelopeSplit(msg *parsedMessage, envelope []string) (to, cc, bcc []string) {
+	inHeader := func(list []string, addr string) bool {
+		for _, a := range list {
+			if strings.EqualFold(a, addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, addr := range envelope {
+		switch {
+		case inHeader(msg.To, addr):
+			to = append(to, addr)
+		case inHeader(msg.Cc, addr):
+			cc = append(cc, addr)
+		default:
+			bcc = append(bcc, addr)
+		}
+	}
+	return to, cc, bcc
+}
+
+// RemoveHeader drops every occurrence of a header field, including its
+// continuation lines, leaving the rest of the message byte-for-byte intact.
+func RemoveHeader(raw []byte, key string) []byte {
+	var out bytes.Buffer
+	dropping := false
+	rest := raw
+	for len(rest) > 0 {
+		end := bytes.IndexByte(rest, '\n') + 1
+		if end == 0 {
+			end = len(rest)
+		}
+		line := rest[:end]
+		rest = rest[end:]
+
+		if len(bytes.TrimRight(line, "\r\n")) == 0 {
+			out.Write(line)
+			out.Write(rest)
+			break
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			name, _, _ := bytes.Cut(line, []byte(":"))
+			dropping = strings.EqualFold(strings.TrimSpace(string(name)), key)
+		}
+		if !dropping {
+			out.Write(line)
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,53 @@
+package provider
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRawMessage(t *testing.T) {
+	raw := "From: a@example.com\r\n" +
+		"To: Bob <bob@example.com>\r\n" +
+		"Cc: carol@example.com\r\n" +
+		"Subject: =?UTF-8?Q?caf=C3=A9?=\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"caf=C3=A9\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+		"\r\n" +
+		"aGVs\r\nbG8=\r\n" +
+		"--b1--\r\n"
+
+	msg, err := parseRawMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseRawMessage() error = %v", err)
+	}
+	if msg.Subject != "café" {
+		t.Fatalf("Subject = %q, want %q", msg.Subject, "café")
+	}
+	if msg.Text != "café" {
+		t.Fatalf("Text = %q, want %q", msg.Text, "café")
+	}
+	if !reflect.DeepEqual(msg.To, []string{"bob@example.com"}) || !reflect.DeepEqual(msg.Cc, []string{"carol@example.com"}) {
+		t.Fatalf("To = %q, Cc = %q", msg.To, msg.Cc)
+	}
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Filename != "notes.txt" || string(msg.Attachments[0].Content) != "hello" {
+		t.Fatalf("Attachments = %+v", msg.Attachments)
+	}
+}
+
+func TestEnvelopeSplit(t *testing.T) {
+	msg := &parsedMessage{To: []string{"bob@example.com"}, Cc: []string{"carol@example.com"}}
+	to, cc, bcc := envelopeSplit(msg, []string{"BOB@example.com", "carol@example.com", "dave@example.com"})
+	if !reflect.DeepEqual(to, []string{"BOB@example.com"}) || !reflect.DeepEqual(cc, []string{"carol@example.com"}) || !reflect.DeepEqual(bcc, []string{"dave@example.com"}) {
+		t.Fatalf("envelopeSplit() = %q, %q, %q", to, cc, bcc)
+	}
+}
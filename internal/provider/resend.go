@@ -0,0 +1,145 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+var resendAPIBase = "https://api.resend.com"
+
+// Resend sends through the Resend emails API.
+type Resend struct {
+	from   string
+	apiKey string
+	client *http.Client
+}
+
+func NewResend(from string, cfg *config.ResendConfig, t *Transport) (*Resend, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("resend api_key is required")
+	}
+	return &Resend{
+		from:   from,
+		apiKey: cfg.APIKey,
+		client: t.httpClient(),
+	}, nil
+}
+
+func (r *Resend) Name() string {
+	return "resend"
+}
+
+type resendAttachment struct {
+	Filename    string `json:"filename"`
+	Content     string `json:"content"` // base64 encoded
+	ContentType string `json:"content_type,omitempty"`
+}
+
+type resendTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type resendRequest struct {
+	From        string             `json:"from"`
+	To          []string           `json:"to,omitempty"`
+	Cc          []string           `json:"cc,omitempty"`
+	Bcc         []string           `json:"bcc,omitempty"`
+	Subject     string             `json:"subject"`
+	Text        string             `json:"text,omitempty"`
+	HTML        string             `json:"html,omitempty"`
+	Headers     map[string]string  `json:"headers,omitempty"`
+	Attachments []resendAttachment `json:"attachments,omitempty"`
+	Tags        []resendTag        `json:"tags,omitempty"`
+}
+
+type resendError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+func (r *Resend) Send(ctx context.Context, email *Email) error {
+	httpReq, err := r.request(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return doAPIRequest(r.client, httpReq, func(status int, respBody []byte) error {
+		var apiErr resendError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+			if apiErr.Name != "" {
+				return fmt.Errorf("resend error: %s: %s (status %d)", apiErr.Name, apiErr.Message, status)
+			}
+			return fmt.Errorf("resend error: %s (status %d)", apiErr.Message, status)
+		}
+		return fmt.Errorf("resend error: %s (status %d)", string(respBody), status)
+	})
+}
+
+// Preview builds the request Send would make, with the API key redacted.
+func (r *Resend) Preview(email *Email) (*Preview, error) {
+	httpReq, err := r.request(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+	return previewRequest(httpReq, "Authorization")
+}
+
+func (r *Resend) request(ctx context.Context, email *Email) (*http.Request, error) {
+	msg, err := newAPIMessage(r.from, email)
+	if err != nil {
+		return nil, err
+	}
+
+	req := resendRequest{
+		From:    msg.From,
+		To:      msg.To,
+		Cc:      msg.Cc,
+		Bcc:     msg.Bcc,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+	}
+	if len(msg.Headers) > 0 {
+		req.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			req.Headers[h.Name] = h.Value
+		}
+	}
+	for _, file := range msg.Attachments {
+		req.Attachments = append(req.Attachments, resendAttachment{
+			Filename:    file.name,
+			Content:     base64.StdEncoding.EncodeToString(file.content),
+			ContentType: file.contentType,
+		})
+	}
+	// Resend tags are name/value pairs: "name=value", or a bare name
+	// with the value "true".
+	for _, tag := range msg.Tags {
+		name, value, ok := strings.Cut(tag, "=")
+		if !ok {
+			value = "true"
+		}
+		req.Tags = append(req.Tags, resendTag{Name: name, Value: value})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, resendAPIBase+"/emails", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+r.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
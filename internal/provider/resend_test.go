@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func TestResendSend_MapsFields(t *testing.T) {
+	api := newFakeAPI(t, &resendAPIBase, http.StatusOK, `{"id":"49a3999c"}`)
+
+	r, err := NewResend("Alerts <alerts@example.com>", &config.ResendConfig{APIKey: "re_test"}, nil)
+	if err != nil {
+		t.Fatalf("NewResend() error = %v", err)
+	}
+	err = r.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Report",
+		Body:        "<p>Attached</p>",
+		HTML:        true,
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+		Tags:        []string{"category=reports", "weekly"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.URL.Path != "/emails" || req.Header.Get("Authorization") != "Bearer re_test" {
+		t.Fatalf("request = %s, Authorization %q", req.URL.Path, req.Header.Get("Authorization"))
+	}
+	var got resendRequest
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got.From != "Alerts <alerts@example.com>" || got.Bcc[0] != "audit@example.com" || got.HTML != "<p>Attached</p>" {
+		t.Fatalf("request = %+v", got)
+	}
+	wantTags := []resendTag{{Name: "category", Value: "reports"}, {Name: "weekly", Value: "true"}}
+	if len(got.Tags) != 2 || got.Tags[0] != wantTags[0] || got.Tags[1] != wantTags[1] {
+		t.Fatalf("Tags = %+v, want %+v", got.Tags, wantTags)
+	}
+	if got.Headers["X-Campaign"] != "q3" || len(got.Attachments) != 1 || got.Attachments[0].Content != "JVBERg==" {
+		t.Fatalf("Headers = %v, Attachments = %+v", got.Headers, got.Attachments)
+	}
+}
+
+func TestResendSend_HTTPError(t *testing.T) {
+	newFakeAPI(t, &resendAPIBase, http.StatusForbidden,
+		`{"statusCode":403,"message":"The example.com domain is not verified.","name":"validation_error"}`)
+
+	r, err := NewResend("alerts@example.com", &config.ResendConfig{APIKey: "re_test"}, nil)
+	if err != nil {
+		t.Fatalf("NewResend() error = %v", err)
+	}
+	err = r.Send(context.Background(), &Email{To: []string{"alice@example.com"}, Subject: "x", Body: "y"})
+	want := "resend error: validation_error: The example.com domain is not verified. (status 403)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+}
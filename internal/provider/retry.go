@@ -0,0 +1,136 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+const (
+	defaultRetryMinWait = time.Second
+	defaultRetryMaxWait = time.Minute
+)
+
+// Retrier sends again after transient and rate-limited failures, waiting
+// exponentially longer, with jitter, between attempts. Auth and permanent
+// failures are returned at once, and so are ambiguous ones (see
+// IsAmbiguous) unless Ambiguous is set.
+type Retrier struct {
+	// Retries is how many times to try again after the first attempt.
+	Retries int
+	// MinWait is the wait before the first retry, doubled for each one
+	// after, up to MaxWait. A provider that asks for a longer wait than
+	// MaxWait is not retried.
+	MinWait time.Duration
+	MaxWait time.Duration
+	// Ambiguous allows retrying a send that may have been delivered,
+	// at the risk of delivering it twice.
+	Ambiguous bool
+
+	// OnRetry, when set, is called before waiting to try again.
+	OnRetry func(attempt int, err error, wait time.Duration)
+
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+// RetrierFor returns a retrier for a provider's configured retries. With
+// no retries configured it sends once.
+func RetrierFor(cfg *config.ProviderConfig) (*Retrier, error) {
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+	maxWait, err := cfg.RetryWait()
+	if err != nil {
+		return nil, err
+	}
+	return &Retrier{Retries: cfg.Retries, MaxWait: maxWait, Ambiguous: cfg.RetryAmbiguous}, nil
+}
+
+// Do calls send until it succeeds, fails in a way not worth retrying, or
+// runs out of retries.
+func (r *Retrier) Do(ctx context.Context, send func(context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		err := trackAmbiguous(ctx, send)
+		if err == nil {
+			return nil
+		}
+		wait, ok := r.backoff(ctx, attempt, err)
+		if !ok {
+			if attempt > 1 {
+				return fmt.Errorf("%w (after %d attempts)", err, attempt)
+			}
+			return err
+		}
+		if r.OnRetry != nil {
+			r.OnRetry(attempt, err, wait)
+		}
+		if err := r.sleepFunc()(ctx, wait); err != nil {
+			return fmt.Errorf("%w (after %d attempts)", err, attempt)
+		}
+	}
+}
+
+// backoff returns how long to wait before trying again after attempt
+// failed with err, or false if it should not be tried again.
+func (r *Retrier) backoff(ctx context.Context, attempt int, err error) (time.Duration, bool) {
+	if attempt > r.Retries || ctx.Err() != nil {
+		return 0, false
+	}
+	switch Classify(err) {
+	case KindTransient, KindRateLimited:
+	default:
+		return 0, false
+	}
+	if IsAmbiguous(err) && !r.Ambiguous {
+		return 0, false
+	}
+
+	minWait, maxWait := r.MinWait, r.MaxWait
+	if minWait <= 0 {
+		minWait = defaultRetryMinWait
+	}
+	if maxWait <= 0 {
+		maxWait = defaultRetryMaxWait
+	}
+	// Equal jitter: half the exponential wait, plus up to as much again
+	// at random, so concurrent senders do not retry in step.
+	wait := min(minWait<<(attempt-1), maxWait)
+	if wait <= 0 {
+		wait = maxWait
+	}
+	wait = wait/2 + r.jitterFunc()(wait/2)
+
+	if after, ok := RetryAfter(err); ok {
+		if after > maxWait {
+			return 0, false
+		}
+		wait = max(wait, after)
+	}
+	if deadline, ok := ctx.Deadline(); ok && wait > time.Until(deadline) {
+		return 0, false
+	}
+	return wait, true
+}
+
+func (r *Retrier) sleepFunc() func(context.Context, time.Duration) error {
+	if r.sleep != nil {
+		return r.sleep
+	}
+	return sleepContext
+}
+
+func (r *Retrier) jitterFunc() func(time.Duration) time.Duration {
+	if r.jitter != nil {
+		return r.jitter
+	}
+	return func(d time.Duration) time.Duration {
+		if d <= 0 {
+			return 0
+		}
+		return rand.N(d + 1)
+	}
+}
@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newTestRetrier returns a retrier without jitter whose waits are
+// recorded in *waits instead of slept.
+func newTestRetrier(retries int, waits *[]time.Duration) *Retrier {
+	return &Retrier{
+		Retries: retries,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			return nil
+		},
+		jitter: func(d time.Duration) time.Duration { return d },
+	}
+}
+
+func TestRetrierDo(t *testing.T) {
+	unavailable := fmt.Errorf("rcpt to failed: %w", &textproto.Error{Code: 421, Msg: "try again later"})
+	ambiguous := fmt.Errorf("close failed: %w", &ambiguousError{fmt.Errorf("read tcp: %w", syscall.ECONNRESET)})
+
+	tests := []struct {
+		name      string
+		errs      []error
+		ambiguous bool
+		wantCalls int
+		wantWaits []time.Duration
+		wantErr   bool
+	}{
+		{"success", nil, false, 1, nil, false},
+		{"transient then success", []error{unavailable, unavailable}, false, 3, []time.Duration{time.Second, 2 * time.Second}, false},
+		{"out of retries", []error{unavailable, unavailable, unavailable, unavailable}, false, 4, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second}, true},
+		{"permanent", []error{&textproto.Error{Code: 550, Msg: "no such user"}}, false, 1, nil, true},
+		{"auth", []error{statusError(401, errors.New("bad key"))}, false, 1, nil, true},
+		{"ambiguous", []error{ambiguous}, false, 1, nil, true},
+		{"ambiguous opted in", []error{ambiguous}, true, 2, []time.Duration{time.Second}, false},
+		{"retry after", []error{&StatusError{Status: 429, RetryAfter: 5 * time.Second, Err: errors.New("slow down")}}, false, 2, []time.Duration{5 * time.Second}, false},
+		{"retry after too long", []error{&StatusError{Status: 503, RetryAfter: time.Hour, Err: errors.New("maintenance")}}, false, 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var waits []time.Duration
+			r := newTestRetrier(3, &waits)
+			r.Ambiguous = tt.ambiguous
+			calls := 0
+			err := r.Do(context.Background(), func(ctx context.Context) error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if fmt.Sprint(waits) != fmt.Sprint(tt.wantWaits) {
+				t.Errorf("waits = %v, want %v", waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestRetrierDo_Deadline(t *testing.T) {
+	var waits []time.Duration
+	r := newTestRetrier(3, &waits)
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	err := r.Do(ctx, func(ctx context.Context) error {
+		calls++
+		return statusError(503, errors.New("busy"))
+	})
+	if err == nil || calls != 1 || len(waits) != 0 {
+		t.Errorf("Do() = %v after %d calls and waits %v, want one call when the wait outlasts the deadline", err, calls, waits)
+	}
+}
+
+func TestTrackAmbiguous_HTTP(t *testing.T) {
+	hangUp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer hangUp.Close()
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	send := func(url string) error {
+		return trackAmbiguous(context.Background(), func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+			if err != nil {
+				return err
+			}
+			return doAPIRequest(http.DefaultClient, req, func(status int, body []byte) error {
+				return fmt.Errorf("api error (status %d)", status)
+			})
+		})
+	}
+
+	if err := send(hangUp.URL); !IsAmbiguous(err) {
+		t.Errorf("hang up after the request: error = %v, want ambiguous", err)
+	}
+	err := send(unavailable.URL)
+	if err == nil || IsAmbiguous(err) || !IsTransient(err) {
+		t.Errorf("503 response: error = %v, want transient and not ambiguous", err)
+	}
+	if wait, ok := RetryAfter(err); !ok || wait != 7*time.Second {
+		t.Errorf("RetryAfter() = %v, %v, want 7s", wait, ok)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"smtp 421", &textproto.Error{Code: 421, Msg: "try again later"}, KindTransient},
+		{"smtp 450 policy", &textproto.Error{Code: 450, Msg: "4.7.1 sending too fast"}, KindRateLimited},
+		{"smtp 535", &textproto.Error{Code: 535, Msg: "5.7.8 bad credentials"}, KindAuth},
+		{"smtp 550", &textproto.Error{Code: 550, Msg: "no such user"}, KindPermanent},
+		{"http 429", statusError(429, errors.New("rate limited")), KindRateLimited},
+		{"http 403", statusError(403, errors.New("forbidden")), KindAuth},
+		{"http 502", statusError(502, errors.New("bad gateway")), KindTransient},
+		{"quota", &LimitError{Provider: "relay", Reason: "has reached its daily quota", Reset: time.Now().Add(time.Hour)}, KindRateLimited},
+		{"canceled", context.Canceled, KindPermanent},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
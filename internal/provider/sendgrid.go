@@ -0,0 +1,181 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+var sendGridAPIBase = "https://api.sendgrid.com"
+
+// SendGrid sends through the SendGrid v3 Mail Send API.
+type SendGrid struct {
+	from   string
+	apiKey string
+	client *http.Client
+}
+
+func NewSendGrid(from string, cfg *config.SendGridConfig, t *Transport) (*SendGrid, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("sendgrid api_key is required")
+	}
+	return &SendGrid{
+		from:   from,
+		apiKey: cfg.APIKey,
+		client: t.httpClient(),
+	}, nil
+}
+
+func (s *SendGrid) Name() string {
+	return "sendgrid"
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridPersonalization struct {
+	To  []sendGridAddress `json:"to,omitempty"`
+	Cc  []sendGridAddress `json:"cc,omitempty"`
+	Bcc []sendGridAddress `json:"bcc,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridAttachment struct {
+	Content     string `json:"content"` // base64 encoded
+	Type        string `json:"type"`
+	Filename    string `json:"filename"`
+	Disposition string `json:"disposition"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+	Attachments      []sendGridAttachment      `json:"attachments,omitempty"`
+	Headers          map[string]string         `json:"headers,omitempty"`
+	Categories       []string                  `json:"categories,omitempty"`
+}
+
+type sendGridError struct {
+	Errors []struct {
+		Message string `json:"message"`
+		Field   string `json:"field"`
+	} `json:"errors"`
+}
+
+func (s *SendGrid) Send(ctx context.Context, email *Email) error {
+	httpReq, err := s.request(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return doAPIRequest(s.client, httpReq, func(status int, respBody []byte) error {
+		var apiErr sendGridError
+		if json.Unmarshal(respBody, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			messages := make([]string, 0, len(apiErr.Errors))
+			for _, e := range apiErr.Errors {
+				if e.Field != "" {
+					messages = append(messages, e.Field+": "+e.Message)
+				} else {
+					messages = append(messages, e.Message)
+				}
+			}
+			return fmt.Errorf("sendgrid error: %s (status %d)", strings.Join(messages, "; "), status)
+		}
+		return fmt.Errorf("sendgrid error: %s (status %d)", string(respBody), status)
+	})
+}
+
+// Preview builds the request Send would make, with the API key redacted.
+func (s *SendGrid) Preview(email *Email) (*Preview, error) {
+	httpReq, err := s.request(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+	return previewRequest(httpReq, "Authorization")
+}
+
+func (s *SendGrid) request(ctx context.Context, email *Email) (*http.Request, error) {
+	msg, err := newAPIMessage(s.from, email)
+	if err != nil {
+		return nil, err
+	}
+
+	req := sendGridRequest{
+		Personalizations: []sendGridPersonalization{{
+			To:  sendGridAddresses(msg.To),
+			Cc:  sendGridAddresses(msg.Cc),
+			Bcc: sendGridAddresses(msg.Bcc),
+		}},
+		From:       sendGridAddressOf(msg.From),
+		Subject:    msg.Subject,
+		Categories: msg.Tags,
+	}
+
+	// SendGrid requires text/plain before text/html, and at least one.
+	if msg.Text != "" || msg.HTML == "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/plain", Value: msg.Text})
+	}
+	if msg.HTML != "" {
+		req.Content = append(req.Content, sendGridContent{Type: "text/html", Value: msg.HTML})
+	}
+
+	for _, file := range msg.Attachments {
+		req.Attachments = append(req.Attachments, sendGridAttachment{
+			Content:     base64.StdEncoding.EncodeToString(file.content),
+			Type:        file.contentType,
+			Filename:    file.name,
+			Disposition: "attachment",
+		})
+	}
+	if len(msg.Headers) > 0 {
+		req.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			req.Headers[h.Name] = h.Value
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridAPIBase+"/v3/mail/send", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+s.apiKey)
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
+
+func sendGridAddresses(addrs []string) []sendGridAddress {
+	var out []sendGridAddress
+	for _, addr := range addrs {
+		out = append(out, sendGridAddressOf(addr))
+	}
+	return out
+}
+
+// sendGridAddressOf splits "Name <addr>" into SendGrid's email and name
+// fields.
+func sendGridAddressOf(addr string) sendGridAddress {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return sendGridAddress{Email: parsed.Address, Name: parsed.Name}
+	}
+	return sendGridAddress{Email: addr}
+}
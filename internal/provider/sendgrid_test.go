@@ -0,0 +1,74 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func TestSendGridSend_MapsFields(t *testing.T) {
+	api := newFakeAPI(t, &sendGridAPIBase, http.StatusAccepted, "")
+
+	s, err := NewSendGrid("Alerts <alerts@example.com>", &config.SendGridConfig{APIKey: "SG.test"}, nil)
+	if err != nil {
+		t.Fatalf("NewSendGrid() error = %v", err)
+	}
+	err = s.Send(context.Background(), &Email{
+		To:          []string{"Alice <alice@example.com>"},
+		Cc:          []string{"bob@example.com"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Report",
+		Body:        "<p>Attached</p>",
+		HTML:        true,
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("a,b")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+		Tags:        []string{"reports"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.URL.Path != "/v3/mail/send" || req.Header.Get("Authorization") != "Bearer SG.test" {
+		t.Fatalf("request = %s %s, Authorization %q", req.Method, req.URL.Path, req.Header.Get("Authorization"))
+	}
+	var got sendGridRequest
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	p := got.Personalizations[0]
+	if p.To[0] != (sendGridAddress{Email: "alice@example.com", Name: "Alice"}) ||
+		p.Cc[0].Email != "bob@example.com" || p.Bcc[0].Email != "audit@example.com" {
+		t.Fatalf("personalizations = %+v", got.Personalizations)
+	}
+	if got.From != (sendGridAddress{Email: "alerts@example.com", Name: "Alerts"}) {
+		t.Fatalf("from = %+v", got.From)
+	}
+	if len(got.Content) != 1 || got.Content[0].Type != "text/html" {
+		t.Fatalf("content = %+v, want only text/html", got.Content)
+	}
+	if len(got.Attachments) != 1 || got.Attachments[0].Type != "application/pdf" || got.Attachments[0].Content != "YSxi" {
+		t.Fatalf("attachments = %+v", got.Attachments)
+	}
+	if got.Headers["X-Campaign"] != "q3" || len(got.Categories) != 1 || got.Categories[0] != "reports" {
+		t.Fatalf("headers = %v, categories = %v", got.Headers, got.Categories)
+	}
+}
+
+func TestSendGridSend_HTTPError(t *testing.T) {
+	newFakeAPI(t, &sendGridAPIBase, http.StatusBadRequest,
+		`{"errors":[{"message":"The from address does not match a verified Sender Identity.","field":"from","help":null}]}`)
+
+	s, err := NewSendGrid("alerts@example.com", &config.SendGridConfig{APIKey: "SG.test"}, nil)
+	if err != nil {
+		t.Fatalf("NewSendGrid() error = %v", err)
+	}
+	err = s.Send(context.Background(), &Email{To: []string{"alice@example.com"}, Subject: "x", Body: "y"})
+	want := "sendgrid error: from: The from address does not match a verified Sender Identity. (status 400)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,225 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// sesEndpoint overrides the SES API base URL when set, for tests.
+var sesEndpoint string
+
+// SES sends through the Amazon SES v2 SendEmail API with raw content,
+// signed with SigV4.
+type SES struct {
+	from   string
+	config *config.SESConfig
+	creds  awsCredentials
+	client *http.Client
+}
+
+func NewSES(from string, cfg *config.SESConfig, t *Transport) (*SES, error) {
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("ses region is required")
+	}
+
+	creds := awsCredentials{
+		AccessKeyID:     cfg.AccessKeyID,
+		SecretAccessKey: cfg.SecretAccessKey,
+		SessionToken:    cfg.SessionToken,
+	}
+	if creds.AccessKeyID == "" {
+		var err error
+		creds, err = loadAWSProfile(cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return nil, fmt.Errorf("ses access key and secret key are required")
+	}
+
+	return &SES{
+		from:   from,
+		config: cfg,
+		creds:  creds,
+		client: t.httpClient(),
+	}, nil
+}
+
+func (s *SES) Name() string {
+	return "ses"
+}
+
+type sesDestination struct {
+	ToAddresses  []string `json:"ToAddresses,omitempty"`
+	CcAddresses  []string `json:"CcAddresses,omitempty"`
+	BccAddresses []string `json:"BccAddresses,omitempty"`
+}
+
+type sesRequest struct {
+	FromEmailAddress     string         `json:"FromEmailAddress,omitempty"`
+	Destination          sesDestination `json:"Destination"`
+	Content              sesContent     `json:"Content"`
+	ConfigurationSetName string         `json:"ConfigurationSetName,omitempty"`
+}
+
+type sesContent struct {
+	Raw struct {
+		Data []byte `json:"Data"`
+	} `json:"Raw"`
+}
+
+type sesError struct {
+	Message string `json:"message"`
+}
+
+func (s *SES) Send(ctx context.Context, email *Email) error {
+	from := sanitizeHeaderValue(s.from)
+	if email.From != "" {
+		from = sanitizeHeaderValue(email.From)
+	}
+
+	req := sesRequest{
+		FromEmailAddress:     from,
+		ConfigurationSetName: s.config.ConfigurationSet,
+	}
+
+	if email.Raw != nil {
+		parsed, err := parseRawMessage(email.Raw)
+		if err != nil {
+			return err
+		}
+		to, cc, bcc := envelopeSplit(parsed, email.envelopeRecipients())
+		req.Destination = sesDestination{ToAddresses: to, CcAddresses: cc, BccAddresses: bcc}
+		req.Content.Raw.Data = email.Raw
+	} else {
+		msg, err := buildMessage(from, email)
+		if err != nil {
+			return err
+		}
+		req.Destination = sesDestination{
+			ToAddresses:  sanitizeAddressList(email.To),
+			CcAddresses:  sanitizeAddressList(email.Cc),
+			BccAddresses: sanitizeAddressList(email.Bcc),
+		}
+		req.Content.Raw.Data = msg
+	}
+	if len(req.Destination.ToAddresses)+len(req.Destination.CcAddresses)+len(req.Destination.BccAddresses) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	endpoint := sesEndpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://email.%s.amazonaws.com", s.config.Region)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v2/email/outbound-emails", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	signV4(httpReq, body, s.creds, s.config.Region, "ses", time.Now())
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		errType, _, _ := strings.Cut(resp.Header.Get("X-Amzn-ErrorType"), ":")
+		var apiErr sesError
+		if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+			if errType != "" {
+				return responseError(resp, fmt.Errorf("ses error: %s: %s (status %d)", errType, apiErr.Message, resp.StatusCode))
+			}
+			return responseError(resp, fmt.Errorf("ses error: %s (status %d)", apiErr.Message, resp.StatusCode))
+		}
+		return responseError(resp, fmt.Errorf("ses error: %s (status %d)", string(respBody), resp.StatusCode))
+	}
+
+	return nil
+}
+
+// loadAWSProfile reads credentials for profile (default: $AWS_PROFILE,
+// else "default") from the shared credentials file.
+func loadAWSProfile(profile string) (awsCredentials, error) {
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, err
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read aws credentials: %w", err)
+	}
+	defer f.Close()
+
+	var (
+		creds   awsCredentials
+		found   bool
+		section string
+	)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read aws credentials: %w", err)
+	}
+	if !found {
+		return awsCredentials{}, fmt.Errorf("aws profile %q not found in %s", profile, path)
+	}
+	return creds, nil
+}
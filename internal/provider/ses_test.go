@@ -0,0 +1,173 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+var testAWSCredentials = awsCredentials{
+	AccessKeyID:     "AKIDEXAMPLE",
+	SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+}
+
+// TestSignV4_GetVanilla checks the signer against the "get-vanilla" case
+// of the AWS Signature Version 4 test suite.
+func TestSignV4_GetVanilla(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	signV4(req, nil, testAWSCredentials, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Fatalf("Authorization = %q, want %q", got, want)
+	}
+}
+
+func TestAWSCanonicalQuery_SortsAndEscapes(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/a%20b/?b=2&a=x y&a=1", nil)
+	if got, want := awsCanonicalQuery(req.URL.Query()), "a=1&a=x%20y&b=2"; got != want {
+		t.Fatalf("awsCanonicalQuery() = %q, want %q", got, want)
+	}
+	if got, want := awsCanonicalURI(req.URL), "/a%20b/"; got != want {
+		t.Fatalf("awsCanonicalURI() = %q, want %q", got, want)
+	}
+}
+
+// newFakeSES stands in for the SES v2 API. It verifies each request's
+// signature by re-signing a copy with the same time and credentials.
+func newFakeSES(t *testing.T, creds awsCredentials) *sesRequest {
+	t.Helper()
+
+	var got sesRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		when, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+		if err != nil {
+			http.Error(w, "missing X-Amz-Date", http.StatusForbidden)
+			return
+		}
+
+		check := r.Clone(context.Background())
+		check.URL.Scheme, check.URL.Host = "http", r.Host
+		check.Header.Del("Authorization")
+		signV4(check, body, creds, "eu-west-1", "ses", when)
+		if r.Header.Get("Authorization") != check.Header.Get("Authorization") {
+			w.Header().Set("X-Amzn-ErrorType", "SignatureDoesNotMatch:http://internal.amazon.com/coral/com.amazon.coral.service/")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"The request signature we calculated does not match"}`))
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/v2/email/outbound-emails" {
+			http.Error(w, "unexpected request", http.StatusNotFound)
+			return
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"MessageId":"0102018a"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	orig := sesEndpoint
+	sesEndpoint = server.URL
+	t.Cleanup(func() { sesEndpoint = orig })
+	return &got
+}
+
+func TestSESSend_SignedRawContent(t *testing.T) {
+	creds := testAWSCredentials
+	creds.SessionToken = "session-token"
+	got := newFakeSES(t, creds)
+
+	s, err := NewSES("alerts@example.com", &config.SESConfig{
+		Region:           "eu-west-1",
+		AccessKeyID:      creds.AccessKeyID,
+		SecretAccessKey:  creds.SecretAccessKey,
+		SessionToken:     creds.SessionToken,
+		ConfigurationSet: "alerts",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewSES() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"ops@example.com"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "Disk full",
+		Body:    "/var is at 100%",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if got.FromEmailAddress != "alerts@example.com" || got.ConfigurationSetName != "alerts" {
+		t.Fatalf("request = %+v", got)
+	}
+	if len(got.Destination.BccAddresses) != 1 || got.Destination.BccAddresses[0] != "audit@example.com" {
+		t.Fatalf("Destination = %+v, want audit@example.com in Bcc", got.Destination)
+	}
+	raw := string(got.Content.Raw.Data)
+	if !strings.Contains(raw, "Subject: Disk full") || strings.Contains(raw, "audit@example.com") {
+		t.Fatalf("raw content = %q", raw)
+	}
+}
+
+func TestSESSend_BadSignature(t *testing.T) {
+	newFakeSES(t, testAWSCredentials)
+
+	s, err := NewSES("alerts@example.com", &config.SESConfig{
+		Region:          "eu-west-1",
+		AccessKeyID:     testAWSCredentials.AccessKeyID,
+		SecretAccessKey: "wrong",
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewSES() error = %v", err)
+	}
+	err = s.Send(context.Background(), &Email{To: []string{"ops@example.com"}, Subject: "x", Body: "y"})
+	if err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("Send() error = %v, want SignatureDoesNotMatch", err)
+	}
+}
+
+func TestLoadAWSProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	data := "[default]\naws_access_key_id = AKIADEFAULT\naws_secret_access_key = default-secret\n\n" +
+		"[alerts]\naws_access_key_id=AKIAALERTS\naws_secret_access_key=alerts-secret\naws_session_token=token\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "")
+
+	creds, err := loadAWSProfile("alerts")
+	if err != nil {
+		t.Fatalf("loadAWSProfile() error = %v", err)
+	}
+	if creds != (awsCredentials{AccessKeyID: "AKIAALERTS", SecretAccessKey: "alerts-secret", SessionToken: "token"}) {
+		t.Fatalf("loadAWSProfile(alerts) = %+v", creds)
+	}
+
+	creds, err = loadAWSProfile("")
+	if err != nil || creds.AccessKeyID != "AKIADEFAULT" {
+		t.Fatalf("loadAWSProfile(\"\") = %+v, %v, want the default profile", creds, err)
+	}
+
+	if _, err := loadAWSProfile("missing"); err == nil {
+		t.Fatal("loadAWSProfile(missing) error = nil, want error")
+	}
+}
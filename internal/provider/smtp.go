@@ -1,37 +1,27 @@
 package provider
 
 import (
-	"crypto/rand"
+	"context"
 	"crypto/tls"
-	"encoding/base64"
+	"errors"
 	"fmt"
-	"mime"
 	"net/smtp"
-	"os"
-	"path/filepath"
-	"strings"
-	"time"
+	"net/textproto"
 
 	"github.com/tnm/email-cli/internal/config"
 )
 
-func generateBoundary() string {
-	b := make([]byte, 16)
-	if _, err := rand.Read(b); err != nil {
-		return fmt.Sprintf("boundary-%d", time.Now().UnixNano())
-	}
-	return fmt.Sprintf("boundary-%x", b)
-}
-
 type SMTP struct {
-	from   string
-	config *config.SMTPConfig
+	from      string
+	config    *config.SMTPConfig
+	transport *Transport
 }
 
-func NewSMTP(from string, cfg *config.SMTPConfig) (*SMTP, error) {
+func NewSMTP(from string, cfg *config.SMTPConfig, t *Transport) (*SMTP, error) {
 	return &SMTP{
-		from:   from,
-		config: cfg,
+		from:      from,
+		config:    cfg,
+		transport: t,
 	}, nil
 }
 
@@ -39,23 +29,10 @@ func (s *SMTP) Name() string {
 	return "smtp"
 }
 
-func (s *SMTP) Send(email *Email) error {
+func (s *SMTP) Send(ctx context.Context, email *Email) error {
 	addr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
-	mailFrom := sanitizeHeaderValue(s.from)
-
-	// Build message
-	msg, err := s.buildMessage(email)
-	if err != nil {
-		return err
-	}
-
-	// Collect all recipients
-	recipients := make([]string, 0, len(email.To)+len(email.Cc)+len(email.Bcc))
-	recipients = append(recipients, sanitizeAddressList(email.To)...)
-	recipients = append(recipients, sanitizeAddressList(email.Cc)...)
-	recipients = append(recipients, sanitizeAddressList(email.Bcc)...)
 
-	if len(recipients) == 0 {
+	if len(email.envelopeRecipients()) == 0 {
 		return fmt.Errorf("at least one recipient is required")
 	}
 
@@ -64,85 +41,108 @@ func (s *SMTP) Send(email *Email) error {
 		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
 	}
 
-	if s.config.UseTLS {
-		return s.sendTLS(addr, mailFrom, auth, recipients, msg)
+	client, err := s.dial(ctx, addr)
+	if err != nil {
+		return contextError(ctx, err)
 	}
+	defer client.Close()
 
-	return smtp.SendMail(addr, auth, mailFrom, recipients, msg)
+	return contextError(ctx, s.deliver(client, auth, email))
 }
 
-func (s *SMTP) sendTLS(addr, mailFrom string, auth smtp.Auth, recipients []string, msg []byte) error {
-	// Connect
-	conn, err := tls.Dial("tcp", addr, &tls.Config{
-		ServerName: s.config.Host,
-	})
-	if err != nil {
-		// Try STARTTLS instead
-		return s.sendSTARTTLS(addr, mailFrom, auth, recipients, msg)
-	}
-	defer conn.Close()
-
-	client, err := smtp.NewClient(conn, s.config.Host)
-	if err != nil {
-		return fmt.Errorf("failed to create smtp client: %w", err)
+// contextError reports cancellation or deadline expiry in place of the
+// network error it caused.
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		aborted := fmt.Errorf("send aborted: %w", ctx.Err())
+		if IsAmbiguous(err) {
+			return &ambiguousError{aborted}
+		}
+		return aborted
 	}
-	defer client.Close()
+	return err
+}
 
-	if auth != nil {
-		if err := client.Auth(auth); err != nil {
-			return fmt.Errorf("auth failed: %w", err)
+// dial connects to the server. With UseTLS it tries implicit TLS first and
+// falls back to mandatory STARTTLS; otherwise it upgrades opportunistically
+// like smtp.SendMail.
+func (s *SMTP) dial(ctx context.Context, addr string) (*smtpClient, error) {
+	if !s.config.UseTLS {
+		client, err := s.dialPlain(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+				client.Close()
+				return nil, fmt.Errorf("starttls failed: %w", err)
+			}
 		}
+		return client, nil
 	}
 
-	if err := client.Mail(mailFrom); err != nil {
-		return fmt.Errorf("mail from failed: %w", err)
+	conn, err := s.transport.dialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial failed: %w", err)
 	}
-
-	for _, rcpt := range recipients {
-		if err := client.Rcpt(rcpt); err != nil {
-			return fmt.Errorf("rcpt to failed: %w", err)
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: s.config.Host})
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, err
 		}
+		// Try STARTTLS instead
+		return s.dialSTARTTLS(ctx, addr)
 	}
 
-	w, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("data failed: %w", err)
-	}
+	return newSMTPClient(ctx, tlsConn, s.config.Host)
+}
 
-	if _, err := w.Write(msg); err != nil {
-		return fmt.Errorf("write failed: %w", err)
+func (s *SMTP) dialSTARTTLS(ctx context.Context, addr string) (*smtpClient, error) {
+	client, err := s.dialPlain(ctx, addr)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := w.Close(); err != nil {
-		return fmt.Errorf("close failed: %w", err)
+	if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("starttls failed: %w", err)
 	}
 
-	return client.Quit()
+	return client, nil
 }
 
-func (s *SMTP) sendSTARTTLS(addr, mailFrom string, auth smtp.Auth, recipients []string, msg []byte) error {
-	client, err := smtp.Dial(addr)
+func (s *SMTP) dialPlain(ctx context.Context, addr string) (*smtpClient, error) {
+	conn, err := s.transport.dialContext(ctx, "tcp", addr)
 	if err != nil {
-		return fmt.Errorf("dial failed: %w", err)
-	}
-	defer client.Close()
-
-	if err := client.StartTLS(&tls.Config{ServerName: s.config.Host}); err != nil {
-		return fmt.Errorf("starttls failed: %w", err)
+		return nil, fmt.Errorf("dial failed: %w", err)
 	}
+	return newSMTPClient(ctx, conn, s.config.Host)
+}
 
+// deliver runs a single mail transaction on an established connection.
+func (s *SMTP) deliver(client *smtpClient, auth smtp.Auth, email *Email) error {
 	if auth != nil {
 		if err := client.Auth(auth); err != nil {
 			return fmt.Errorf("auth failed: %w", err)
 		}
 	}
 
-	if err := client.Mail(mailFrom); err != nil {
+	extension := func(name string) bool {
+		ok, _ := client.Extension(name)
+		return ok
+	}
+	tx, err := prepareTransaction(s.from, email, extension, "smtp server "+s.config.Host)
+	if err != nil {
+		return err
+	}
+
+	if err := client.mail(tx.from, tx.mailParams...); err != nil {
 		return fmt.Errorf("mail from failed: %w", err)
 	}
 
-	for _, rcpt := range recipients {
-		if err := client.Rcpt(rcpt); err != nil {
+	for _, rcpt := range tx.recipients {
+		if err := client.rcpt(rcpt, tx.rcptParams(rcpt)...); err != nil {
 			return fmt.Errorf("rcpt to failed: %w", err)
 		}
 	}
@@ -152,99 +152,126 @@ func (s *SMTP) sendSTARTTLS(addr, mailFrom string, auth smtp.Auth, recipients []
 		return fmt.Errorf("data failed: %w", err)
 	}
 
-	if _, err := w.Write(msg); err != nil {
+	if _, err := w.Write(tx.msg); err != nil {
 		return fmt.Errorf("write failed: %w", err)
 	}
 
+	// Close sends the final "." and reads the server's reply. Without a
+	// reply, the server may have accepted the message before the
+	// connection was lost.
 	if err := w.Close(); err != nil {
+		var reply *textproto.Error
+		if !errors.As(err, &reply) {
+			err = &ambiguousError{err}
+		}
 		return fmt.Errorf("close failed: %w", err)
 	}
 
-	return client.Quit()
+	// The message is accepted; a failed QUIT does not change that, and
+	// reporting it would invite sending the message again.
+	_ = client.Quit()
+	return nil
 }
 
-func (s *SMTP) buildMessage(email *Email) ([]byte, error) {
-	var msg strings.Builder
-
-	// Headers
-	msg.WriteString(fmt.Sprintf("From: %s\r\n", sanitizeHeaderValue(s.from)))
-	to := sanitizeAddressList(email.To)
-	if len(to) > 0 {
-		msg.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(to, ", ")))
-	}
-	cc := sanitizeAddressList(email.Cc)
-	if len(cc) > 0 {
-		msg.WriteString(fmt.Sprintf("Cc: %s\r\n", strings.Join(cc, ", ")))
-	}
-	msg.WriteString(fmt.Sprintf("Subject: %s\r\n", sanitizeHeaderValue(email.Subject)))
-	msg.WriteString("MIME-Version: 1.0\r\n")
-
-	if len(email.Attachments) > 0 {
-		boundary := generateBoundary()
-		msg.WriteString(fmt.Sprintf("Content-Type: multipart/mixed; boundary=\"%s\"\r\n", boundary))
-		msg.WriteString("\r\n")
-
-		// Body part
-		msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		contentType := "text/plain"
-		if email.HTML {
-			contentType = "text/html"
+// transaction is a message ready for the MAIL, RCPT and DATA commands of
+// an SMTP or LMTP session.
+type transaction struct {
+	from       string
+	recipients []string
+	mailParams []string
+	dsn        *DSN
+	msg        []byte
+}
+
+// prepareTransaction builds the message once the server's extensions are
+// known, so that addresses can be adapted to SMTPUTF8 and 8BITMIME.
+// server names the server in errors.
+func prepareTransaction(defaultFrom string, email *Email, extension func(string) bool, server string) (*transaction, error) {
+	prepared := *email
+	prepared.From = sanitizeHeaderValue(defaultFrom)
+	if email.From != "" {
+		prepared.From = sanitizeHeaderValue(email.From)
+	}
+	prepared.To = sanitizeAddressList(email.To)
+	prepared.Cc = sanitizeAddressList(email.Cc)
+	prepared.Bcc = sanitizeAddressList(email.Bcc)
+
+	var mailParams []string
+	if needsSMTPUTF8(&prepared) {
+		if extension("SMTPUTF8") {
+			mailParams = append(mailParams, "SMTPUTF8")
+		} else if err := convertAddressesToASCII(&prepared); err != nil {
+			return nil, err
 		}
-		msg.WriteString(fmt.Sprintf("Content-Type: %s; charset=\"UTF-8\"\r\n", contentType))
-		msg.WriteString("\r\n")
-		msg.WriteString(email.Body)
-		msg.WriteString("\r\n")
-
-		// Attachments
-		for _, att := range email.Attachments {
-			content := att.Content
-			if content == nil && att.Path != "" {
-				data, err := os.ReadFile(att.Path)
-				if err != nil {
-					return nil, fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
-				}
-				content = data
-			}
+	}
 
-				filename := att.Filename
-				if filename == "" && att.Path != "" {
-					filename = filepath.Base(att.Path)
-				}
-				filename = sanitizeFilename(filename)
+	msg := email.Raw
+	if msg == nil {
+		var err error
+		msg, err = buildMessage(prepared.From, &prepared)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if extension("8BITMIME") && !isASCII(string(msg)) {
+		mailParams = append([]string{"BODY=8BITMIME"}, mailParams...)
+	}
 
-				mimeType := mime.TypeByExtension(filepath.Ext(filename))
-			if mimeType == "" {
-				mimeType = "application/octet-stream"
-			}
+	if email.DSN != nil {
+		if !extension("DSN") {
+			return nil, fmt.Errorf("%s does not support delivery status notifications (DSN)", server)
+		}
+		mailParams = append(mailParams, email.DSN.mailParams()...)
+	}
+
+	return &transaction{
+		from:       prepared.From,
+		recipients: prepared.envelopeRecipients(),
+		mailParams: mailParams,
+		dsn:        email.DSN,
+		msg:        msg,
+	}, nil
+}
+
+func (t *transaction) rcptParams(rcpt string) []string {
+	if t.dsn == nil {
+		return nil
+	}
+	return t.dsn.rcptParams(rcpt)
+}
 
-			msg.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-			msg.WriteString(fmt.Sprintf("Content-Type: %s\r\n", mimeType))
-			msg.WriteString("Content-Transfer-Encoding: base64\r\n")
-			msg.WriteString(fmt.Sprintf("Content-Disposition: attachment; filename=\"%s\"\r\n", filename))
-			msg.WriteString("\r\n")
-
-			encoded := base64.StdEncoding.EncodeToString(content)
-			// Wrap at 76 chars
-			for i := 0; i < len(encoded); i += 76 {
-				end := i + 76
-				if end > len(encoded) {
-					end = len(encoded)
-				}
-				msg.WriteString(encoded[i:end])
-				msg.WriteString("\r\n")
+// needsSMTPUTF8 reports whether any envelope address is non-ASCII.
+func needsSMTPUTF8(email *Email) bool {
+	for _, list := range [][]string{{email.From}, email.To, email.Cc, email.Bcc} {
+		for _, addr := range list {
+			if !isASCII(addr) {
+				return true
 			}
 		}
+	}
+	return false
+}
 
-		msg.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-	} else {
-		contentType := "text/plain"
-		if email.HTML {
-			contentType = "text/html"
-		}
-		msg.WriteString(fmt.Sprintf("Content-Type: %s; charset=\"UTF-8\"\r\n", contentType))
-		msg.WriteString("\r\n")
-		msg.WriteString(email.Body)
+// convertAddressesToASCII rewrites every address for a server without
+// SMTPUTF8, failing if any local part is non-ASCII.
+func convertAddressesToASCII(email *Email) error {
+	from, err := asciiAddress(email.From)
+	if err != nil {
+		return err
+	}
+	email.From = from
+	if email.To, err = asciiAddressList(email.To); err != nil {
+		return err
+	}
+	if email.Cc, err = asciiAddressList(email.Cc); err != nil {
+		return err
+	}
+	if email.Bcc, err = asciiAddressList(email.Bcc); err != nil {
+		return err
 	}
+	return nil
+}
 
-	return []byte(msg.String()), nil
+func (s *SMTP) buildMessage(email *Email) ([]byte, error) {
+	return buildMessage(s.from, email)
 }
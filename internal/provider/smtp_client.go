@@ -0,0 +1,66 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// smtpClient extends smtp.Client with MAIL and RCPT commands that accept
+// ESMTP parameters, which net/smtp does not expose.
+type smtpClient struct {
+	*smtp.Client
+	stop func() bool
+}
+
+// newSMTPClient reads the server greeting on conn. The connection is
+// closed as soon as ctx is done, which unblocks any pending command.
+func newSMTPClient(ctx context.Context, conn net.Conn, host string) (*smtpClient, error) {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		stop()
+		conn.Close()
+		return nil, fmt.Errorf("failed to create smtp client: %w", err)
+	}
+	return &smtpClient{Client: client, stop: stop}, nil
+}
+
+func (c *smtpClient) Close() error {
+	c.stop()
+	return c.Client.Close()
+}
+
+// mail issues MAIL FROM with the given ESMTP parameters. Callers must
+// have triggered EHLO first, e.g. via Extension.
+func (c *smtpClient) mail(from string, params ...string) error {
+	if strings.ContainsAny(from, "\r\n") {
+		return fmt.Errorf("smtp: a line must not contain CR or LF")
+	}
+	return c.cmd(250, "MAIL FROM:<"+from+">", params)
+}
+
+// rcpt issues RCPT TO with the given ESMTP parameters.
+func (c *smtpClient) rcpt(to string, params ...string) error {
+	if strings.ContainsAny(to, "\r\n") {
+		return fmt.Errorf("smtp: a line must not contain CR or LF")
+	}
+	return c.cmd(25, "RCPT TO:<"+to+">", params)
+}
+
+func (c *smtpClient) cmd(expectCode int, line string, params []string) error {
+	if len(params) > 0 {
+		line += " " + strings.Join(params, " ")
+	}
+	id, err := c.Text.Cmd("%s", line)
+	if err != nil {
+		return err
+	}
+	c.Text.StartResponse(id)
+	defer c.Text.EndResponse(id)
+	_, _, err = c.Text.ReadResponse(expectCode)
+	return err
+}
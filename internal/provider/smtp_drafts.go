@@ -0,0 +1,251 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// SMTP providers keep drafts in the account's IMAP mailbox when one is
+// configured. A draft is sent by fetching it, submitting it over SMTP and
+// then removing it from the drafts mailbox.
+
+func (s *SMTP) imap(ctx context.Context) (*imapClient, string, error) {
+	cfg := s.config.IMAP
+	if cfg == nil || cfg.Host == "" {
+		return nil, "", fmt.Errorf("drafts need an IMAP server; set one with: email-cli config set <name> imap-host <host>")
+	}
+	username, password := cfg.Username, cfg.Password
+	if username == "" {
+		username = s.config.Username
+	}
+	if password == "" {
+		password = s.config.Password
+	}
+
+	c, err := dialIMAP(ctx, s.transport, cfg, username, password)
+	if err != nil {
+		return nil, "", contextError(ctx, err)
+	}
+	mailbox, err := c.draftsMailbox(cfg.Drafts)
+	if err != nil {
+		c.Close()
+		return nil, "", contextError(ctx, err)
+	}
+	return c, mailbox, nil
+}
+
+func (s *SMTP) CreateDraft(ctx context.Context, email *Email) (string, error) {
+	msg, err := bccHeaderMessage(email, func(e *Email) ([]byte, error) {
+		return buildMessage(s.from, e)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c, mailbox, err := s.imap(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer c.logout()
+
+	id, err := c.append(mailbox, `\Draft \Seen`, msg)
+	if err != nil {
+		return "", contextError(ctx, fmt.Errorf("failed to save draft in %s: %w", mailbox, err))
+	}
+	return id, nil
+}
+
+func (s *SMTP) ListDrafts(ctx context.Context, limit int) ([]Draft, error) {
+	c, mailbox, err := s.imap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer c.logout()
+
+	if _, _, err := c.cmd("EXAMINE %s", imapQuote(mailbox)); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to open %s: %w", mailbox, err))
+	}
+	uids, err := c.searchUndeleted()
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+	// Newest first.
+	if limit > 0 && len(uids) > limit {
+		uids = uids[len(uids)-limit:]
+	}
+
+	untagged, _, err := c.cmd("UID FETCH %s (UID BODY.PEEK[HEADER.FIELDS (TO SUBJECT DATE)])", strings.Join(uids, ","))
+	if err != nil {
+		return nil, contextError(ctx, fmt.Errorf("failed to fetch drafts: %w", err))
+	}
+
+	byUID := make(map[string]Draft)
+	for _, line := range untagged {
+		uid := fetchUID(line.text)
+		if uid == "" || len(line.literals) == 0 {
+			continue
+		}
+		msg, err := mail.ReadMessage(bytes.NewReader(append(line.literals[0], '\r', '\n')))
+		if err != nil {
+			continue
+		}
+		dec := new(mime.WordDecoder)
+		subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+		if err != nil {
+			subject = msg.Header.Get("Subject")
+		}
+		byUID[uid] = Draft{
+			ID:      uid,
+			To:      msg.Header.Get("To"),
+			Subject: subject,
+			Date:    msg.Header.Get("Date"),
+		}
+	}
+
+	drafts := make([]Draft, 0, len(uids))
+	for i := len(uids) - 1; i >= 0; i-- {
+		if d, ok := byUID[uids[i]]; ok {
+			drafts = append(drafts, d)
+		}
+	}
+	return drafts, nil
+}
+
+func (s *SMTP) SendDraft(ctx context.Context, id string) error {
+	if err := validateUID(id); err != nil {
+		return err
+	}
+
+	c, mailbox, err := s.imap(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.logout()
+
+	if _, _, err := c.cmd("SELECT %s", imapQuote(mailbox)); err != nil {
+		return contextError(ctx, fmt.Errorf("failed to open %s: %w", mailbox, err))
+	}
+	untagged, _, err := c.cmd("UID FETCH %s (UID BODY.PEEK[])", id)
+	if err != nil {
+		return contextError(ctx, fmt.Errorf("failed to fetch draft %s: %w", id, err))
+	}
+	var raw []byte
+	for _, line := range untagged {
+		if fetchUID(line.text) == id && len(line.literals) > 0 {
+			raw = line.literals[0]
+		}
+	}
+	if raw == nil {
+		return fmt.Errorf("draft %s not found in %s", id, mailbox)
+	}
+
+	email, err := draftEmail(raw)
+	if err != nil {
+		return fmt.Errorf("draft %s: %w", id, err)
+	}
+	if err := s.Send(ctx, email); err != nil {
+		return err
+	}
+
+	if err := c.deleteUID(id); err != nil {
+		return contextError(ctx, fmt.Errorf("draft %s was sent but could not be removed from %s: %w", id, mailbox, err))
+	}
+	return nil
+}
+
+func (s *SMTP) DeleteDraft(ctx context.Context, id string) error {
+	if err := validateUID(id); err != nil {
+		return err
+	}
+
+	c, mailbox, err := s.imap(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.logout()
+
+	if _, _, err := c.cmd("SELECT %s", imapQuote(mailbox)); err != nil {
+		return contextError(ctx, fmt.Errorf("failed to open %s: %w", mailbox, err))
+	}
+	if err := c.deleteUID(id); err != nil {
+		return contextError(ctx, fmt.Errorf("failed to delete draft %s: %w", id, err))
+	}
+	return nil
+}
+
+// draftEmail turns a saved draft back into a message to submit: the
+// recipients come from its To, Cc and Bcc headers, and Bcc is removed
+// from the copy that is sent.
+func draftEmail(raw []byte) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	email := &Email{}
+	if email.To, err = headerAddresses(msg.Header, "To"); err != nil {
+		return nil, err
+	}
+	if email.Cc, err = headerAddresses(msg.Header, "Cc"); err != nil {
+		return nil, err
+	}
+	if email.Bcc, err = headerAddresses(msg.Header, "Bcc"); err != nil {
+		return nil, err
+	}
+	if from, err := headerAddresses(msg.Header, "From"); err == nil && len(from) > 0 {
+		email.From = from[0]
+	}
+	if len(email.envelopeRecipients()) == 0 {
+		return nil, fmt.Errorf("draft has no recipients")
+	}
+	email.Raw = RemoveHeader(raw, "Bcc")
+	return email, nil
+}
+
+func (c *imapClient) searchUndeleted() ([]string, error) {
+	untagged, _, err := c.cmd("UID SEARCH NOT DELETED")
+	if err != nil {
+		return nil, fmt.Errorf("imap search failed: %w", err)
+	}
+	var uids []string
+	for _, line := range untagged {
+		if rest, ok := strings.CutPrefix(line.text, "* SEARCH"); ok {
+			uids = append(uids, strings.Fields(rest)...)
+		}
+	}
+	return uids, nil
+}
+
+func (c *imapClient) deleteUID(uid string) error {
+	if _, _, err := c.cmd(`UID STORE %s +FLAGS.SILENT (\Deleted)`, uid); err != nil {
+		return err
+	}
+	return c.expunge(uid)
+}
+
+// fetchUID returns the UID item of a FETCH response, or "".
+func fetchUID(text string) string {
+	_, rest, ok := strings.Cut(strings.ToUpper(text), "UID ")
+	if !ok {
+		return ""
+	}
+	end := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' })
+	if end < 0 {
+		end = len(rest)
+	}
+	return rest[:end]
+}
+
+func validateUID(id string) error {
+	if n, err := strconv.ParseUint(id, 10, 32); err != nil || n == 0 {
+		return fmt.Errorf("invalid draft ID %q", id)
+	}
+	return nil
+}
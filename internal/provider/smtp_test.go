@@ -1,8 +1,16 @@
 package provider
 
 import (
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
 )
 
 func TestSMTPBuildMessage_SanitizesHeaderInjection(t *testing.T) {
@@ -32,6 +40,25 @@ func TestSMTPBuildMessage_SanitizesHeaderInjection(t *testing.T) {
 	}
 }
 
+func TestSMTPBuildMessage_ExtraHeaders(t *testing.T) {
+	s := &SMTP{from: "sender@example.com"}
+
+	msgBytes, err := s.buildMessage(&Email{
+		To:      []string{"to@example.com"},
+		Subject: "Newsletter",
+		Body:    "body",
+		Headers: []Header{{Name: "List-Unsubscribe", Value: "<mailto:unsub@example.com>\r\nX-Injected: true"}},
+	})
+	if err != nil {
+		t.Fatalf("buildMessage() error = %v", err)
+	}
+	msg := string(msgBytes)
+
+	if !strings.Contains(msg, "\r\nList-Unsubscribe: <mailto:unsub@example.com>X-Injected: true\r\n") {
+		t.Fatalf("message missing sanitized extra header:\n%s", msg)
+	}
+}
+
 func TestSMTPBuildMessage_SanitizesAttachmentFilename(t *testing.T) {
 	s := &SMTP{from: "sender@example.com"}
 
@@ -61,3 +88,344 @@ func TestSMTPBuildMessage_SanitizesAttachmentFilename(t *testing.T) {
 	}
 }
 
+// fakeSMTPServer is a minimal in-process SMTP server that records the
+// commands it receives. It never offers STARTTLS or AUTH.
+type fakeSMTPServer struct {
+	ln         net.Listener
+	extensions []string
+
+	mu       sync.Mutex
+	commands []string
+	messages []string
+	// dropData, when set, hangs up instead of replying to the message
+	// data.
+	dropData bool
+}
+
+func newFakeSMTPServer(t *testing.T, extensions ...string) *fakeSMTPServer {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	f := &fakeSMTPServer{ln: ln, extensions: extensions}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTPServer) config() *config.SMTPConfig {
+	addr := f.ln.Addr().(*net.TCPAddr)
+	return &config.SMTPConfig{Host: "127.0.0.1", Port: addr.Port}
+}
+
+func (f *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		f.mu.Unlock()
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			lines := append([]string{"fake"}, f.extensions...)
+			for i, l := range lines {
+				sep := "-"
+				if i == len(lines)-1 {
+					sep = " "
+				}
+				_ = tp.PrintfLine("250%s%s", sep, l)
+			}
+		case "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			_ = tp.PrintfLine("250 OK")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.messages = append(f.messages, string(data))
+			drop := f.dropData
+			f.mu.Unlock()
+			if drop {
+				return
+			}
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// commandsWithPrefix returns the recorded commands starting with prefix.
+func (f *fakeSMTPServer) commandsWithPrefix(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, c := range f.commands {
+		if strings.HasPrefix(c, prefix) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// receivedMessages returns the message data received so far.
+func (f *fakeSMTPServer) receivedMessages() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.messages...)
+}
+
+func TestSMTPSend_DSNParameters(t *testing.T) {
+	server := newFakeSMTPServer(t, "DSN")
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	dsn, err := NewDSN([]string{"success", "failure"}, "hdrs", "env+1")
+	if err != nil {
+		t.Fatalf("NewDSN() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Subject: "dsn",
+		Body:    "body",
+		DSN:     dsn,
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	mail := server.commandsWithPrefix("MAIL FROM:")
+	if len(mail) != 1 || mail[0] != "MAIL FROM:<sender@example.com> RET=HDRS ENVID=env+2B1" {
+		t.Fatalf("MAIL commands = %q", mail)
+	}
+	rcpt := server.commandsWithPrefix("RCPT TO:")
+	if len(rcpt) != 1 || rcpt[0] != "RCPT TO:<to@example.com> NOTIFY=SUCCESS,FAILURE ORCPT=rfc822;to@example.com" {
+		t.Fatalf("RCPT commands = %q", rcpt)
+	}
+}
+
+func TestSMTPSend_DSNUnsupported(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	dsn, err := NewDSN([]string{"failure"}, "", "")
+	if err != nil {
+		t.Fatalf("NewDSN() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Subject: "dsn",
+		Body:    "body",
+		DSN:     dsn,
+	})
+	if err == nil || !strings.Contains(err.Error(), "does not support delivery status notifications") {
+		t.Fatalf("Send() error = %v, want DSN unsupported error", err)
+	}
+	if got := server.commandsWithPrefix("MAIL"); len(got) != 0 {
+		t.Fatalf("MAIL sent despite missing DSN support: %q", got)
+	}
+}
+
+func TestSMTPSend_SMTPUTF8Supported(t *testing.T) {
+	server := newFakeSMTPServer(t, "8BITMIME", "SMTPUTF8")
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"用户@例子.广告"},
+		Subject: "hello",
+		Body:    "body",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	mail := server.commandsWithPrefix("MAIL FROM:")
+	if len(mail) != 1 || mail[0] != "MAIL FROM:<sender@example.com> BODY=8BITMIME SMTPUTF8" {
+		t.Fatalf("MAIL commands = %q", mail)
+	}
+	rcpt := server.commandsWithPrefix("RCPT TO:")
+	if len(rcpt) != 1 || rcpt[0] != "RCPT TO:<用户@例子.广告>" {
+		t.Fatalf("RCPT commands = %q", rcpt)
+	}
+}
+
+func TestSMTPSend_PunycodeWithoutSMTPUTF8(t *testing.T) {
+	server := newFakeSMTPServer(t, "8BITMIME")
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"user@bücher.example"},
+		Subject: "hello",
+		Body:    "plain ascii",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	mail := server.commandsWithPrefix("MAIL FROM:")
+	if len(mail) != 1 || mail[0] != "MAIL FROM:<sender@example.com>" {
+		t.Fatalf("MAIL commands = %q", mail)
+	}
+	rcpt := server.commandsWithPrefix("RCPT TO:")
+	if len(rcpt) != 1 || rcpt[0] != "RCPT TO:<user@xn--bcher-kva.example>" {
+		t.Fatalf("RCPT commands = %q", rcpt)
+	}
+	msgs := server.receivedMessages()
+	if len(msgs) != 1 || !strings.Contains(msgs[0], "To: user@xn--bcher-kva.example\n") {
+		t.Fatalf("message headers not converted: %q", msgs)
+	}
+}
+
+func TestSMTPSend_NonASCIILocalPartWithoutSMTPUTF8(t *testing.T) {
+	server := newFakeSMTPServer(t, "8BITMIME")
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"用户@例子.广告"},
+		Subject: "hello",
+		Body:    "body",
+	})
+	if err == nil || !strings.Contains(err.Error(), "non-ASCII local part") {
+		t.Fatalf("Send() error = %v, want non-ASCII local part error", err)
+	}
+	if got := server.commandsWithPrefix("MAIL"); len(got) != 0 {
+		t.Fatalf("MAIL sent for undeliverable address: %q", got)
+	}
+}
+
+func TestSMTPSend_ContextCancelsStalledServer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	defer ln.Close()
+
+	// Accept connections but never send a greeting.
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	s, err := NewSMTP("sender@example.com", &config.SMTPConfig{
+		Host: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = s.Send(ctx, &Email{
+		To:      []string{"to@example.com"},
+		Subject: "stalled",
+		Body:    "body",
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Send() error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Send() took %v after the context expired", elapsed)
+	}
+}
+
+func TestSMTPSend_AmbiguousAfterData(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	server.mu.Lock()
+	server.dropData = true
+	server.mu.Unlock()
+	s, err := NewSMTP("sender@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	err = s.Send(context.Background(), &Email{To: []string{"to@example.com"}, Subject: "hi", Body: "body"})
+	if err == nil {
+		t.Fatal("Send() should fail when the server hangs up after the data")
+	}
+	if !IsAmbiguous(err) || IsTransient(err) {
+		t.Errorf("Send() error = %v, want ambiguous and not transient", err)
+	}
+	if got := len(server.receivedMessages()); got != 1 {
+		t.Errorf("server received %d messages, want 1", got)
+	}
+}
+
+func TestSMTPSend_RawMessage(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	s, err := NewSMTP("default@example.com", server.config(), nil)
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+
+	raw := "From: Ops <ops@example.com>\r\nTo: to@example.com\r\nSubject: raw\r\n\r\nbody line\r\n"
+	err = s.Send(context.Background(), &Email{
+		From: "bounces@example.com",
+		To:   []string{"to@example.com", "hidden@example.com"},
+		Raw:  []byte(raw),
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	mail := server.commandsWithPrefix("MAIL FROM:")
+	if len(mail) != 1 || mail[0] != "MAIL FROM:<bounces@example.com>" {
+		t.Fatalf("MAIL commands = %q", mail)
+	}
+	rcpt := server.commandsWithPrefix("RCPT TO:")
+	if len(rcpt) != 2 || rcpt[1] != "RCPT TO:<hidden@example.com>" {
+		t.Fatalf("RCPT commands = %q", rcpt)
+	}
+	messages := server.receivedMessages()
+	want := strings.ReplaceAll(raw, "\r\n", "\n")
+	if len(messages) != 1 || messages[0] != want {
+		t.Fatalf("message = %q, want %q", messages, want)
+	}
+}
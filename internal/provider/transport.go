@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const defaultConnectTimeout = 30 * time.Second
+
+// Transport holds the network settings shared by every provider type.
+// A nil *Transport uses the defaults.
+type Transport struct {
+	ConnectTimeout time.Duration
+	Proxy          *url.URL
+}
+
+// ParseProxyURL validates a proxy setting. Supported schemes are socks5,
+// socks5h, http and https (the latter two via HTTP CONNECT).
+func ParseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http", "https":
+	default:
+		return nil, fmt.Errorf("invalid proxy %q: scheme must be socks5, socks5h, http, or https", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", raw)
+	}
+	return u, nil
+}
+
+func (t *Transport) connectTimeout() time.Duration {
+	if t == nil || t.ConnectTimeout <= 0 {
+		return defaultConnectTimeout
+	}
+	return t.ConnectTimeout
+}
+
+// dialContext opens a TCP connection, through the proxy if one is
+// configured, bounded by both ctx and the configured connect timeout.
+func (t *Transport) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: t.connectTimeout()}
+	if t == nil || t.Proxy == nil {
+		return d.DialContext(ctx, network, addr)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.connectTimeout())
+	defer cancel()
+
+	switch t.Proxy.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if t.Proxy.User != nil {
+			password, _ := t.Proxy.User.Password()
+			auth = &proxy.Auth{User: t.Proxy.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyAddr(t.Proxy), auth, d)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 proxy: %w", err)
+		}
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 proxy: %w", err)
+		}
+		return conn, nil
+	default:
+		return t.dialConnect(ctx, d, addr)
+	}
+}
+
+// dialConnect tunnels a connection to addr through an HTTP proxy using
+// the CONNECT method.
+func (t *Transport) dialConnect(ctx context.Context, d *net.Dialer, addr string) (net.Conn, error) {
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr(t.Proxy))
+	if err != nil {
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+	if t.Proxy.Scheme == "https" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: t.Proxy.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("http proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if t.Proxy.User != nil {
+		password, _ := t.Proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(t.Proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("http proxy: CONNECT %s: %s", addr, resp.Status)
+	}
+	if ctx.Err() != nil {
+		conn.Close()
+		return nil, ctx.Err()
+	}
+
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// bufferedConn returns bytes the CONNECT response reader already
+// buffered before reading from the connection again.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		return net.JoinHostPort(u.Hostname(), "1080")
+	case "https":
+		return net.JoinHostPort(u.Hostname(), "443")
+	default:
+		return net.JoinHostPort(u.Hostname(), "80")
+	}
+}
+
+// httpClient returns the client HTTP-based providers should use. Without
+// custom settings it is the shared package client.
+func (t *Transport) httpClient() *http.Client {
+	if t == nil || (t.ConnectTimeout <= 0 && t.Proxy == nil) {
+		return httpClient
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: t.connectTimeout()}).DialContext
+	if t.Proxy != nil {
+		transport.Proxy = http.ProxyURL(t.Proxy)
+	}
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,246 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// startTestProxy runs handle for every connection accepted on a local
+// listener and returns the listener address.
+func startTestProxy(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// pipe copies data in both directions until either side closes.
+func pipe(a, b net.Conn) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() { defer wg.Done(); _, _ = io.Copy(a, b); a.Close() }()
+	go func() { defer wg.Done(); _, _ = io.Copy(b, a); b.Close() }()
+	wg.Wait()
+}
+
+// socks5Proxy is a minimal no-auth SOCKS5 server that records the
+// CONNECT targets it was asked for.
+type socks5Proxy struct {
+	mu      sync.Mutex
+	targets []string
+}
+
+func (p *socks5Proxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0})
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		n, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, portBytes); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+
+	p.mu.Lock()
+	p.targets = append(p.targets, target)
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", target)
+	if err != nil {
+		_, _ = conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	_, _ = conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	pipe(conn, upstream)
+}
+
+// connectProxy is a minimal HTTP CONNECT proxy that records targets and
+// the Proxy-Authorization header it received.
+type connectProxy struct {
+	mu      sync.Mutex
+	targets []string
+	auth    []string
+}
+
+func (p *connectProxy) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	req, err := http.ReadRequest(r)
+	if err != nil || req.Method != http.MethodConnect {
+		return
+	}
+
+	p.mu.Lock()
+	p.targets = append(p.targets, req.Host)
+	p.auth = append(p.auth, req.Header.Get("Proxy-Authorization"))
+	p.mu.Unlock()
+
+	upstream, err := net.Dial("tcp", req.Host)
+	if err != nil {
+		_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+		return
+	}
+	_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+	pipe(conn, upstream)
+}
+
+func sendThroughProxy(t *testing.T, server *fakeSMTPServer, proxyURL string) {
+	t.Helper()
+
+	u, err := ParseProxyURL(proxyURL)
+	if err != nil {
+		t.Fatalf("ParseProxyURL() error = %v", err)
+	}
+	s, err := NewSMTP("sender@example.com", server.config(), &Transport{Proxy: u})
+	if err != nil {
+		t.Fatalf("NewSMTP() error = %v", err)
+	}
+	err = s.Send(context.Background(), &Email{
+		To:      []string{"to@example.com"},
+		Subject: "via proxy",
+		Body:    "body",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := len(server.receivedMessages()); got != 1 {
+		t.Fatalf("server received %d messages, want 1", got)
+	}
+}
+
+func TestSMTPSend_SOCKS5Proxy(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	p := &socks5Proxy{}
+	addr := startTestProxy(t, p.handle)
+
+	sendThroughProxy(t, server, "socks5://"+addr)
+
+	want := server.ln.Addr().String()
+	if len(p.targets) != 1 || p.targets[0] != want {
+		t.Fatalf("proxy targets = %q, want [%q]", p.targets, want)
+	}
+}
+
+func TestSMTPSend_HTTPConnectProxy(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	p := &connectProxy{}
+	addr := startTestProxy(t, p.handle)
+
+	sendThroughProxy(t, server, "http://user:secret@"+addr)
+
+	want := server.ln.Addr().String()
+	if len(p.targets) != 1 || p.targets[0] != want {
+		t.Fatalf("proxy targets = %q, want [%q]", p.targets, want)
+	}
+	if p.auth[0] != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Fatalf("Proxy-Authorization = %q, want Basic credentials", p.auth[0])
+	}
+}
+
+func TestAgentMailSend_UsesProxy(t *testing.T) {
+	originalBase := agentMailAPIBase
+	defer func() { agentMailAPIBase = originalBase }()
+	agentMailAPIBase = "http://agentmail.invalid/v0"
+
+	var gotURL string
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURL = r.URL.String()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxyServer.Close()
+
+	proxyURL, _ := url.Parse(proxyServer.URL)
+	a, err := NewAgentMail(&config.AgentMailConfig{
+		APIKey:  "am_test",
+		InboxID: "test@agentmail.to",
+	}, &Transport{Proxy: proxyURL})
+	if err != nil {
+		t.Fatalf("NewAgentMail() error = %v", err)
+	}
+
+	err = a.Send(context.Background(), &Email{
+		To:      []string{"user@example.com"},
+		Subject: "test",
+		Body:    "body",
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	want := "http://agentmail.invalid/v0/inboxes/test@agentmail.to/messages/send"
+	if gotURL != want {
+		t.Fatalf("proxied URL = %q, want %q", gotURL, want)
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	for _, valid := range []string{"socks5://127.0.0.1:1080", "socks5h://proxy", "http://proxy:3128", "https://user:pw@proxy"} {
+		if _, err := ParseProxyURL(valid); err != nil {
+			t.Errorf("ParseProxyURL(%q) error = %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"ftp://proxy", "proxy:3128", "socks5://"} {
+		if _, err := ParseProxyURL(invalid); err == nil {
+			t.Errorf("ParseProxyURL(%q) expected error", invalid)
+		}
+	}
+}
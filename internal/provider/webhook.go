@@ -0,0 +1,235 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+const (
+	defaultWebhookSignatureHeader = "X-Signature-256"
+	// defaultWebhookTemplateType is sent for a template with no configured
+	// content type, since the template may render anything.
+	defaultWebhookTemplateType = "text/plain; charset=utf-8"
+)
+
+// Webhook POSTs each email to a URL as JSON, multipart form data, or a
+// body rendered from a template, for in-house gateways and chat bridges.
+type Webhook struct {
+	from     string
+	config   *config.WebhookConfig
+	template *template.Template
+	client   *http.Client
+}
+
+func NewWebhook(from string, cfg *config.WebhookConfig, t *Transport) (*Webhook, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook url must be an http or https URL")
+	}
+	switch cfg.Format {
+	case "", "json", "multipart":
+	default:
+		return nil, fmt.Errorf("invalid webhook format %q: must be json or multipart", cfg.Format)
+	}
+	if cfg.ContentType != "" {
+		if cfg.Format == "multipart" && cfg.Template == "" {
+			return nil, fmt.Errorf("webhook content_type cannot be set with the multipart format")
+		}
+		if _, _, err := mime.ParseMediaType(cfg.ContentType); err != nil {
+			return nil, fmt.Errorf("invalid webhook content_type %q: %w", cfg.ContentType, err)
+		}
+	}
+
+	w := &Webhook{
+		from:   from,
+		config: cfg,
+		client: t.httpClient(),
+	}
+	if cfg.Template != "" {
+		w.template, err = template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+	}
+	return w, nil
+}
+
+func (w *Webhook) Name() string {
+	return "webhook"
+}
+
+var webhookTemplateFuncs = template.FuncMap{
+	// json renders a value as JSON, so templates can embed strings safely.
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join": strings.Join,
+}
+
+func (w *Webhook) Send(ctx context.Context, email *Email) error {
+	req, err := w.request(ctx, email)
+	if err != nil {
+		return err
+	}
+
+	return doAPIRequest(w.client, req, func(status int, respBody []byte) error {
+		detail := strings.TrimSpace(string(respBody))
+		if len(detail) > 512 {
+			detail = detail[:512] + "..."
+		}
+		if detail == "" {
+			detail = http.StatusText(status)
+		}
+		return fmt.Errorf("webhook error: %s (status %d)", detail, status)
+	})
+}
+
+// Preview builds the request Send would make. Configured header values
+// are redacted, since they usually carry credentials.
+func (w *Webhook) Preview(email *Email) (*Preview, error) {
+	req, err := w.request(context.Background(), email)
+	if err != nil {
+		return nil, err
+	}
+	secret := make([]string, 0, len(w.config.Headers))
+	for name := range w.config.Headers {
+		secret = append(secret, name)
+	}
+	return previewRequest(req, secret...)
+}
+
+func (w *Webhook) request(ctx context.Context, email *Email) (*http.Request, error) {
+	msg, err := newAPIMessage(w.from, email)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		body        []byte
+		contentType string
+	)
+	switch {
+	case w.template != nil:
+		var buf bytes.Buffer
+		if err := w.template.Execute(&buf, newJSONEmail(msg, email.Raw)); err != nil {
+			return nil, fmt.Errorf("failed to render webhook template: %w", err)
+		}
+		body, contentType = buf.Bytes(), w.contentType(defaultWebhookTemplateType)
+	case w.config.Format == "multipart":
+		body, contentType, err = webhookMultipart(msg, email.Raw)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		body, err = json.Marshal(newJSONEmail(msg, email.Raw))
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		contentType = w.contentType("application/json")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "email-cli")
+	for name, value := range w.config.Headers {
+		req.Header.Set(name, value)
+	}
+	if w.config.Secret != "" {
+		header := w.config.SignatureHeader
+		if header == "" {
+			header = defaultWebhookSignatureHeader
+		}
+		req.Header.Set(header, webhookSignature(w.config.Secret, body))
+	}
+	return req, nil
+}
+
+// contentType returns the configured content type, or def.
+func (w *Webhook) contentType(def string) string {
+	if w.config.ContentType != "" {
+		return w.config.ContentType
+	}
+	return def
+}
+
+// webhookSignature is the hex HMAC-SHA256 of body, in the "sha256=..."
+// form GitHub and others use.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookMultipart renders msg as multipart form data. List fields repeat,
+// extra headers are "header" fields of the form "Name: value", and
+// attachments (and a raw message) are file parts.
+func webhookMultipart(msg *apiMessage, raw []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+
+	var err error
+	write := func(key string, values ...string) {
+		for _, value := range values {
+			if err == nil && value != "" {
+				err = form.WriteField(key, value)
+			}
+		}
+	}
+	write("from", msg.From)
+	write("to", msg.To...)
+	write("cc", msg.Cc...)
+	write("bcc", msg.Bcc...)
+	write("subject", msg.Subject)
+	write("text", msg.Text)
+	write("html", msg.HTML)
+	write("tag", msg.Tags...)
+	for _, h := range msg.Headers {
+		write("header", h.Name+": "+h.Value)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to build request: %w", err)
+	}
+
+	files := msg.Attachments
+	if raw != nil {
+		files = append(files, attachmentFile{name: "message.eml", contentType: "message/rfc822", content: raw})
+	}
+	for i, file := range files {
+		field := "attachment"
+		if raw != nil && i == len(files)-1 {
+			field = "message"
+		}
+		part, err := form.CreatePart(map[string][]string{
+			"Content-Disposition": {fmt.Sprintf(`form-data; name="%s"; filename="%s"`, field, sanitizeFilename(file.name))},
+			"Content-Type":        {file.contentType},
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to build request: %w", err)
+		}
+		if _, err := part.Write(file.content); err != nil {
+			return nil, "", fmt.Errorf("failed to build request: %w", err)
+		}
+	}
+
+	if err := form.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to build request: %w", err)
+	}
+	return buf.Bytes(), form.FormDataContentType(), nil
+}
@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+func newTestWebhook(t *testing.T, cfg config.WebhookConfig) (*Webhook, *fakeAPI) {
+	t.Helper()
+	api := newFakeAPI(t, &cfg.URL, http.StatusAccepted, "")
+	w, err := NewWebhook("Alerts <alerts@example.com>", &cfg, nil)
+	if err != nil {
+		t.Fatalf("NewWebhook() error = %v", err)
+	}
+	return w, api
+}
+
+func TestWebhookSend_JSONWithSignature(t *testing.T) {
+	w, api := newTestWebhook(t, config.WebhookConfig{
+		Headers: map[string]string{"Authorization": "Bearer gw-token"},
+		Secret:  "s3cret",
+	})
+	err := w.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com"},
+		Bcc:         []string{"audit@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+		Tags:        []string{"weekly"},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Bearer gw-token" {
+		t.Fatalf("headers = %v", req.Header)
+	}
+	if got, want := req.Header.Get("X-Signature-256"), webhookSignature("s3cret", body); got != want {
+		t.Fatalf("signature = %q, want %q", got, want)
+	}
+	var got jsonEmail
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if got.From != "Alerts <alerts@example.com>" || got.To[0] != "alice@example.com" || got.Bcc[0] != "audit@example.com" || got.Text != "Attached" {
+		t.Fatalf("payload = %+v", got)
+	}
+	if got.Headers["X-Campaign"] != "q3" || got.Tags[0] != "weekly" {
+		t.Fatalf("Headers = %v, Tags = %v", got.Headers, got.Tags)
+	}
+	want := jsonAttachment{Filename: "report.pdf", ContentType: "application/pdf", Content: "JVBERg=="}
+	if len(got.Attachments) != 1 || got.Attachments[0] != want {
+		t.Fatalf("Attachments = %+v, want %+v", got.Attachments, want)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	// HMAC-SHA256("key", "The quick brown fox jumps over the lazy dog")
+	got := webhookSignature("key", []byte("The quick brown fox jumps over the lazy dog"))
+	want := "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+	if got != want {
+		t.Fatalf("webhookSignature() = %s, want %s", got, want)
+	}
+}
+
+func TestWebhookSend_Multipart(t *testing.T) {
+	w, api := newTestWebhook(t, config.WebhookConfig{Format: "multipart"})
+	err := w.Send(context.Background(), &Email{
+		To:          []string{"alice@example.com", "bob@example.com"},
+		Subject:     "Report",
+		Body:        "Attached",
+		Attachments: []Attachment{{Filename: "report.pdf", Content: []byte("%PDF")}},
+		Headers:     []Header{{Name: "X-Campaign", Value: "q3"}},
+	})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("ParseMediaType() error = %v", err)
+	}
+	form, err := multipart.NewReader(strings.NewReader(string(body)), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm() error = %v", err)
+	}
+	if got := strings.Join(form.Value["to"], ","); got != "alice@example.com,bob@example.com" {
+		t.Fatalf("to = %s", got)
+	}
+	if form.Value["subject"][0] != "Report" || form.Value["header"][0] != "X-Campaign: q3" {
+		t.Fatalf("form values = %v", form.Value)
+	}
+	if files := form.File["attachment"]; len(files) != 1 || files[0].Filename != "report.pdf" {
+		t.Fatalf("attachments = %v", form.File)
+	}
+}
+
+func TestWebhookSend_Template(t *testing.T) {
+	w, api := newTestWebhook(t, config.WebhookConfig{
+		Template: `{"text": {{json (printf "%s: %s" (join .To ", ") .Subject)}}}`,
+	})
+	err := w.Send(context.Background(), &Email{To: []string{"ops@example.com"}, Subject: `Disk "full"`, Body: "y"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	req, body := api.last()
+	if want := `{"text": "ops@example.com: Disk \"full\""}`; string(body) != want {
+		t.Fatalf("body = %s, want %s", body, want)
+	}
+	if got := req.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Fatalf("Content-Type = %q, want text/plain for a template", got)
+	}
+}
+
+func TestWebhookSend_ContentType(t *testing.T) {
+	for _, cfg := range []config.WebhookConfig{
+		{ContentType: "application/vnd.gateway+json"},
+		{ContentType: "application/vnd.gateway+json", Template: `{{json .Subject}}`},
+	} {
+		w, api := newTestWebhook(t, cfg)
+		if err := w.Send(context.Background(), &Email{To: []string{"ops@example.com"}, Subject: "x", Body: "y"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		req, _ := api.last()
+		if got := req.Header.Get("Content-Type"); got != cfg.ContentType {
+			t.Fatalf("Content-Type = %q, want %q", got, cfg.ContentType)
+		}
+	}
+}
+
+func TestWebhookSend_HTTPError(t *testing.T) {
+	w, api := newTestWebhook(t, config.WebhookConfig{})
+	api.status, api.reply = http.StatusBadGateway, "upstream relay unavailable\n"
+
+	err := w.Send(context.Background(), &Email{To: []string{"alice@example.com"}, Subject: "x", Body: "y"})
+	want := "webhook error: upstream relay unavailable (status 502)"
+	if err == nil || err.Error() != want {
+		t.Fatalf("Send() error = %v, want %q", err, want)
+	}
+}
+
+func TestNewWebhook_Validates(t *testing.T) {
+	for _, cfg := range []config.WebhookConfig{
+		{URL: "ftp://example.com/hook"},
+		{URL: "https://example.com/hook", Format: "xml"},
+		{URL: "https://example.com/hook", Template: "{{.Subject"},
+		{URL: "https://example.com/hook", ContentType: "not a type"},
+		{URL: "https://example.com/hook", Format: "multipart", ContentType: "text/plain"},
+	} {
+		if _, err := NewWebhook("a@example.com", &cfg, nil); err == nil {
+			t.Fatalf("NewWebhook(%+v) error = nil, want error", cfg)
+		}
+	}
+}
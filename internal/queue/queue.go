@@ -0,0 +1,377 @@
+// Package queue is email-cli's outbox: emails saved by send --queue, to be
+// delivered later by queue run. Each email is a JSON file, synced to disk
+// and renamed into place, so a queued email survives a crash or power
+// loss. Changes to an entry's status hold a lock file, so concurrent
+// queue runs never deliver the same email twice.
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tnm/email-cli/internal/config"
+	"github.com/tnm/email-cli/internal/provider"
+	"github.com/tnm/email-cli/internal/state"
+)
+
+const (
+	// minBackoff is the wait before a failed email is tried again,
+	// doubled after each attempt up to maxBackoff.
+	minBackoff = time.Minute
+	maxBackoff = time.Hour
+)
+
+// Status is where an entry is in its delivery.
+type Status string
+
+const (
+	// StatusPending entries wait to be sent at their NextAttempt.
+	StatusPending Status = "pending"
+	// StatusSending entries were claimed by a queue run that has not yet
+	// finished with them.
+	StatusSending Status = "sending"
+	// StatusFailed entries will not be tried again until queue retry.
+	StatusFailed Status = "failed"
+)
+
+// Entry is a queued email.
+type Entry struct {
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	// Provider names the provider, failover chain or pool to send
+	// through, chosen when the email was queued.
+	Provider string `json:"provider"`
+	// Timeout bounds each attempt to send, including its retries.
+	// Retries and RetryAmbiguous are as in the provider's config. All
+	// three are fixed when the email is queued.
+	Timeout        time.Duration   `json:"timeout"`
+	Retries        int             `json:"retries,omitzero"`
+	RetryAmbiguous bool            `json:"retry_ambiguous,omitzero"`
+	Email          *provider.Email `json:"email"`
+
+	Status      Status    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// Lease is when a sending entry's queue run is assumed to have
+	// crashed.
+	Lease time.Time `json:"lease,omitzero"`
+}
+
+// Recipients is the number of addresses the entry's email is sent to.
+func (e *Entry) Recipients() int {
+	return len(e.Email.To) + len(e.Email.Cc) + len(e.Email.Bcc)
+}
+
+// Queue is a directory of queued emails.
+type Queue struct {
+	dir string
+	now func() time.Time
+}
+
+// Open returns the queue in Dir.
+func Open() (*Queue, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return New(dir), nil
+}
+
+// New returns the queue kept in dir.
+func New(dir string) *Queue {
+	return &Queue{dir: dir, now: time.Now}
+}
+
+// Dir returns the directory queued emails are kept in.
+func Dir() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queue"), nil
+}
+
+func (q *Queue) Dir() string {
+	return q.dir
+}
+
+// Add queues e to be sent as soon as possible, setting its ID. Attachments
+// are read into the entry, so the files may change or go away before it
+// is sent.
+func (q *Queue) Add(e *Entry) error {
+	if err := os.MkdirAll(q.dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	email := *e.Email
+	email.Attachments = make([]provider.Attachment, len(e.Email.Attachments))
+	for i, att := range e.Email.Attachments {
+		if att.Path != "" {
+			content, err := os.ReadFile(att.Path)
+			if err != nil {
+				return fmt.Errorf("failed to read attachment %s: %w", att.Path, err)
+			}
+			if att.Filename == "" {
+				att.Filename = filepath.Base(att.Path)
+			}
+			att.Content, att.Path = content, ""
+		}
+		email.Attachments[i] = att
+	}
+
+	now := q.now()
+	id, err := newID(now)
+	if err != nil {
+		return err
+	}
+	e.ID = id
+	e.Created = now
+	e.Email = &email
+	e.Status = StatusPending
+	e.NextAttempt = now
+	return q.save(e)
+}
+
+// List returns every queued email, oldest first.
+func (q *Queue) List() ([]*Entry, error) {
+	files, err := os.ReadDir(q.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+	var entries []*Entry
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		e, err := q.load(strings.TrimSuffix(name, ".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			// Removed since the directory was read.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Created.Equal(entries[j].Created) {
+			return entries[i].Created.Before(entries[j].Created)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+// Get returns the queued email with the given ID.
+func (q *Queue) Get(id string) (*Entry, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	e, err := q.load(id)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("queued email %q not found", id)
+	}
+	return e, err
+}
+
+// Claim takes the oldest pending email that is due, marking it sending
+// until its Timeout and then grace have passed, and returns it, or nil
+// when none is due. The caller must Save or Remove it once it has been
+// tried.
+//
+// Emails left sending by a queue run that outlived its lease, presumably
+// because it crashed, may or may not have been delivered. They are failed
+// rather than sent twice, unless they allow ambiguous retries.
+func (q *Queue) Claim(grace time.Duration) (*Entry, error) {
+	var claimed *Entry
+	err := q.locked(func() error {
+		entries, err := q.List()
+		if err != nil {
+			return err
+		}
+		now := q.now()
+		for _, e := range entries {
+			if e.Status == StatusSending && now.After(e.Lease) {
+				e.Lease = time.Time{}
+				if e.RetryAmbiguous {
+					e.Status = StatusPending
+				} else {
+					e.Status = StatusFailed
+					e.LastError = "interrupted while sending; it may have been delivered"
+				}
+				if err := q.save(e); err != nil {
+					return err
+				}
+			}
+			if claimed == nil && e.Status == StatusPending && !e.NextAttempt.After(now) {
+				e.Status = StatusSending
+				e.Attempts++
+				e.Lease = now.Add(e.Timeout + grace)
+				if err := q.save(e); err != nil {
+					return err
+				}
+				claimed = e
+			}
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+// Save writes back an entry changed after it was claimed.
+func (q *Queue) Save(e *Entry) error {
+	if e.Status != StatusSending {
+		e.Lease = time.Time{}
+	}
+	return q.locked(func() error {
+		return q.save(e)
+	})
+}
+
+// Remove deletes the queued email with the given ID.
+func (q *Queue) Remove(id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+	return q.locked(func() error {
+		return q.remove(id)
+	})
+}
+
+// Retry makes the queued emails that match due now, with their attempts
+// reset, and returns them. Emails being sent are left alone.
+func (q *Queue) Retry(match func(*Entry) bool) ([]*Entry, error) {
+	var retried []*Entry
+	err := q.locked(func() error {
+		entries, err := q.List()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Status == StatusSending || !match(e) {
+				continue
+			}
+			e.Status = StatusPending
+			e.Attempts = 0
+			e.NextAttempt = q.now()
+			e.LastError = ""
+			if err := q.save(e); err != nil {
+				return err
+			}
+			retried = append(retried, e)
+		}
+		return nil
+	})
+	return retried, err
+}
+
+// Purge removes the queued emails that match and returns them. Emails
+// being sent are left alone.
+func (q *Queue) Purge(match func(*Entry) bool) ([]*Entry, error) {
+	var purged []*Entry
+	err := q.locked(func() error {
+		entries, err := q.List()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if e.Status == StatusSending || !match(e) {
+				continue
+			}
+			if err := q.remove(e.ID); err != nil {
+				return err
+			}
+			purged = append(purged, e)
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// Backoff returns how long to wait before trying an email again after
+// attempts failed attempts: a minute, doubling to at most an hour.
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return minBackoff
+	}
+	wait := minBackoff << min(attempts-1, 16)
+	return min(wait, maxBackoff)
+}
+
+func (q *Queue) locked(fn func() error) error {
+	unlock, err := config.Lock(filepath.Join(q.dir, ".queue"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return fn()
+}
+
+func (q *Queue) path(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+func (q *Queue) load(id string) (*Entry, error) {
+	data, err := os.ReadFile(q.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, fmt.Errorf("failed to parse queued email %s: %w", q.path(id), err)
+	}
+	if e.Email == nil {
+		return nil, fmt.Errorf("queued email %s has no email", q.path(id))
+	}
+	return &e, nil
+}
+
+func (q *Queue) save(e *Entry) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued email: %w", err)
+	}
+	if err := state.WriteFile(q.path(e.ID), data); err != nil {
+		return fmt.Errorf("failed to write queued email: %w", err)
+	}
+	return nil
+}
+
+func (q *Queue) remove(id string) error {
+	if err := os.Remove(q.path(id)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("queued email %q not found", id)
+		}
+		return fmt.Errorf("failed to remove queued email: %w", err)
+	}
+	return state.SyncDir(q.dir)
+}
+
+// newID returns an ID that sorts by the time it was created, such as
+// 20260301-120000-9f86d081.
+func newID(now time.Time) (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate queue ID: %w", err)
+	}
+	return now.UTC().Format("20060102-150405") + "-" + hex.EncodeToString(b), nil
+}
+
+func checkID(id string) error {
+	if id == "" || strings.HasPrefix(id, ".") || strings.ContainsAny(id, `/\`) {
+		return fmt.Errorf("invalid queue ID %q", id)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package queue
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tnm/email-cli/internal/provider"
+)
+
+// newTestQueue returns a queue in a temporary directory whose clock stands
+// still at *now.
+func newTestQueue(t *testing.T, now *time.Time) *Queue {
+	t.Helper()
+	q := New(filepath.Join(t.TempDir(), "queue"))
+	q.now = func() time.Time { return *now }
+	return q
+}
+
+func addTestEntry(t *testing.T, q *Queue, to string) *Entry {
+	t.Helper()
+	e := &Entry{Provider: "relay", Email: &provider.Email{To: []string{to}, Subject: "Hi", Body: "Hello"}}
+	if err := q.Add(e); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	return e
+}
+
+func TestAdd_Attachments(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestQueue(t, &now)
+
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("numbers"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	e := &Entry{Provider: "relay", Email: &provider.Email{
+		To:          []string{"a@example.com"},
+		Attachments: []provider.Attachment{{Path: path}},
+	}}
+	if err := q.Add(e); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	// The queued email must not depend on the file.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := q.Get(e.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	att := got.Email.Attachments[0]
+	if att.Path != "" || att.Filename != "report.txt" || string(att.Content) != "numbers" {
+		t.Errorf("attachment = %+v, want report.txt read into the entry", att)
+	}
+	if got.Status != StatusPending || !got.NextAttempt.Equal(now) {
+		t.Errorf("Get() status = %s, next attempt = %v, want pending now", got.Status, got.NextAttempt)
+	}
+
+	info, err := os.Stat(q.path(e.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("entry mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestClaim(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestQueue(t, &now)
+	first := addTestEntry(t, q, "a@example.com")
+	now = now.Add(time.Second)
+	second := addTestEntry(t, q, "b@example.com")
+
+	e, err := q.Claim(time.Minute)
+	if err != nil || e == nil || e.ID != first.ID {
+		t.Fatalf("Claim() = %v, %v, want the oldest entry", e, err)
+	}
+	if e.Status != StatusSending || e.Attempts != 1 {
+		t.Errorf("claimed status = %s, attempts = %d, want sending and 1", e.Status, e.Attempts)
+	}
+
+	// A failed attempt waits its backoff before it is claimed again.
+	e.Status = StatusPending
+	e.NextAttempt = now.Add(Backoff(e.Attempts))
+	if err := q.Save(e); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if e, err := q.Claim(time.Minute); err != nil || e == nil || e.ID != second.ID {
+		t.Fatalf("Claim() = %v, %v, want the second entry", e, err)
+	}
+	if e, err := q.Claim(time.Minute); err != nil || e != nil {
+		t.Fatalf("Claim() = %v, %v, want nothing due", e, err)
+	}
+
+	now = now.Add(Backoff(1))
+	e, err = q.Claim(time.Minute)
+	if err != nil || e == nil || e.ID != first.ID || e.Attempts != 2 {
+		t.Fatalf("Claim() = %+v, %v, want the first entry's second attempt", e, err)
+	}
+	if err := q.Remove(e.ID); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := q.Get(e.ID); err == nil {
+		t.Errorf("Get() after Remove() found the entry")
+	}
+}
+
+func TestClaim_ExpiredLease(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestQueue(t, &now)
+	e := addTestEntry(t, q, "a@example.com")
+	ambiguous := addTestEntry(t, q, "b@example.com")
+	ambiguous.RetryAmbiguous = true
+	if err := q.Save(ambiguous); err != nil {
+		t.Fatal(err)
+	}
+
+	// Claim both, then let their run "crash".
+	for i := 0; i < 2; i++ {
+		if c, err := q.Claim(time.Minute); err != nil || c == nil {
+			t.Fatalf("Claim() = %v, %v", c, err)
+		}
+	}
+	now = now.Add(2 * time.Minute)
+
+	claimed, err := q.Claim(time.Minute)
+	if err != nil || claimed == nil || claimed.ID != ambiguous.ID {
+		t.Fatalf("Claim() = %v, %v, want the entry allowing ambiguous retries", claimed, err)
+	}
+	got, err := q.Get(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != StatusFailed || got.LastError == "" {
+		t.Errorf("interrupted entry status = %s (%q), want failed", got.Status, got.LastError)
+	}
+}
+
+func TestRetryAndPurge(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := newTestQueue(t, &now)
+	failed := addTestEntry(t, q, "a@example.com")
+	failed.Status, failed.Attempts, failed.LastError = StatusFailed, 3, "550 no such user"
+	if err := q.Save(failed); err != nil {
+		t.Fatal(err)
+	}
+	addTestEntry(t, q, "b@example.com")
+	sending, err := q.Claim(time.Minute)
+	if err != nil || sending == nil {
+		t.Fatalf("Claim() = %v, %v", sending, err)
+	}
+
+	all := func(*Entry) bool { return true }
+	retried, err := q.Retry(all)
+	if err != nil || len(retried) != 1 || retried[0].ID != failed.ID {
+		t.Fatalf("Retry() = %v, %v, want only the failed entry", retried, err)
+	}
+	if retried[0].Status != StatusPending || retried[0].Attempts != 0 || retried[0].LastError != "" {
+		t.Errorf("retried entry = %+v, want pending with attempts reset", retried[0])
+	}
+
+	purged, err := q.Purge(all)
+	if err != nil || len(purged) != 1 || purged[0].ID != failed.ID {
+		t.Fatalf("Purge() = %v, %v, want only the entry not being sent", purged, err)
+	}
+	entries, err := q.List()
+	if err != nil || len(entries) != 1 || entries[0].ID != sending.ID {
+		t.Errorf("List() = %v, %v, want the entry being sent", entries, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		5:  16 * time.Minute,
+		7:  time.Hour,
+		99: time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestGet_InvalidID(t *testing.T) {
+	q := New(t.TempDir())
+	for _, id := range []string{"", "../config", ".queue.lock"} {
+		if _, err := q.Get(id); err == nil {
+			t.Errorf("Get(%q) succeeded, want an error", id)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Package state keeps the small JSON files email-cli updates from one run
+// to the next, such as how many messages each provider has sent today.
+// Updates hold a lock file, so concurrent runs do not lose each other's
+// changes.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/tnm/email-cli/internal/config"
+)
+
+// File is a JSON state file.
+type File struct {
+	path string
+}
+
+// Open returns the state file with the given name in Dir.
+func Open(name string) (*File, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return NewFile(filepath.Join(dir, name)), nil
+}
+
+// NewFile returns the state file at path.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Dir returns the directory state files are kept in.
+func Dir() (string, error) {
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "state"), nil
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load reads the file into v. A missing file leaves v unchanged.
+func (f *File) Load(v any) error {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse state %s: %w", f.path, err)
+	}
+	return nil
+}
+
+// Update locks the file, loads it into v, and calls fn. If fn succeeds,
+// v is written back before the lock is released.
+func (f *File) Update(v any, fn func() error) error {
+	unlock, err := config.Lock(f.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := f.Load(v); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	return f.save(v)
+}
+
+func (f *File) save(v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := WriteFile(f.path, data); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// WriteFile replaces the file at path with data, readable only by the
+// user. The data is synced to disk before it is renamed into place, and
+// the rename is synced too, so after a crash the file holds either the
+// old data or the new, never a mix.
+func WriteFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return SyncDir(filepath.Dir(path))
+}
+
+// SyncDir flushes a directory's entries, such as a file just renamed or
+// removed, to disk. Windows cannot sync a directory, and has no need to.
+func SyncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,78 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestLoad_Missing(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "missing.json"))
+	v := map[string]int{"kept": 1}
+	if err := f.Load(&v); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if v["kept"] != 1 {
+		t.Errorf("Load() of a missing file changed v to %v", v)
+	}
+}
+
+func TestUpdate_Concurrent(t *testing.T) {
+	f := NewFile(filepath.Join(t.TempDir(), "state", "counter.json"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var n int
+			if err := f.Update(&n, func() error {
+				n++
+				return nil
+			}); err != nil {
+				t.Errorf("Update() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var n int
+	if err := f.Load(&n); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if n != 20 {
+		t.Errorf("counter = %d, want 20", n)
+	}
+	if _, err := os.Stat(f.Path() + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+	info, err := os.Stat(f.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("state file mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestWriteFile_Replaces(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "entry.json")
+	for _, data := range []string{"first", "second"} {
+		if err := WriteFile(path, []byte(data)); err != nil {
+			t.Fatalf("WriteFile() error = %v", err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "second" {
+		t.Errorf("ReadFile() = %q, %v, want the second write", got, err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("directory holds %d files, want no temporary files left", len(files))
+	}
+}